@@ -0,0 +1,82 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// compareCmd represents the command for comparing benchmark reports.
+var compareCmd = &cobra.Command{
+	Use:                "compare <baseline.json> <current.json> [flags]",
+	Short:              "A command line tool for comparing the benchmark reports against the baseline",
+	Args:               cobra.ExactArgs(2),
+	DisableAutoGenTag:  true,
+	SilenceUsage:       true,
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		current, err := stats.ReadReport(args[1])
+		if err != nil {
+			logrus.Errorf("failed to read current report: %v", err)
+			return err
+		}
+
+		return compareReport(cfg, args[0], current)
+	},
+}
+
+// init initializes compare command.
+func init() {
+	flags := compareCmd.Flags()
+	flags.Float64Var(&cfg.Compare.LatencyThreshold, "latency-threshold", cfg.Compare.LatencyThreshold, "Specify the maximum latency regression in percent against the baseline")
+	flags.Float64Var(&cfg.Compare.BackToSourceRateThreshold, "back-to-source-rate-threshold", cfg.Compare.BackToSourceRateThreshold, "Specify the maximum back-to-source rate increase in percentage points against the baseline")
+
+	if err := viper.BindPFlags(flags); err != nil {
+		panic(fmt.Errorf("bind cache compare flags to viper: %w", err))
+	}
+}
+
+// compareReport compares the current report with the baseline report and
+// returns an error if the thresholds are exceeded.
+func compareReport(cfg *config.Config, baselinePath string, current *stats.Report) error {
+	baseline, err := stats.ReadReport(baselinePath)
+	if err != nil {
+		logrus.Errorf("failed to read baseline report: %v", err)
+		return err
+	}
+
+	comparisons := stats.Compare(baseline, current, &cfg.Compare)
+	fmt.Fprintf(os.Stderr, "Comparing with baseline %s ...\n", baselinePath)
+	if err := stats.PrintComparisons(os.Stdout, comparisons); err != nil {
+		logrus.Errorf("failed to print comparisons: %v", err)
+		return err
+	}
+
+	if stats.Regressed(comparisons) {
+		return errors.New("benchmark regressed against the baseline")
+	}
+
+	return nil
+}
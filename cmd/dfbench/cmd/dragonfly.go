@@ -19,11 +19,14 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/dragonflyoss/perf-tests/pkg/backend"
 	"github.com/dragonflyoss/perf-tests/pkg/config"
 	"github.com/dragonflyoss/perf-tests/pkg/dragonfly"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/manager"
 	"github.com/dragonflyoss/perf-tests/pkg/stats"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -51,9 +54,44 @@ var dragonflyCmd = &cobra.Command{
 func init() {
 	flags := dragonflyCmd.Flags()
 	flags.Uint32VarP(&cfg.Dragonfly.Number, "number", "n", cfg.Dragonfly.Number, "Specify the number of times to run the dragonfly benchmark")
+	flags.Uint32Var(&cfg.Dragonfly.Warmup, "warmup", cfg.Dragonfly.Warmup, "Specify the number of warmup iterations to run and discard before the dragonfly benchmark")
+	flags.DurationVar(&cfg.Dragonfly.Cooldown, "cooldown", cfg.Dragonfly.Cooldown, "Specify the duration to wait between iterations of the dragonfly benchmark")
 	flags.StringVar(&cfg.Dragonfly.Namespace, "namespace", cfg.Dragonfly.Namespace, "Specify the namespace to use for the dragonfly benchmark")
-	flags.StringVarP(&cfg.Dragonfly.Downloader, "downloader", "d", cfg.Dragonfly.Downloader, "Specify the downloader to use for the dragonfly benchmark [dfget, proxy], default is dfget")
+	flags.StringVarP(&cfg.Dragonfly.Downloader, "downloader", "d", cfg.Dragonfly.Downloader, "Specify the downloaders to use for the dragonfly benchmark separated by commas [dfget, proxy, dfcache, dfstore, direct], direct downloads from the file server without the dfdaemon as the control group, default is dfget")
 	flags.StringVar(&cfg.Dragonfly.FileSizeLevel, "file-size-level", cfg.Dragonfly.FileSizeLevel, "Specify the file size level to use for the dragonfly benchmark [nano, micro, small, medium, large, xlarge, xxlarge], default is running all levels")
+	flags.StringVar(&cfg.Dragonfly.FileSize, "file-size", cfg.Dragonfly.FileSize, "Specify the file sizes to use for the dragonfly benchmark separated by commas instead of the file size levels, e.g. 256MiB,3GiB, the files are generated by the file server on the fly")
+	flags.Uint32Var(&cfg.Dragonfly.Concurrency, "concurrency", cfg.Dragonfly.Concurrency, "Specify the maximum number of pods downloading at the same time, default is 0 to download in all pods at the same time")
+	flags.Uint32Var(&cfg.Dragonfly.Parallelism, "parallelism", cfg.Dragonfly.Parallelism, "Specify the number of parallel downloads of different tasks in every pod")
+	flags.StringVar(&cfg.Dragonfly.StartPattern, "start-pattern", cfg.Dragonfly.StartPattern, "Specify the pattern to start the downloads in the pods [all-at-once, ramp, waves, seed-first], default is all-at-once")
+	flags.Uint32Var(&cfg.Dragonfly.WaveSize, "wave-size", cfg.Dragonfly.WaveSize, "Specify the number of pods in every wave of the ramp and waves start patterns")
+	flags.DurationVar(&cfg.Dragonfly.RampDuration, "ramp-duration", cfg.Dragonfly.RampDuration, "Specify the duration to start all waves over by the ramp start pattern")
+	flags.StringVar(&cfg.Dragonfly.Cache, "cache", cfg.Dragonfly.Cache, "Specify the cache mode of the downloads [cold, hot], hot reuses the tasks of the first iteration in the later iterations, default is cold")
+	flags.StringVar(&cfg.Dragonfly.RangeMode, "range-mode", cfg.Dragonfly.RangeMode, "Specify the range workload of the proxy downloader [full, fixed, random, sequential], sequential reads the tasks of the pool from head to tail in chunks, default is full")
+	flags.StringVar(&cfg.Dragonfly.Range, "range", cfg.Dragonfly.Range, "Specify the byte range of the fixed range mode, e.g. 0-1023 or bytes=0-1023")
+	flags.Uint64Var(&cfg.Dragonfly.ChunkSize, "chunk-size", cfg.Dragonfly.ChunkSize, "Specify the size of every request in bytes of the random and sequential range modes")
+	flags.Uint32Var(&cfg.Dragonfly.TaskPoolSize, "task-pool-size", cfg.Dragonfly.TaskPoolSize, "Specify the number of tasks read in turn by the sequential range mode")
+	flags.StringVar(&cfg.Dragonfly.Bucket, "bucket", cfg.Dragonfly.Bucket, "Specify the bucket of the object storage to put the files into for the dfstore downloader")
+	flags.BoolVar(&cfg.Dragonfly.Preheat, "preheat", cfg.Dragonfly.Preheat, "Specify whether to download the tasks preheated by the manager after the tasks without preheat in every iteration by dfget and proxy, requires --manager-token")
+	flags.StringVar(&cfg.Manager.URL, "manager", cfg.Manager.URL, "Specify the base URL of the manager REST API to create the preheat jobs, default is http://dragonfly-manager.<namespace>.svc:8080")
+	flags.StringVar(&cfg.Manager.Token, "manager-token", cfg.Manager.Token, "Specify the personal access token of the manager open API to create the preheat jobs")
+	flags.StringVar(&cfg.Manager.Scope, "preheat-scope", cfg.Manager.Scope, "Specify the scope of the peers to preheat [single_seed_peer, all_seed_peers, all_peers], default is the default of the manager")
+	flags.StringVar(&cfg.Dragonfly.Executor, "executor", cfg.Dragonfly.Executor, "Specify the executor to run the downloads [kubernetes, local], default is kubernetes")
+	flags.StringArrayVar(&cfg.Dragonfly.Endpoints, "endpoint", cfg.Dragonfly.Endpoints, "Specify the local dfdaemon endpoint in the format of \"proxy=<addr>,metrics=<addr>,socket=<path>,object-storage=<addr>\" for the local executor, can be repeated, default is the dfdaemon listening on 127.0.0.1:4001, 127.0.0.1:4002 and 127.0.0.1:65004")
+	flags.StringVar(&cfg.Compare.Baseline, "baseline", cfg.Compare.Baseline, "Specify the baseline report in JSON format to compare the dragonfly benchmark with, the command fails if the thresholds are exceeded")
+	flags.Float64Var(&cfg.Compare.LatencyThreshold, "latency-threshold", cfg.Compare.LatencyThreshold, "Specify the maximum latency regression in percent against the baseline")
+	flags.Float64Var(&cfg.Compare.BackToSourceRateThreshold, "back-to-source-rate-threshold", cfg.Compare.BackToSourceRateThreshold, "Specify the maximum back-to-source rate increase in percentage points against the baseline")
+	flags.StringVar(&cfg.Dragonfly.Origin.Bandwidth, "origin-bandwidth", cfg.Dragonfly.Origin.Bandwidth, "Specify the bandwidth cap of every connection of the file server per second to emulate a slow origin, e.g. 10MiB, default is unlimited")
+	flags.StringVar(&cfg.Dragonfly.Origin.AggregateBandwidth, "origin-aggregate-bandwidth", cfg.Dragonfly.Origin.AggregateBandwidth, "Specify the bandwidth cap of the file server per second shared by all connections to emulate a slow origin, e.g. 100MiB, default is unlimited")
+	flags.DurationVar(&cfg.Dragonfly.Origin.TTFB, "origin-ttfb", cfg.Dragonfly.Origin.TTFB, "Specify the delay of the file server before the first byte of every response to emulate a distant origin")
+	flags.DurationVar(&cfg.Dragonfly.Origin.TTFBJitter, "origin-ttfb-jitter", cfg.Dragonfly.Origin.TTFBJitter, "Specify the maximum random deviation of the delay of the file server before the first byte")
+	flags.StringVar(&cfg.Dragonfly.Origin.Fault, "origin-fault", cfg.Dragonfly.Origin.Fault, "Specify the fault injected by the file server into the responses of the faulty tasks downloaded after the tasks without faults in every iteration [error, reset, stall, truncate], default is no fault")
+	flags.Float64Var(&cfg.Dragonfly.Origin.FaultRate, "origin-fault-rate", cfg.Dragonfly.Origin.FaultRate, "Specify the fraction of the requests of the faulty tasks the fault is injected into in (0, 1]")
+	flags.StringVar(&cfg.Dragonfly.Origin.FaultAfter, "origin-fault-after", cfg.Dragonfly.Origin.FaultAfter, "Specify the body sent before the connection is reset, stalled or truncated by the fault, e.g. 1MiB, default is before the first byte")
+	flags.DurationVar(&cfg.Dragonfly.Origin.FaultStall, "origin-fault-stall", cfg.Dragonfly.Origin.FaultStall, "Specify the duration of the stall fault")
+	flags.IntVar(&cfg.Dragonfly.Origin.FaultStatus, "origin-fault-status", cfg.Dragonfly.Origin.FaultStatus, "Specify the 5xx status code of the error fault")
+	flags.BoolVar(&cfg.Dragonfly.Verify, "verify", cfg.Dragonfly.Verify, "Specify whether to verify the size and the digest of every downloaded file against the file server, the command fails if any download is corrupted")
+	flags.BoolVar(&cfg.Dragonfly.OriginTraffic, "origin-traffic", cfg.Dragonfly.OriginTraffic, "Specify whether to count the traffic served by the file server for the tasks of every download step and reconcile it with the back-to-source traffic reported by the dfdaemon")
+	flags.StringVar(&cfg.Dragonfly.FileServer, "file-server", cfg.Dragonfly.FileServer, "Specify the base URL of the file server, default is http://file-server.<namespace>.svc")
 
 	if err := viper.BindPFlags(flags); err != nil {
 		panic(fmt.Errorf("bind cache dragonfly flags to viper: %w", err))
@@ -62,35 +100,46 @@ func init() {
 
 // runDragonfly runs the dragonfly benchmark.
 func runDragonfly(ctx context.Context, cfg *config.Config) error {
-	stats := stats.New(cfg.Dragonfly.Namespace)
-	fileServer := backend.NewFileServer(cfg.Dragonfly.Namespace)
-	dragonfly := dragonfly.New(cfg.Dragonfly.Namespace, fileServer, stats)
-
-	// If file size level is not specified, run all file size levels.
-	if cfg.Dragonfly.FileSizeLevel == "" {
-		fmt.Printf("Running benchmark for all size levels by %s ...\n", strings.ToUpper(cfg.Dragonfly.Downloader))
-		if err := dragonfly.Run(ctx, cfg.Dragonfly.Downloader); err != nil {
-			logrus.Errorf("failed to run dragonfly benchmark: %v", err)
-			return err
-		}
+	executor, err := newExecutor(cfg)
+	if err != nil {
+		logrus.Errorf("failed to create executor: %v", err)
+		return err
+	}
 
-		if err := stats.PrettyPrint(); err != nil {
-			logrus.Errorf("failed to print dragonfly benchmark statistics: %v", err)
-			return err
-		}
+	origin, err := cfg.Dragonfly.Origin.Origin()
+	if err != nil {
+		logrus.Errorf("failed to parse origin: %v", err)
+		return err
+	}
 
-		if err := dragonfly.Cleanup(ctx); err != nil {
-			logrus.Errorf("failed to cleanup dragonfly benchmark: %v", err)
-			return err
-		}
-		return nil
+	stats := stats.New(cfg, executor)
+	fileServer := backend.NewFileServer(cfg.Dragonfly.Namespace, origin)
+	if cfg.Dragonfly.FileServer != "" {
+		fileServer = backend.NewFileServerWithBaseURL(cfg.Dragonfly.FileServer, origin)
 	}
+	manager := manager.New(cfg.Manager.BaseURL(cfg.Dragonfly.Namespace), cfg.Manager.Token, cfg.Manager.Scope, executor)
+	dragonfly := dragonfly.New(&cfg.Dragonfly, executor, fileServer, stats, manager)
 
-	// Run the benchmark for the specified file size level.
-	fmt.Printf("Running benchmark for %s size level by %s ...\n", strings.ToUpper(cfg.Dragonfly.FileSizeLevel), strings.ToUpper(cfg.Dragonfly.Downloader))
-	if err := dragonfly.RunByFileSizes(ctx, cfg.Dragonfly.Downloader, backend.FileSizeLevel(cfg.Dragonfly.FileSizeLevel)); err != nil {
-		logrus.Errorf("failed to run dragonfly benchmark: %v", err)
-		return err
+	for _, downloader := range cfg.Dragonfly.Downloaders() {
+		// If neither file size level nor file size is specified, run all file size levels.
+		if cfg.Dragonfly.FileSizeLevel == "" && cfg.Dragonfly.FileSize == "" {
+			fmt.Fprintf(os.Stderr, "Running benchmark for all size levels by %s with %s ...\n", strings.ToUpper(downloader), fanOut(cfg))
+			if err := dragonfly.Run(ctx, downloader); err != nil {
+				logrus.Errorf("failed to run dragonfly benchmark: %v", err)
+				return err
+			}
+
+			continue
+		}
+
+		// Run the benchmark for the specified file size level or file sizes.
+		for _, fileSizeLevel := range cfg.Dragonfly.FileSizeLevels() {
+			fmt.Fprintf(os.Stderr, "Running benchmark for %s size level by %s with %s ...\n", fileSizeLevel, strings.ToUpper(downloader), fanOut(cfg))
+			if err := dragonfly.RunByFileSizes(ctx, downloader, fileSizeLevel); err != nil {
+				logrus.Errorf("failed to run dragonfly benchmark: %v", err)
+				return err
+			}
+		}
 	}
 
 	if err := stats.PrettyPrint(); err != nil {
@@ -102,5 +151,79 @@ func runDragonfly(ctx context.Context, cfg *config.Config) error {
 		logrus.Errorf("failed to cleanup dragonfly benchmark: %v", err)
 		return err
 	}
+
+	if err := compareWithBaseline(cfg, stats); err != nil {
+		return err
+	}
+
+	return checkCorrupted(stats)
+}
+
+// fanOut returns the description of the download fan-out.
+func fanOut(cfg *config.Config) string {
+	concurrency := "unlimited"
+	if cfg.Dragonfly.Concurrency > 0 {
+		concurrency = fmt.Sprint(cfg.Dragonfly.Concurrency)
+	}
+
+	return fmt.Sprintf("concurrency %s and parallelism %d", concurrency, cfg.Dragonfly.Parallelism)
+}
+
+// compareWithBaseline compares the statistics with the baseline report if specified.
+func compareWithBaseline(cfg *config.Config, stats stats.Stats) error {
+	if cfg.Compare.Baseline == "" {
+		return nil
+	}
+
+	current, err := stats.Report()
+	if err != nil {
+		logrus.Errorf("failed to generate dragonfly benchmark report: %v", err)
+		return err
+	}
+
+	return compareReport(cfg, cfg.Compare.Baseline, current)
+}
+
+// checkCorrupted returns an error if any download mismatches the size or the digest of the file server,
+// the downloads of the faulty tasks are reported as failed instead.
+func checkCorrupted(stats stats.Stats) error {
+	var downloads, corrupted int
+	for _, timing := range stats.GetTimings() {
+		if timing.Upload || timing.Preheat || timing.Faulty {
+			continue
+		}
+
+		downloads++
+		if timing.Corrupted {
+			corrupted++
+		}
+	}
+
+	if corrupted > 0 {
+		return fmt.Errorf("%d of %d downloads are corrupted", corrupted, downloads)
+	}
+
 	return nil
 }
+
+// newExecutor creates the executor to run the downloads.
+func newExecutor(cfg *config.Config) (executor.Executor, error) {
+	switch cfg.Dragonfly.Executor {
+	case config.ExecutorKubernetes:
+		return executor.NewKubernetes(cfg.KubeConfig, cfg.Dragonfly.Namespace, "client")
+	case config.ExecutorLocal:
+		var endpoints []executor.Endpoint
+		for _, e := range cfg.Dragonfly.Endpoints {
+			endpoint, err := executor.ParseEndpoint(e)
+			if err != nil {
+				return nil, err
+			}
+
+			endpoints = append(endpoints, endpoint)
+		}
+
+		return executor.NewLocal(endpoints...), nil
+	default:
+		return nil, fmt.Errorf("unknown executor %q", cfg.Dragonfly.Executor)
+	}
+}
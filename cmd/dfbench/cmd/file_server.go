@@ -0,0 +1,57 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dragonflyoss/perf-tests/pkg/fileserver"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// fileServerCmd represents the command serving the files of the benchmarks.
+var fileServerCmd = &cobra.Command{
+	Use:                "file-server [flags]",
+	Short:              "A file server generating the files of the benchmarks on the fly",
+	Args:               cobra.NoArgs,
+	DisableAutoGenTag:  true,
+	SilenceUsage:       true,
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The file server runs until it is stopped, so the benchmark timeout is not applied.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return fileserver.New(&cfg.FileServer).Serve(ctx)
+	},
+}
+
+// init initializes file server command.
+func init() {
+	flags := fileServerCmd.Flags()
+	flags.StringVar(&cfg.FileServer.Addr, "addr", cfg.FileServer.Addr, "Specify the address for the file server to listen on")
+	flags.Uint64Var(&cfg.FileServer.Seed, "seed", cfg.FileServer.Seed, "Specify the seed of the pseudo-random content of the files, the same seed serves the same content")
+
+	if err := viper.BindPFlags(flags); err != nil {
+		panic(fmt.Errorf("bind cache file server flags to viper: %w", err))
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/image"
+	"github.com/dragonflyoss/perf-tests/pkg/manager"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// imageCmd represents the benchmark command for the image pulls.
+var imageCmd = &cobra.Command{
+	Use:                "image [flags]",
+	Short:              "A command line tool for benchmarking the image pulls through Dragonfly",
+	Args:               cobra.NoArgs,
+	DisableAutoGenTag:  true,
+	SilenceUsage:       true,
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+
+		logrus.Infof("running image benchmark %d times", cfg.Image.Number)
+		return runImage(ctx, cfg)
+	},
+}
+
+// init initializes image command.
+func init() {
+	flags := imageCmd.Flags()
+	flags.Uint32VarP(&cfg.Image.Number, "number", "n", cfg.Image.Number, "Specify the number of times to run the image benchmark")
+	flags.StringVar(&cfg.Image.Namespace, "namespace", cfg.Image.Namespace, "Specify the namespace of the dragonfly client pods for the image benchmark")
+	flags.StringArrayVar(&cfg.Image.Images, "image", cfg.Image.Images, "Specify the image to pull on every node, can be repeated")
+	flags.StringVar(&cfg.Image.Registry, "registry", cfg.Image.Registry, "Specify the registry to pull the images without a registry host from, e.g. 127.0.0.1:30500 of the local registry in the cluster")
+	flags.StringVar(&cfg.Image.Puller, "puller", cfg.Image.Puller, "Specify the tool to pull the images [ctr, crictl], only ctr reports the pull time of every layer, default is ctr")
+	flags.StringVar(&cfg.Image.HostsDir, "hosts-dir", cfg.Image.HostsDir, "Specify the directory of the containerd registry hosts configuration with the dfdaemon mirror for ctr")
+	flags.StringVar(&cfg.Image.Cache, "cache", cfg.Image.Cache, "Specify the cache mode of the pulls [cold, hot], the images of --registry are copied to a new repository for every iteration by cold and for the first iteration only by hot, default is cold")
+	flags.BoolVar(&cfg.Image.Preheat, "preheat", cfg.Image.Preheat, "Specify whether to preheat the images by the manager before every pull, requires --manager-token")
+	flags.StringVar(&cfg.Manager.URL, "manager", cfg.Manager.URL, "Specify the base URL of the manager REST API to create the preheat jobs, default is http://dragonfly-manager.<namespace>.svc:8080")
+	flags.StringVar(&cfg.Manager.Token, "manager-token", cfg.Manager.Token, "Specify the personal access token of the manager open API to create the preheat jobs")
+	flags.StringVar(&cfg.Manager.Scope, "preheat-scope", cfg.Manager.Scope, "Specify the scope of the peers to preheat [single_seed_peer, all_seed_peers, all_peers], default is the default of the manager")
+
+	if err := viper.BindPFlags(flags); err != nil {
+		panic(fmt.Errorf("bind cache image flags to viper: %w", err))
+	}
+}
+
+// runImage runs the image benchmark.
+func runImage(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.Image.Images) == 0 {
+		return errors.New("no image specified")
+	}
+
+	executor, err := executor.NewKubernetes(cfg.KubeConfig, cfg.Image.Namespace, "client")
+	if err != nil {
+		logrus.Errorf("failed to create executor: %v", err)
+		return err
+	}
+
+	stats := stats.New(cfg, executor)
+	manager := manager.New(cfg.Manager.BaseURL(cfg.Image.Namespace), cfg.Manager.Token, cfg.Manager.Scope, executor)
+	image := image.New(&cfg.Image, executor, stats, manager)
+
+	fmt.Fprintf(os.Stderr, "Running benchmark for %d images by %s ...\n", len(cfg.Image.Images), strings.ToUpper(cfg.Image.Puller))
+	if err := image.Run(ctx); err != nil {
+		logrus.Errorf("failed to run image benchmark: %v", err)
+		return err
+	}
+
+	if err := stats.PrettyPrint(); err != nil {
+		logrus.Errorf("failed to print image benchmark statistics: %v", err)
+		return err
+	}
+
+	if err := image.Cleanup(ctx); err != nil {
+		logrus.Errorf("failed to cleanup image benchmark: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -18,9 +18,14 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/nydus"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -48,6 +53,9 @@ func init() {
 	flags := nydusCmd.Flags()
 	flags.Uint32VarP(&cfg.Nydus.Number, "number", "n", cfg.Nydus.Number, "Specify the number of times to run the nydus benchmark")
 	flags.StringVar(&cfg.Nydus.Namespace, "namespace", cfg.Nydus.Namespace, "Specify the namespace to use for the nydus benchmark")
+	flags.StringArrayVar(&cfg.Nydus.Images, "image", cfg.Nydus.Images, "Specify the RAFS image to start containers from on every node, can be repeated")
+	flags.StringVar(&cfg.Nydus.LabelSelector, "label-selector", cfg.Nydus.LabelSelector, "Specify the label selector of the nydus-snapshotter pods")
+	flags.StringVar(&cfg.Nydus.Container, "container", cfg.Nydus.Container, "Specify the container of the nydus-snapshotter pods to execute commands in")
 
 	if err := viper.BindPFlags(flags); err != nil {
 		panic(fmt.Errorf("bind cache nydus flags to viper: %w", err))
@@ -56,6 +64,34 @@ func init() {
 
 // runNydus runs the nydus benchmark.
 func runNydus(ctx context.Context, cfg *config.Config) error {
-	// TODO: Add nydus benchmark logic here.
+	if len(cfg.Nydus.Images) == 0 {
+		return errors.New("no nydus image specified")
+	}
+
+	executor, err := executor.NewKubernetes(cfg.KubeConfig, cfg.Nydus.Namespace, cfg.Nydus.Container)
+	if err != nil {
+		logrus.Errorf("failed to create executor: %v", err)
+		return err
+	}
+
+	stats := stats.New(cfg, executor)
+	nydus := nydus.New(&cfg.Nydus, executor, stats)
+
+	fmt.Fprintf(os.Stderr, "Running benchmark for %d images by NYDUS ...\n", len(cfg.Nydus.Images))
+	if err := nydus.Run(ctx); err != nil {
+		logrus.Errorf("failed to run nydus benchmark: %v", err)
+		return err
+	}
+
+	if err := stats.PrettyPrint(); err != nil {
+		logrus.Errorf("failed to print nydus benchmark statistics: %v", err)
+		return err
+	}
+
+	if err := nydus.Cleanup(ctx); err != nil {
+		logrus.Errorf("failed to cleanup nydus benchmark: %v", err)
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,113 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/proxyload"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// proxyLoadCmd represents the load benchmark command for the dfdaemon proxy.
+var proxyLoadCmd = &cobra.Command{
+	Use:                "proxy-load [flags]",
+	Short:              "A command line tool for benchmarking the dfdaemon proxy by an open-loop load",
+	Args:               cobra.NoArgs,
+	DisableAutoGenTag:  true,
+	SilenceUsage:       true,
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		defer cancel()
+
+		logrus.Debugf("running proxy load benchmark in %s mode for %s", cfg.ProxyLoad.Mode, cfg.ProxyLoad.Duration)
+		return runProxyLoad(ctx, cfg)
+	},
+}
+
+// init initializes proxy load command.
+func init() {
+	flags := proxyLoadCmd.Flags()
+	flags.StringVar(&cfg.ProxyLoad.Mode, "mode", cfg.ProxyLoad.Mode, "Specify the mode of the load [repeat, random, sequential], repeat requests the same task, random requests a new task every time, sequential reads the tasks of the pool from head to tail in chunks, default is repeat")
+	flags.StringVar(&cfg.ProxyLoad.Proxy, "proxy", cfg.ProxyLoad.Proxy, "Specify the URL of the dfdaemon proxy to send the requests through")
+	flags.StringVar(&cfg.ProxyLoad.TargetURL, "target-url", cfg.ProxyLoad.TargetURL, "Specify the URL of the file to request, default is the small file of the file server, or the large file in the sequential mode")
+	flags.Uint32Var(&cfg.ProxyLoad.Rate, "rate", cfg.ProxyLoad.Rate, "Specify the number of requests per second, the requests scheduled while all workers are busy are dropped, 0 sends the requests as fast as possible by the max workers")
+	flags.DurationVar(&cfg.ProxyLoad.Duration, "duration", cfg.ProxyLoad.Duration, "Specify the duration to send the requests")
+	flags.DurationVar(&cfg.ProxyLoad.Timeout, "request-timeout", cfg.ProxyLoad.Timeout, "Specify the timeout of every request")
+	flags.Uint32Var(&cfg.ProxyLoad.MaxWorkers, "max-workers", cfg.ProxyLoad.MaxWorkers, "Specify the maximum number of the requests in flight")
+	flags.StringVar(&cfg.ProxyLoad.Range, "range", cfg.ProxyLoad.Range, "Specify the byte range of every request in the repeat and random modes, e.g. 0-1023 or bytes=0-1023, default is the whole file")
+	flags.Uint64Var(&cfg.ProxyLoad.FileSize, "file-size", cfg.ProxyLoad.FileSize, "Specify the size of the file in bytes read by the sequential mode")
+	flags.Uint64Var(&cfg.ProxyLoad.ChunkSize, "chunk-size", cfg.ProxyLoad.ChunkSize, "Specify the size of every request in bytes of the sequential mode")
+	flags.Uint32Var(&cfg.ProxyLoad.Streams, "streams", cfg.ProxyLoad.Streams, "Specify the number of the passes read in turn by the sequential mode, i.e. the number of the tasks read at the same time")
+	flags.Uint32Var(&cfg.ProxyLoad.URLCount, "url-count", cfg.ProxyLoad.URLCount, "Specify the number of the tasks read repeatedly by the sequential mode")
+	flags.StringVar(&cfg.Dragonfly.Namespace, "namespace", cfg.Dragonfly.Namespace, "Specify the namespace of the dragonfly client pods to collect the metrics from")
+	flags.StringVar(&cfg.Dragonfly.FileServer, "file-server", cfg.Dragonfly.FileServer, "Specify the base URL of the file server, default is http://file-server.<namespace>.svc")
+	flags.StringVar(&cfg.Dragonfly.Executor, "executor", cfg.Dragonfly.Executor, "Specify the executor to collect the dfdaemon metrics [kubernetes, local], default is kubernetes")
+	flags.StringArrayVar(&cfg.Dragonfly.Endpoints, "endpoint", cfg.Dragonfly.Endpoints, "Specify the local dfdaemon endpoint in the format of \"proxy=<addr>,metrics=<addr>\" to collect the metrics from for the local executor, can be repeated")
+
+	if err := viper.BindPFlags(flags); err != nil {
+		panic(fmt.Errorf("bind cache proxy load flags to viper: %w", err))
+	}
+}
+
+// runProxyLoad runs the proxy load benchmark.
+func runProxyLoad(ctx context.Context, cfg *config.Config) error {
+	executor, err := newExecutor(cfg)
+	if err != nil {
+		logrus.Errorf("failed to create executor: %v", err)
+		return err
+	}
+
+	fileServer := cfg.Dragonfly.FileServer
+	if fileServer == "" {
+		fileServer = fmt.Sprintf("http://file-server.%s.svc", cfg.Dragonfly.Namespace)
+	}
+
+	stats := stats.New(cfg, executor)
+	targetURL := cfg.ProxyLoad.Target(fileServer)
+	proxyLoad, err := proxyload.New(&cfg.ProxyLoad, targetURL, stats)
+	if err != nil {
+		logrus.Errorf("failed to create proxy load: %v", err)
+		return err
+	}
+
+	rate := "max"
+	if cfg.ProxyLoad.Rate > 0 {
+		rate = fmt.Sprintf("%d/s", cfg.ProxyLoad.Rate)
+	}
+
+	fmt.Fprintf(os.Stderr, "Running %s load of %s through %s at %s rate for %s ...\n", strings.ToUpper(cfg.ProxyLoad.Mode), targetURL, cfg.ProxyLoad.Proxy, rate, cfg.ProxyLoad.Duration)
+	if err := proxyLoad.Run(ctx); err != nil {
+		logrus.Errorf("failed to run proxy load benchmark: %v", err)
+		return err
+	}
+
+	if err := stats.PrettyPrint(); err != nil {
+		logrus.Errorf("failed to print proxy load benchmark statistics: %v", err)
+		return err
+	}
+
+	return nil
+}
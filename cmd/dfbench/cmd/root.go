@@ -17,17 +17,24 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 // Initialize default dfbench config.
 var cfg = config.New()
 
+// cfgFile is the path of the dfbench config file.
+var cfgFile string
+
 // rootCmd represents the benchmark command.
 var rootCmd = &cobra.Command{
 	Use:                "dfbench",
@@ -36,15 +43,34 @@ var rootCmd = &cobra.Command{
 	DisableAutoGenTag:  true,
 	SilenceUsage:       true,
 	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
-	RunE: func(cmd *cobra.Command, args []string) error {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		logrus.Debug("dfbench is running")
 
+		// Load the config file and the environment variables, the flags take precedence.
+		if err := loadConfig(cmd); err != nil {
+			logrus.Errorf("failed to load config: %v", err)
+			return err
+		}
+
 		// Set the configured log level
 		if level, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
 			logrus.SetLevel(level)
 		}
 		logrus.Debug("dfbench log initialized")
 
+		// Validate the config before touching the cluster.
+		if err := cfg.Validate(); err != nil {
+			logrus.Errorf("invalid config: %v", err)
+			return err
+		}
+
+		// The columns are defined by the stats package, so they are validated here rather than by
+		// the config to fail on a typo before the benchmark runs.
+		if err := stats.ValidateColumns(cfg.Report.Columns); err != nil {
+			logrus.Errorf("invalid config: %v", err)
+			return err
+		}
+
 		// Set the kubeconfig if it is provided.
 		if cfg.KubeConfig != "" {
 			os.Setenv("KUBECONFIG", cfg.KubeConfig)
@@ -65,9 +91,13 @@ func Execute() {
 func init() {
 	// Bind more cache specific persistent flags.
 	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&cfgFile, "config", cfgFile, fmt.Sprintf("Specify the path to the dfbench config file in YAML format, the keys can be overridden by the environment variables prefixed with %s_, e.g. %s_DRAGONFLY_NUMBER", config.EnvPrefix, config.EnvPrefix))
 	flags.StringVar(&cfg.KubeConfig, "kubeconfig", cfg.KubeConfig, "Specify the path to the kubeconfig file")
 	flags.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "Specify the timeout for benchmarking")
 	flags.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Specify the log level [debug, info, warn, error, fatal, panic], default is info")
+	flags.StringVar(&cfg.Report.Format, "output-format", cfg.Report.Format, "Specify the output format of the benchmark report [table, json, csv, markdown], default is table")
+	flags.StringVar(&cfg.Report.File, "output-file", cfg.Report.File, "Specify the file to write the benchmark report, default is stdout")
+	flags.StringSliceVar(&cfg.Report.Columns, "columns", cfg.Report.Columns, fmt.Sprintf("Specify the columns of the statistics table [%s], \"all\" selects all columns, default is %s", strings.Join(stats.ColumnNames(), ", "), strings.Join(stats.DefaultColumns, ",")))
 
 	// Bind common flags.
 	if err := viper.BindPFlags(flags); err != nil {
@@ -77,4 +107,48 @@ func init() {
 	// Add sub command.
 	rootCmd.AddCommand(dragonflyCmd)
 	rootCmd.AddCommand(nydusCmd)
+	rootCmd.AddCommand(imageCmd)
+	rootCmd.AddCommand(proxyLoadCmd)
+	rootCmd.AddCommand(fileServerCmd)
+	rootCmd.AddCommand(compareCmd)
+}
+
+// loadConfig loads the config file and the environment variables into the config, the flags
+// set on the command line are applied again afterwards to take precedence over them.
+func loadConfig(cmd *cobra.Command) error {
+	type changedFlag struct {
+		flag  *pflag.Flag
+		value string
+		slice []string
+	}
+
+	var changed []changedFlag
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if sliceValue, ok := f.Value.(pflag.SliceValue); ok {
+			changed = append(changed, changedFlag{flag: f, slice: sliceValue.GetSlice()})
+			return
+		}
+
+		changed = append(changed, changedFlag{flag: f, value: f.Value.String()})
+	})
+
+	if err := cfg.Load(cfgFile); err != nil {
+		return err
+	}
+
+	for _, c := range changed {
+		if sliceValue, ok := c.flag.Value.(pflag.SliceValue); ok {
+			if err := sliceValue.Replace(c.slice); err != nil {
+				return fmt.Errorf("failed to apply flag --%s: %w", c.flag.Name, err)
+			}
+
+			continue
+		}
+
+		if err := c.flag.Value.Set(c.value); err != nil {
+			return fmt.Errorf("failed to apply flag --%s: %w", c.flag.Name, err)
+		}
+	}
+
+	return nil
 }
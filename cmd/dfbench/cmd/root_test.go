@@ -0,0 +1,102 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	defer func(c config.Config, f string) {
+		*cfg = c
+		cfgFile = f
+	}(*cfg, cfgFile)
+	*cfg = *config.New()
+
+	cfgFile = filepath.Join(t.TempDir(), "dfbench.yaml")
+	if err := os.WriteFile(cfgFile, []byte(`dragonfly:
+  number: 3
+  cooldown: 1s
+  namespace: from-file
+  endpoints: ["proxy=127.0.0.1:6001"]
+`), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	t.Setenv("DFBENCH_DRAGONFLY_NUMBER", "4")
+	t.Setenv("DFBENCH_DRAGONFLY_COOLDOWN", "2s")
+
+	// The flags are bound to the config like the flags of the commands.
+	cmd := &cobra.Command{}
+	flags := cmd.Flags()
+	flags.Uint32Var(&cfg.Dragonfly.Number, "number", cfg.Dragonfly.Number, "")
+	flags.DurationVar(&cfg.Dragonfly.Cooldown, "cooldown", cfg.Dragonfly.Cooldown, "")
+	flags.StringVar(&cfg.Dragonfly.Namespace, "namespace", cfg.Dragonfly.Namespace, "")
+	flags.StringArrayVar(&cfg.Dragonfly.Endpoints, "endpoint", cfg.Dragonfly.Endpoints, "")
+	if err := cmd.ParseFlags([]string{"--number", "5", "--endpoint", "proxy=127.0.0.1:5001", "--endpoint", "proxy=127.0.0.1:5002"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	if err := loadConfig(cmd); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	// The flag takes precedence over the env and the file.
+	if cfg.Dragonfly.Number != 5 {
+		t.Errorf("number = %d, want 5 of the flag", cfg.Dragonfly.Number)
+	}
+
+	if expected := []string{"proxy=127.0.0.1:5001", "proxy=127.0.0.1:5002"}; !slices.Equal(cfg.Dragonfly.Endpoints, expected) {
+		t.Errorf("endpoints = %v, want %v of the flags", cfg.Dragonfly.Endpoints, expected)
+	}
+
+	// The env takes precedence over the file.
+	if cfg.Dragonfly.Cooldown != 2*time.Second {
+		t.Errorf("cooldown = %v, want 2s of the env", cfg.Dragonfly.Cooldown)
+	}
+
+	if cfg.Dragonfly.Namespace != "from-file" {
+		t.Errorf("namespace = %q, want %q of the file", cfg.Dragonfly.Namespace, "from-file")
+	}
+
+	if cfg.Dragonfly.Downloader != config.DownloaderDfget {
+		t.Errorf("downloader = %q, want the default %q", cfg.Dragonfly.Downloader, config.DownloaderDfget)
+	}
+}
+
+func TestLoadConfigUnknownKey(t *testing.T) {
+	defer func(c config.Config, f string) {
+		*cfg = c
+		cfgFile = f
+	}(*cfg, cfgFile)
+
+	cfgFile = filepath.Join(t.TempDir(), "dfbench.yaml")
+	if err := os.WriteFile(cfgFile, []byte("dragonfly:\n  numbr: 3\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	if err := loadConfig(&cobra.Command{}); err == nil {
+		t.Error("loadConfig of unknown key succeeded, want error")
+	}
+}
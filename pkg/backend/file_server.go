@@ -17,10 +17,16 @@
 package backend
 
 import (
+	"errors"
 	"fmt"
+	"math/bits"
 	"net/url"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/google/uuid"
 )
 
@@ -43,29 +49,106 @@ func (f FileSizeLevel) String() string {
 	case FileSizeLevelXXLarge:
 		return "XXLarge(30GB)"
 	default:
+		if f.Bytes() > 0 {
+			return string(f)
+		}
+
 		return "Unknow"
 	}
 }
 
+// TaskSizeLevel returns the task size level of the file in the dfdaemon metrics, the levels are the
+// size ranges [0, 1MiB), [1MiB, 4MiB), [4MiB, 8MiB) and so on doubling up to 1GiB, [1GiB, 4GiB), then
+// doubling again up to 1TiB, and the sizes from 1TiB.
 func (f FileSizeLevel) TaskSizeLevel() string {
+	size := f.Bytes()
+	switch {
+	case size == 0:
+		return "0"
+	case size < 1<<20:
+		return "1"
+	case size < 1<<30:
+		// [1MiB, 4MiB) is level 2, and every doubling from 4MiB adds a level up to level 10 of [512MiB, 1GiB).
+		return strconv.Itoa(max(2, bits.Len64(size>>20)))
+	case size < 4<<30:
+		return "11"
+	case size < 1<<40:
+		// Every doubling from 4GiB adds a level up to level 19 of [512GiB, 1TiB).
+		return strconv.Itoa(bits.Len64(size>>30) + 9)
+	default:
+		return "20"
+	}
+}
+
+// Bytes returns the size of the file in bytes, the size of the custom file size level is parsed
+// from its label, and 0 is returned for an unknown level.
+func (f FileSizeLevel) Bytes() uint64 {
 	switch f {
 	case FileSizeLevelNano:
-		return "1"
+		return 1
 	case FileSizeLevelMicro:
-		return "1"
+		// The micro file on the file server is 10KiB.
+		return 10 << 10
 	case FileSizeLevelSmall:
-		return "2"
+		return 1 << 20
 	case FileSizeLevelMedium:
-		return "4"
+		return 10 << 20
 	case FileSizeLevelLarge:
-		return "11"
+		return 1 << 30
 	case FileSizeLevelXLarge:
-		return "13"
+		return 10 << 30
 	case FileSizeLevelXXLarge:
-		return "14"
+		return 30 << 30
 	default:
-		return "unknown"
+		size, err := humanize.ParseBytes(string(f))
+		if err != nil {
+			return 0
+		}
+
+		return size
+	}
+}
+
+// Path returns the path of the file on the file server, the files of the custom file size levels
+// are generated on the fly under /size by the size in bytes, e.g. /size/268435456.
+func (f FileSizeLevel) Path() string {
+	if slices.Contains(FileSizeLevels, f) {
+		return string(f)
+	}
+
+	return path.Join("size", strconv.FormatUint(f.Bytes(), 10))
+}
+
+// ParseFileSizeLevel parses the file size level by name, e.g. small, or the custom file size level
+// of any size, e.g. 256MiB or 3GiB, the label of the custom file size level is the size in the
+// largest unit dividing it, so the same sizes are the same level.
+func ParseFileSizeLevel(s string) (FileSizeLevel, error) {
+	if level := FileSizeLevel(strings.ToLower(s)); slices.Contains(FileSizeLevels, level) {
+		return level, nil
+	}
+
+	size, err := humanize.ParseBytes(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid file size %q: %w", s, err)
+	}
+
+	if size == 0 {
+		return "", fmt.Errorf("invalid file size %q: must be greater than 0", s)
+	}
+
+	for _, unit := range []struct {
+		name string
+		size uint64
+	}{
+		{"TiB", 1 << 40}, {"TB", 1e12}, {"GiB", 1 << 30}, {"GB", 1e9},
+		{"MiB", 1 << 20}, {"MB", 1e6}, {"KiB", 1 << 10}, {"KB", 1e3},
+	} {
+		if size%unit.size == 0 {
+			return FileSizeLevel(fmt.Sprintf("%d%s", size/unit.size, unit.name)), nil
+		}
 	}
+
+	return FileSizeLevel(fmt.Sprintf("%dB", size)), nil
 }
 
 const (
@@ -88,31 +171,130 @@ var FileSizeLevels = []FileSizeLevel{
 	FileSizeLevelXXLarge,
 }
 
+// DigestPath is the path prefix of the digests of the files on the file server, e.g. /digest/small.
+const DigestPath = "digest"
+
+// Digest represents the digest of a file or a byte range of a file advertised by the file server.
+type Digest struct {
+	// Size is the size of the content in bytes.
+	Size uint64 `json:"size"`
+
+	// Digest is the digest of the content in the format of <algorithm>:<hex>, e.g. sha256:<hex>.
+	Digest string `json:"digest"`
+}
+
+// StatsPath is the path of the traffic served by the file server by task, e.g. /stats?tag=dfget, the
+// tasks are filtered by the uuid query parameters, and removed after they are read by the DELETE method.
+const StatsPath = "stats"
+
+// TaskTraffic represents the traffic served by the file server for a task, the task is identified by
+// the path and the tag and uuid query parameters of the file URL.
+type TaskTraffic struct {
+	// Path is the path of the file.
+	Path string `json:"path"`
+
+	// Tag is the tag query parameter of the file URL.
+	Tag string `json:"tag"`
+
+	// UUID is the uuid query parameter of the file URL.
+	UUID string `json:"uuid"`
+
+	// Requests is the number of the requests.
+	Requests uint64 `json:"requests"`
+
+	// Bytes is the bytes of the bodies sent.
+	Bytes uint64 `json:"bytes"`
+}
+
 type FileServer interface {
 	GetFileURL(FileSizeLevel, string) (*url.URL, error)
+
+	// GetFaultyFileURL returns the URL of a new task of the file like GetFileURL, the responses of the
+	// task are injected with the fault of the origin.
+	GetFaultyFileURL(FileSizeLevel, string) (*url.URL, error)
+
+	// GetDigestURL returns the URL of the digest of the file of the file size level, the digest
+	// of a byte range is returned by the Range header.
+	GetDigestURL(FileSizeLevel) (*url.URL, error)
+
+	// GetStatsURL returns the URL of the traffic served by the file server for the tasks of the tag and
+	// uuids, all tasks of the tag without uuids.
+	GetStatsURL(string, []string) (*url.URL, error)
 }
 
 type fileServer struct {
-	namespace string
+	baseURL string
+
+	// origin is the behavior of the file server set by the query parameters of the file URLs, nil
+	// to serve the files as fast as possible.
+	origin *Origin
+}
+
+func NewFileServer(namespace string, origin *Origin) FileServer {
+	return &fileServer{fmt.Sprintf("http://file-server.%s.svc", namespace), origin}
 }
 
-func NewFileServer(namespace string) FileServer {
-	return &fileServer{namespace}
+// NewFileServerWithBaseURL creates a file server serving files under the base URL,
+// e.g. a file server running on the local machine.
+func NewFileServerWithBaseURL(baseURL string, origin *Origin) FileServer {
+	return &fileServer{baseURL, origin}
 }
 
 func (f *fileServer) GetFileURL(fileSizeLevel FileSizeLevel, tag string) (*url.URL, error) {
-	baseURL := fmt.Sprintf("http://file-server.%s.svc", f.namespace)
+	return f.getFileURL(fileSizeLevel, tag, false)
+}
+
+// GetFaultyFileURL returns the URL of a new task of the file with the fault of the origin.
+func (f *fileServer) GetFaultyFileURL(fileSizeLevel FileSizeLevel, tag string) (*url.URL, error) {
+	if f.origin == nil || f.origin.Fault == nil {
+		return nil, errors.New("no fault of the origin")
+	}
+
+	return f.getFileURL(fileSizeLevel, tag, true)
+}
 
-	u, err := url.Parse(baseURL)
+// getFileURL returns the URL of a new task of the file, the fault of the origin is set if faulty is true.
+func (f *fileServer) getFileURL(fileSizeLevel FileSizeLevel, tag string, faulty bool) (*url.URL, error) {
+	u, err := url.Parse(f.baseURL)
 	if err != nil {
 		return nil, err
 	}
-	u.Path = path.Join(u.Path, string(fileSizeLevel))
+	u.Path = path.Join(u.Path, fileSizeLevel.Path())
 
 	// Add tag query parameter.
 	query := u.Query()
 	query.Set("tag", tag)
 	query.Set("uuid", uuid.New().String())
+	if f.origin != nil {
+		f.origin.Encode(query)
+		if faulty {
+			f.origin.Fault.Encode(query)
+		}
+	}
 	u.RawQuery = query.Encode()
 	return u, nil
 }
+
+// GetDigestURL returns the URL of the digest of the file of the file size level, e.g. /digest/small.
+func (f *fileServer) GetDigestURL(fileSizeLevel FileSizeLevel) (*url.URL, error) {
+	u, err := url.Parse(f.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, DigestPath, fileSizeLevel.Path())
+	return u, nil
+}
+
+// GetStatsURL returns the URL of the traffic served for the tasks of the tag and uuids,
+// e.g. /stats?tag=dfget&uuid=<uuid>.
+func (f *fileServer) GetStatsURL(tag string, uuids []string) (*url.URL, error) {
+	u, err := url.Parse(f.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, StatsPath)
+	u.RawQuery = url.Values{"tag": []string{tag}, "uuid": uuids}.Encode()
+	return u, nil
+}
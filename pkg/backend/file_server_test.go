@@ -0,0 +1,136 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package backend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFileSizeLevel(t *testing.T) {
+	tests := []struct {
+		size    string
+		level   FileSizeLevel
+		bytes   uint64
+		path    string
+		wantErr string
+	}{
+		{size: "small", level: FileSizeLevelSmall, bytes: 1 << 20, path: "small"},
+		{size: "XLarge", level: FileSizeLevelXLarge, bytes: 10 << 30, path: "xlarge"},
+		{size: "256MiB", level: "256MiB", bytes: 256 << 20, path: "size/268435456"},
+		{size: "268435456", level: "256MiB", bytes: 256 << 20, path: "size/268435456"},
+		{size: "3gib", level: "3GiB", bytes: 3 << 30, path: "size/3221225472"},
+		{size: "1024KiB", level: "1MiB", bytes: 1 << 20, path: "size/1048576"},
+		{size: "1.5GB", level: "1500MB", bytes: 1500e6, path: "size/1500000000"},
+		{size: "2TiB", level: "2TiB", bytes: 2 << 40, path: "size/2199023255552"},
+		{size: "1000", level: "1KB", bytes: 1000, path: "size/1000"},
+		{size: "1", level: "1B", bytes: 1, path: "size/1"},
+		{size: "1025", level: "1025B", bytes: 1025, path: "size/1025"},
+		{size: "0", wantErr: "must be greater than 0"},
+		{size: "0KiB", wantErr: "must be greater than 0"},
+		{size: "-1MiB", wantErr: "invalid file size"},
+		{size: "1XB", wantErr: "invalid file size"},
+		{size: "huge", wantErr: "invalid file size"},
+		{size: "20000000000000000000000", wantErr: "invalid file size"},
+		{size: "100000EiB", wantErr: "invalid file size"},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseFileSizeLevel(tt.size)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFileSizeLevel(%q) = %q, %v, want error %q", tt.size, level, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseFileSizeLevel(%q) failed: %v", tt.size, err)
+			continue
+		}
+
+		if level != tt.level || level.Bytes() != tt.bytes || level.Path() != tt.path {
+			t.Errorf("ParseFileSizeLevel(%q) = %q of %d bytes at %q, want %q of %d bytes at %q", tt.size, level, level.Bytes(), level.Path(), tt.level, tt.bytes, tt.path)
+		}
+	}
+}
+
+func TestTaskSizeLevel(t *testing.T) {
+	tests := []struct {
+		level FileSizeLevel
+		task  string
+	}{
+		{level: "unknown", task: "0"},
+		{level: FileSizeLevelNano, task: "1"},
+		{level: FileSizeLevelMicro, task: "1"},
+		{level: "1048575B", task: "1"},
+		{level: FileSizeLevelSmall, task: "2"},
+		{level: "4194303B", task: "2"},
+		{level: "4MiB", task: "3"},
+		{level: FileSizeLevelMedium, task: "4"},
+		{level: "536870911B", task: "9"},
+		{level: "512MiB", task: "10"},
+		{level: "1073741823B", task: "10"},
+		{level: FileSizeLevelLarge, task: "11"},
+		{level: "4294967295B", task: "11"},
+		{level: "4GiB", task: "12"},
+		{level: FileSizeLevelXLarge, task: "13"},
+		{level: FileSizeLevelXXLarge, task: "14"},
+		{level: "512GiB", task: "19"},
+		{level: "1099511627775B", task: "19"},
+		{level: "1TiB", task: "20"},
+		{level: "100TiB", task: "20"},
+	}
+
+	for _, tt := range tests {
+		if task := tt.level.TaskSizeLevel(); task != tt.task {
+			t.Errorf("%s of %d bytes has task size level %s, want %s", tt.level, tt.level.Bytes(), task, tt.task)
+		}
+	}
+}
+
+func TestGetStatsURL(t *testing.T) {
+	fileServer := NewFileServerWithBaseURL("http://127.0.0.1:8080/files", nil)
+	tests := []struct {
+		name     string
+		uuids    []string
+		expected string
+	}{
+		{
+			name:     "all tasks of the tag",
+			expected: "http://127.0.0.1:8080/files/stats?tag=dfget",
+		},
+		{
+			name:     "tasks of the uuids",
+			uuids:    []string{"b", "a"},
+			expected: "http://127.0.0.1:8080/files/stats?tag=dfget&uuid=b&uuid=a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := fileServer.GetStatsURL("dfget", tt.uuids)
+			if err != nil {
+				t.Fatalf("GetStatsURL() error = %v", err)
+			}
+
+			if u.String() != tt.expected {
+				t.Errorf("GetStatsURL() = %s, want %s", u, tt.expected)
+			}
+		})
+	}
+}
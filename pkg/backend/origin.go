@@ -0,0 +1,261 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	// QueryBandwidth is the query parameter of the bandwidth cap of every connection in bytes per second.
+	QueryBandwidth = "bandwidth"
+
+	// QueryAggregateBandwidth is the query parameter of the bandwidth cap in bytes per second shared by
+	// all connections requesting the files with the same cap.
+	QueryAggregateBandwidth = "aggregate_bandwidth"
+
+	// QueryTTFB is the query parameter of the delay before the first byte of the response.
+	QueryTTFB = "ttfb"
+
+	// QueryTTFBJitter is the query parameter of the maximum random deviation of the delay before the first byte.
+	QueryTTFBJitter = "ttfb_jitter"
+
+	// QueryFault is the query parameter of the fault profile of the response.
+	QueryFault = "fault"
+
+	// QueryFaultRate is the query parameter of the fraction of the requests the fault is injected into.
+	QueryFaultRate = "fault_rate"
+
+	// QueryFaultAfter is the query parameter of the bytes of the body sent before the fault.
+	QueryFaultAfter = "fault_after"
+
+	// QueryFaultStall is the query parameter of the duration of the stall.
+	QueryFaultStall = "fault_stall"
+
+	// QueryFaultStatus is the query parameter of the status code of the error.
+	QueryFaultStatus = "fault_status"
+)
+
+const (
+	// FaultError responds the error status code instead of the file.
+	FaultError = "error"
+
+	// FaultReset resets the connection after the bytes of the body are sent.
+	FaultReset = "reset"
+
+	// FaultStall stalls the response for the stall duration after the bytes of the body are sent.
+	FaultStall = "stall"
+
+	// FaultTruncate declares the Content-Length of the whole response but closes the connection after
+	// the bytes of the body are sent, so the Content-Length is wrong.
+	FaultTruncate = "truncate"
+)
+
+// Faults is all fault profiles of the file server.
+var Faults = []string{FaultError, FaultReset, FaultStall, FaultTruncate}
+
+// Origin represents the behavior of the file server emulating a slow origin, e.g. an object storage
+// or a cross-region origin. It is set by the query parameters of the file URLs, so every task can
+// be served differently by the same file server.
+type Origin struct {
+	// Bandwidth is the bandwidth cap of every connection in bytes per second, 0 is unlimited.
+	Bandwidth uint64
+
+	// AggregateBandwidth is the bandwidth cap in bytes per second shared by all connections
+	// requesting the files with the same cap, 0 is unlimited.
+	AggregateBandwidth uint64
+
+	// TTFB is the delay before the first byte of the response.
+	TTFB time.Duration
+
+	// TTFBJitter is the maximum random deviation of the delay before the first byte, the delay is
+	// uniformly distributed in [TTFB - TTFBJitter, TTFB + TTFBJitter] and at least 0.
+	TTFBJitter time.Duration
+
+	// Fault is the fault injected into the responses of the faulty file URLs, nil if no fault is injected.
+	Fault *Fault
+}
+
+// Fault represents a fault injected into the responses of the file server to emulate a flaky origin.
+type Fault struct {
+	// Profile is the fault profile [error, reset, stall, truncate].
+	Profile string
+
+	// Rate is the fraction of the requests the fault is injected into in (0, 1], so the retries of the
+	// failed requests can recover.
+	Rate float64
+
+	// After is the bytes of the body sent before the connection is reset, stalled or closed.
+	After uint64
+
+	// Stall is the duration of the stall of the stall profile.
+	Stall time.Duration
+
+	// Status is the status code of the error profile.
+	Status int
+}
+
+// Encode sets the query parameters of the fault.
+func (f *Fault) Encode(query url.Values) {
+	query.Set(QueryFault, f.Profile)
+	query.Set(QueryFaultRate, strconv.FormatFloat(f.Rate, 'f', -1, 64))
+	switch f.Profile {
+	case FaultError:
+		query.Set(QueryFaultStatus, strconv.Itoa(f.Status))
+	case FaultStall:
+		query.Set(QueryFaultAfter, strconv.FormatUint(f.After, 10))
+		query.Set(QueryFaultStall, f.Stall.String())
+	default:
+		query.Set(QueryFaultAfter, strconv.FormatUint(f.After, 10))
+	}
+}
+
+// Validate validates the fault.
+func (f *Fault) Validate() error {
+	if !slices.Contains(Faults, f.Profile) {
+		return fmt.Errorf("invalid fault %q, must be one of %v", f.Profile, Faults)
+	}
+
+	if f.Rate <= 0 || f.Rate > 1 {
+		return fmt.Errorf("invalid fault rate %v, must be in (0, 1]", f.Rate)
+	}
+
+	if f.Profile == FaultError && (f.Status < 500 || f.Status > 599) {
+		return fmt.Errorf("invalid fault status %d, must be 5xx", f.Status)
+	}
+
+	if f.Profile == FaultStall && f.Stall <= 0 {
+		return errors.New("fault stall must be greater than 0")
+	}
+
+	return nil
+}
+
+// Encode sets the query parameters of the origin except the fault, nothing is set for the zero values.
+func (o *Origin) Encode(query url.Values) {
+	if o.Bandwidth > 0 {
+		query.Set(QueryBandwidth, strconv.FormatUint(o.Bandwidth, 10))
+	}
+
+	if o.AggregateBandwidth > 0 {
+		query.Set(QueryAggregateBandwidth, strconv.FormatUint(o.AggregateBandwidth, 10))
+	}
+
+	if o.TTFB > 0 {
+		query.Set(QueryTTFB, o.TTFB.String())
+	}
+
+	if o.TTFBJitter > 0 {
+		query.Set(QueryTTFBJitter, o.TTFBJitter.String())
+	}
+}
+
+// ParseOrigin parses the origin from the query parameters of the file URL, the fault is parsed only if
+// the fault profile is set.
+func ParseOrigin(query url.Values) (*Origin, error) {
+	origin := &Origin{}
+	for _, param := range []struct {
+		name  string
+		value *uint64
+	}{
+		{QueryBandwidth, &origin.Bandwidth},
+		{QueryAggregateBandwidth, &origin.AggregateBandwidth},
+	} {
+		if s := query.Get(param.name); s != "" {
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", param.name, s, err)
+			}
+
+			*param.value = v
+		}
+	}
+
+	for _, param := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{QueryTTFB, &origin.TTFB},
+		{QueryTTFBJitter, &origin.TTFBJitter},
+	} {
+		if s := query.Get(param.name); s != "" {
+			v, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", param.name, s, err)
+			}
+
+			if v < 0 {
+				return nil, fmt.Errorf("invalid %s %q: must not be negative", param.name, s)
+			}
+
+			*param.value = v
+		}
+	}
+
+	if query.Get(QueryFault) == "" {
+		return origin, nil
+	}
+
+	fault := &Fault{Profile: query.Get(QueryFault), Rate: 1, Status: 503}
+	if s := query.Get(QueryFaultRate); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", QueryFaultRate, s, err)
+		}
+
+		fault.Rate = v
+	}
+
+	if s := query.Get(QueryFaultAfter); s != "" {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", QueryFaultAfter, s, err)
+		}
+
+		fault.After = v
+	}
+
+	if s := query.Get(QueryFaultStall); s != "" {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", QueryFaultStall, s, err)
+		}
+
+		fault.Stall = v
+	}
+
+	if s := query.Get(QueryFaultStatus); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", QueryFaultStatus, s, err)
+		}
+
+		fault.Status = v
+	}
+
+	if err := fault.Validate(); err != nil {
+		return nil, err
+	}
+
+	origin.Fault = fault
+	return origin, nil
+}
@@ -0,0 +1,251 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package backend
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected Origin
+		wantErr  bool
+	}{
+		{
+			name:  "unlimited",
+			query: "tag=dfget&uuid=1",
+		},
+		{
+			name:     "bandwidth caps",
+			query:    "bandwidth=1048576&aggregate_bandwidth=10485760",
+			expected: Origin{Bandwidth: 1 << 20, AggregateBandwidth: 10 << 20},
+		},
+		{
+			name:     "ttfb with jitter",
+			query:    "ttfb=200ms&ttfb_jitter=50ms",
+			expected: Origin{TTFB: 200 * time.Millisecond, TTFBJitter: 50 * time.Millisecond},
+		},
+		{
+			name:    "invalid bandwidth",
+			query:   "bandwidth=10MiB",
+			wantErr: true,
+		},
+		{
+			name:    "negative bandwidth",
+			query:   "aggregate_bandwidth=-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid ttfb",
+			query:   "ttfb=200",
+			wantErr: true,
+		},
+		{
+			name:    "negative ttfb jitter",
+			query:   "ttfb_jitter=-1s",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid query %q: %v", tt.query, err)
+			}
+
+			origin, err := ParseOrigin(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseOrigin(%q) = %+v, want error", tt.query, origin)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseOrigin(%q) failed: %v", tt.query, err)
+			}
+
+			if *origin != tt.expected {
+				t.Errorf("ParseOrigin(%q) = %+v, want %+v", tt.query, *origin, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOriginEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		origin   Origin
+		expected string
+	}{
+		{
+			name: "unlimited",
+		},
+		{
+			name:     "all parameters",
+			origin:   Origin{Bandwidth: 1 << 20, AggregateBandwidth: 10 << 20, TTFB: 200 * time.Millisecond, TTFBJitter: 50 * time.Millisecond},
+			expected: "aggregate_bandwidth=10485760&bandwidth=1048576&ttfb=200ms&ttfb_jitter=50ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			tt.origin.Encode(query)
+			if query.Encode() != tt.expected {
+				t.Fatalf("Encode = %q, want %q", query.Encode(), tt.expected)
+			}
+
+			origin, err := ParseOrigin(query)
+			if err != nil {
+				t.Fatalf("ParseOrigin(%q) failed: %v", query.Encode(), err)
+			}
+
+			if *origin != tt.origin {
+				t.Errorf("ParseOrigin(%q) = %+v, want %+v", query.Encode(), *origin, tt.origin)
+			}
+		})
+	}
+}
+
+func TestFaultValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   Fault
+		wantErr bool
+	}{
+		{name: "error", fault: Fault{Profile: FaultError, Rate: 0.5, Status: 503}},
+		{name: "reset", fault: Fault{Profile: FaultReset, Rate: 1, After: 1024}},
+		{name: "stall", fault: Fault{Profile: FaultStall, Rate: 0.1, Stall: time.Second}},
+		{name: "truncate", fault: Fault{Profile: FaultTruncate, Rate: 1}},
+		{name: "unknown profile", fault: Fault{Profile: "timeout", Rate: 1}, wantErr: true},
+		{name: "zero rate", fault: Fault{Profile: FaultReset}, wantErr: true},
+		{name: "rate above 1", fault: Fault{Profile: FaultReset, Rate: 1.5}, wantErr: true},
+		{name: "non-5xx status", fault: Fault{Profile: FaultError, Rate: 1, Status: 404}, wantErr: true},
+		{name: "zero stall", fault: Fault{Profile: FaultStall, Rate: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fault.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOriginFault(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected *Fault
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			query:    "fault=truncate",
+			expected: &Fault{Profile: FaultTruncate, Rate: 1, Status: 503},
+		},
+		{
+			name:     "error with status",
+			query:    "fault=error&fault_rate=0.5&fault_status=502",
+			expected: &Fault{Profile: FaultError, Rate: 0.5, Status: 502},
+		},
+		{
+			name:     "stall after bytes",
+			query:    "fault=stall&fault_rate=0.25&fault_after=4096&fault_stall=3s",
+			expected: &Fault{Profile: FaultStall, Rate: 0.25, After: 4096, Stall: 3 * time.Second, Status: 503},
+		},
+		{
+			name:    "invalid fault",
+			query:   "fault=timeout",
+			wantErr: true,
+		},
+		{
+			name:    "invalid rate",
+			query:   "fault=reset&fault_rate=half",
+			wantErr: true,
+		},
+		{
+			name:    "invalid after",
+			query:   "fault=reset&fault_after=-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid stall",
+			query:   "fault=stall&fault_stall=3",
+			wantErr: true,
+		},
+		{
+			name:    "invalid status",
+			query:   "fault=error&fault_status=404",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid query %q: %v", tt.query, err)
+			}
+
+			origin, err := ParseOrigin(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseOrigin(%q) = %+v, want error", tt.query, origin)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseOrigin(%q) failed: %v", tt.query, err)
+			}
+
+			if origin.Fault == nil || *origin.Fault != *tt.expected {
+				t.Errorf("ParseOrigin(%q) fault = %+v, want %+v", tt.query, origin.Fault, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFaultEncode(t *testing.T) {
+	for _, fault := range []Fault{
+		{Profile: FaultError, Rate: 0.5, Status: 502},
+		{Profile: FaultReset, Rate: 1, After: 1024, Status: 503},
+		{Profile: FaultStall, Rate: 0.1, After: 10, Stall: 2 * time.Second, Status: 503},
+		{Profile: FaultTruncate, Rate: 0.75, After: 65536, Status: 503},
+	} {
+		t.Run(fault.Profile, func(t *testing.T) {
+			query := url.Values{}
+			fault.Encode(query)
+			origin, err := ParseOrigin(query)
+			if err != nil {
+				t.Fatalf("ParseOrigin(%q) failed: %v", query.Encode(), err)
+			}
+
+			if origin.Fault == nil || *origin.Fault != fault {
+				t.Errorf("ParseOrigin(%q) fault = %+v, want %+v", query.Encode(), origin.Fault, fault)
+			}
+		})
+	}
+}
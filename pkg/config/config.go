@@ -18,57 +18,518 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
+// EnvPrefix is the prefix of the environment variables overriding the configuration,
+// the nested keys are joined by underscores, e.g. DFBENCH_DRAGONFLY_NUMBER.
+const EnvPrefix = "DFBENCH"
+
 const (
 	// DownloaderDfget is the dfget downloader.
 	DownloaderDfget = "dfget"
 
 	// DownloaderProxy is the proxy downloader.
 	DownloaderProxy = "proxy"
+
+	// DownloaderDfcache is the dfcache downloader, the file is imported into the cache in the first
+	// client pod and exported in all client pods.
+	DownloaderDfcache = "dfcache"
+
+	// DownloaderDfstore is the dfstore downloader, the file is put into the object storage in the first
+	// client pod and copied from the object storage in all client pods.
+	DownloaderDfstore = "dfstore"
+
+	// DownloaderDirect is the downloader fetching the file from the file server directly without
+	// the dfdaemon, as the control group of the P2P downloaders.
+	DownloaderDirect = "direct"
+)
+
+const (
+	// LoadModeRepeat requests the same URL repeatedly, every request hits the same task.
+	LoadModeRepeat = "repeat"
+
+	// LoadModeRandom appends a unique query string to every request, so every request is a new task.
+	LoadModeRandom = "random"
+
+	// LoadModeSequential reads the file from head to tail in ranged requests of the chunk size by several
+	// streams in turn, every stream moves to the next task of a fixed pool after a complete pass.
+	LoadModeSequential = "sequential"
+)
+
+const (
+	// OutputFormatTable is the ASCII table output format.
+	OutputFormatTable = "table"
+
+	// OutputFormatJSON is the JSON output format.
+	OutputFormatJSON = "json"
+
+	// OutputFormatCSV is the CSV output format.
+	OutputFormatCSV = "csv"
+
+	// OutputFormatMarkdown is the markdown table output format.
+	OutputFormatMarkdown = "markdown"
+)
+
+const (
+	// ExecutorKubernetes is the executor running commands in the client pods by the kubernetes API.
+	ExecutorKubernetes = "kubernetes"
+
+	// ExecutorLocal is the executor running commands on the local machine.
+	ExecutorLocal = "local"
+)
+
+const (
+	// StartPatternAllAtOnce starts the downloads in all pods at the same time.
+	StartPatternAllAtOnce = "all-at-once"
+
+	// StartPatternRamp starts the waves of pods linearly over the ramp duration without waiting for the previous waves.
+	StartPatternRamp = "ramp"
+
+	// StartPatternWaves starts the waves of pods one after another when the previous wave is finished.
+	StartPatternWaves = "waves"
+
+	// StartPatternSeedFirst starts the download in the first pod, then in the rest pods when it is finished.
+	StartPatternSeedFirst = "seed-first"
+)
+
+const (
+	// CacheCold downloads a new task in every iteration.
+	CacheCold = "cold"
+
+	// CacheHot reuses the tasks of the first iteration in the later iterations, so they are
+	// served from the local and remote peers.
+	CacheHot = "hot"
+)
+
+const (
+	// RangeModeFull downloads the whole file by every request.
+	RangeModeFull = "full"
+
+	// RangeModeFixed downloads the fixed byte range of the file by every request.
+	RangeModeFixed = "fixed"
+
+	// RangeModeRandom downloads a byte range of the chunk size at a random offset of the file by every request.
+	RangeModeRandom = "random"
+
+	// RangeModeSequential reads the file from head to tail in ranged requests of the chunk size, the
+	// tasks are taken from a fixed pool in turn, so the same tasks are read repeatedly.
+	RangeModeSequential = "sequential"
+)
+
+const (
+	// PullerCtr pulls the images by ctr with the registry hosts directory, the pull time of every layer is reported.
+	PullerCtr = "ctr"
+
+	// PullerCrictl pulls the images by crictl through the CRI, only the total pull time is reported.
+	PullerCrictl = "crictl"
 )
 
+// byteRangeRegexp matches the byte range of the fixed range mode with the optional unit.
+var byteRangeRegexp = regexp.MustCompile(`^(bytes=)?[0-9]+-[0-9]*$`)
+
 // Config is the root configuration for dfbench.
 type Config struct {
 	// KubeConfig is the path to the kubeconfig file.
-	KubeConfig string `yaml:"kubeconfig,omitempty" mapstructure:"kubeconfig,omitempty"`
+	KubeConfig string `yaml:"kubeconfig,omitempty" mapstructure:"kubeconfig,omitempty" json:"kubeconfig,omitempty"`
 
 	// Timeout specifies the timeout for benchmarking
-	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout,omitempty" json:"timeout,omitempty"`
 
 	// LogLevel is the level with to log for this config
-	LogLevel string `yaml:"log_level,omitempty" mapstructure:"log_level,omitempty"`
+	LogLevel string `yaml:"log_level,omitempty" mapstructure:"log_level,omitempty" json:"log_level,omitempty"`
 
 	// Dragonfly is the configuration for benchmarking dragonfly.
-	Dragonfly DragonflyConfig `yaml:"dragonfly,omitempty" mapstructure:"dragonfly,omitempty"`
+	Dragonfly DragonflyConfig `yaml:"dragonfly,omitempty" mapstructure:"dragonfly,omitempty" json:"dragonfly,omitempty"`
 
 	// Nydus is the configuration for benchmarking nydus.
-	Nydus NydusConfig `yaml:"nydus,omitempty" mapstructure:"nydus,omitempty"`
+	Nydus NydusConfig `yaml:"nydus,omitempty" mapstructure:"nydus,omitempty" json:"nydus,omitempty"`
+
+	// ProxyLoad is the configuration for the load benchmark of the dfdaemon proxy.
+	ProxyLoad ProxyLoadConfig `yaml:"proxy_load,omitempty" mapstructure:"proxy_load,omitempty" json:"proxy_load,omitempty"`
+
+	// FileServer is the configuration of the file server serving the files of the benchmarks.
+	FileServer FileServerConfig `yaml:"file_server,omitempty" mapstructure:"file_server,omitempty" json:"file_server,omitempty"`
+
+	// Image is the configuration for benchmarking the image pulls.
+	Image ImageConfig `yaml:"image,omitempty" mapstructure:"image,omitempty" json:"image,omitempty"`
+
+	// Report is the configuration for reporting the benchmark results.
+	Report ReportConfig `yaml:"report,omitempty" mapstructure:"report,omitempty" json:"report,omitempty"`
+
+	// Compare is the configuration for comparing the benchmark results with the baseline.
+	Compare CompareConfig `yaml:"compare,omitempty" mapstructure:"compare,omitempty" json:"compare,omitempty"`
+
+	// Manager is the configuration of the Dragonfly manager to create the preheat jobs.
+	Manager ManagerConfig `yaml:"manager,omitempty" mapstructure:"manager,omitempty" json:"manager,omitempty"`
 }
 
 // DragonflyConfig is the configuration for benchmarking dragonfly.
 type DragonflyConfig struct {
 	// Namespace is the namespace to use for the benchmark.
-	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace,omitempty" json:"namespace,omitempty"`
 
 	// Number is the number of times to run the benchmark.
-	Number uint32 `yaml:"number,omitempty" mapstructure:"number,omitempty"`
+	Number uint32 `yaml:"number,omitempty" mapstructure:"number,omitempty" json:"number,omitempty"`
+
+	// Warmup is the number of warmup iterations to run before the benchmark, the results are discarded.
+	Warmup uint32 `yaml:"warmup,omitempty" mapstructure:"warmup,omitempty" json:"warmup,omitempty"`
+
+	// Cooldown is the duration to wait between iterations.
+	Cooldown time.Duration `yaml:"cooldown,omitempty" mapstructure:"cooldown,omitempty" json:"cooldown,omitempty"`
 
-	// Downloader is the downloader to use for the benchmark [dfget, proxy], default is dfget.
-	Downloader string `yaml:"downloader,omitempty" mapstructure:"downloader,omitempty"`
+	// Downloader is the downloaders to use for the benchmark separated by commas [dfget, proxy, dfcache, dfstore, direct],
+	// default is dfget.
+	Downloader string `yaml:"downloader,omitempty" mapstructure:"downloader,omitempty" json:"downloader,omitempty"`
 
 	// FileSizeLevel is the file size level to use for the benchmark [nano, micro, small, medium, large, xlarge, xxlarge], default is "" to run all levels.
-	FileSizeLevel string `yaml:"file_size_level,omitempty" mapstructure:"file_size_level,omitempty"`
+	FileSizeLevel string `yaml:"file_size_level,omitempty" mapstructure:"file_size_level,omitempty" json:"file_size_level,omitempty"`
+
+	// FileSize is the custom file sizes to use for the benchmark separated by commas instead of the file size levels,
+	// e.g. 256MiB,3GiB, the files are generated by the file server on the fly.
+	FileSize string `yaml:"file_size,omitempty" mapstructure:"file_size,omitempty" json:"file_size,omitempty"`
+
+	// Executor is the executor to run the downloads [kubernetes, local], default is kubernetes.
+	Executor string `yaml:"executor,omitempty" mapstructure:"executor,omitempty" json:"executor,omitempty"`
+
+	// Endpoints is the local dfdaemon endpoints in the format of "proxy=<addr>,metrics=<addr>,socket=<path>,object-storage=<addr>",
+	// only used by the local executor, default is the dfdaemon listening on the default addresses.
+	Endpoints []string `yaml:"endpoints,omitempty" mapstructure:"endpoints,omitempty" json:"endpoints,omitempty"`
+
+	// FileServer is the base URL of the file server, default is "" to use the file server in the namespace.
+	FileServer string `yaml:"file_server,omitempty" mapstructure:"file_server,omitempty" json:"file_server,omitempty"`
+
+	// OutputDir is the directory to store the downloaded files in the client pods, it is removed
+	// entirely by the cleanup, so it must not be shared with anything else, default is /tmp/dfbench.
+	OutputDir string `yaml:"output_dir,omitempty" mapstructure:"output_dir,omitempty" json:"output_dir,omitempty"`
+
+	// Origin is the bandwidth and latency shaping of the file server for the downloaded files.
+	Origin OriginConfig `yaml:"origin,omitempty" mapstructure:"origin,omitempty" json:"origin,omitempty"`
+
+	// Concurrency is the maximum number of pods downloading at the same time, default is 0 to download
+	// in all pods at the same time.
+	Concurrency uint32 `yaml:"concurrency" mapstructure:"concurrency" json:"concurrency"`
+
+	// Parallelism is the number of parallel downloads in every pod, every parallel download is a
+	// different task shared by all pods, default is 1.
+	Parallelism uint32 `yaml:"parallelism" mapstructure:"parallelism" json:"parallelism"`
+
+	// StartPattern is the pattern to start the downloads in the pods [all-at-once, ramp, waves, seed-first],
+	// default is all-at-once.
+	StartPattern string `yaml:"start_pattern,omitempty" mapstructure:"start_pattern,omitempty" json:"start_pattern,omitempty"`
+
+	// WaveSize is the number of pods in every wave of the ramp and waves start patterns, default is 1.
+	WaveSize uint32 `yaml:"wave_size,omitempty" mapstructure:"wave_size,omitempty" json:"wave_size,omitempty"`
+
+	// RampDuration is the duration to start all waves over by the ramp start pattern.
+	RampDuration time.Duration `yaml:"ramp_duration,omitempty" mapstructure:"ramp_duration,omitempty" json:"ramp_duration,omitempty"`
+
+	// Cache is the cache mode of the downloads [cold, hot], default is cold.
+	Cache string `yaml:"cache,omitempty" mapstructure:"cache,omitempty" json:"cache,omitempty"`
+
+	// Bucket is the bucket of the object storage to put the files into, only used by dfstore.
+	Bucket string `yaml:"bucket,omitempty" mapstructure:"bucket,omitempty" json:"bucket,omitempty"`
+
+	// RangeMode is the range workload of the proxy downloader [full, fixed, random, sequential], default is full.
+	RangeMode string `yaml:"range_mode,omitempty" mapstructure:"range_mode,omitempty" json:"range_mode,omitempty"`
+
+	// Range is the byte range of the fixed range mode, e.g. 0-1023 or bytes=0-1023.
+	Range string `yaml:"range,omitempty" mapstructure:"range,omitempty" json:"range,omitempty"`
+
+	// ChunkSize is the size of every request in bytes of the random and sequential range modes.
+	ChunkSize uint64 `yaml:"chunk_size,omitempty" mapstructure:"chunk_size,omitempty" json:"chunk_size,omitempty"`
+
+	// TaskPoolSize is the number of tasks read in turn by the sequential range mode.
+	TaskPoolSize uint32 `yaml:"task_pool_size,omitempty" mapstructure:"task_pool_size,omitempty" json:"task_pool_size,omitempty"`
+
+	// Preheat is true to download the preheated tasks after the tasks without preheat in every
+	// iteration by dfget and proxy, the tasks are preheated by the manager.
+	Preheat bool `yaml:"preheat,omitempty" mapstructure:"preheat,omitempty" json:"preheat,omitempty"`
+
+	// Verify is true to compare the size and the digest of every downloaded file with the ones advertised
+	// by the file server, the mismatched downloads are reported as corrupted. The chunks of the sequential
+	// range mode are not verified.
+	Verify bool `yaml:"verify,omitempty" mapstructure:"verify,omitempty" json:"verify,omitempty"`
+
+	// OriginTraffic is true to count the traffic served by the file server for the tasks of every download
+	// step and reconcile it with the back-to-source traffic reported by the dfdaemon.
+	OriginTraffic bool `yaml:"origin_traffic,omitempty" mapstructure:"origin_traffic,omitempty" json:"origin_traffic,omitempty"`
 }
 
 // NydusConfig is the configuration for benchmarking nydus.
 type NydusConfig struct {
 	// Namespace is the namespace to use for the benchmark.
-	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Number is the number of times to run the benchmark.
+	Number uint32 `yaml:"number,omitempty" mapstructure:"number,omitempty" json:"number,omitempty"`
+
+	// Images is the RAFS images to start containers from on every node.
+	Images []string `yaml:"images,omitempty" mapstructure:"images,omitempty" json:"images,omitempty"`
+
+	// LabelSelector is the label selector of the nydus-snapshotter pods, one pod runs on every node.
+	LabelSelector string `yaml:"label_selector,omitempty" mapstructure:"label_selector,omitempty" json:"label_selector,omitempty"`
+
+	// Container is the container of the nydus-snapshotter pods to execute commands in.
+	Container string `yaml:"container,omitempty" mapstructure:"container,omitempty" json:"container,omitempty"`
+}
+
+// ProxyLoadConfig is the configuration for the open-loop load benchmark of the dfdaemon proxy.
+type ProxyLoadConfig struct {
+	// Mode is the mode of the load [repeat, random, sequential], default is repeat.
+	Mode string `yaml:"mode,omitempty" mapstructure:"mode,omitempty" json:"mode,omitempty"`
+
+	// Proxy is the URL of the dfdaemon proxy to send the requests through.
+	Proxy string `yaml:"proxy,omitempty" mapstructure:"proxy,omitempty" json:"proxy,omitempty"`
+
+	// TargetURL is the URL of the file to request, default is the small file of the file server,
+	// or the large file in the sequential mode.
+	TargetURL string `yaml:"target_url,omitempty" mapstructure:"target_url,omitempty" json:"target_url,omitempty"`
+
+	// Rate is the number of requests per second, 0 sends the requests as fast as possible by the max workers.
+	Rate uint32 `yaml:"rate" mapstructure:"rate" json:"rate"`
+
+	// Duration is the duration to send the requests.
+	Duration time.Duration `yaml:"duration,omitempty" mapstructure:"duration,omitempty" json:"duration,omitempty"`
+
+	// Timeout is the timeout of every request.
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// MaxWorkers is the maximum number of the requests in flight.
+	MaxWorkers uint32 `yaml:"max_workers,omitempty" mapstructure:"max_workers,omitempty" json:"max_workers,omitempty"`
+
+	// Range is the byte range of every request in the repeat and random modes, e.g. 0-1023 or bytes=0-1023,
+	// default is "" to request the whole file.
+	Range string `yaml:"range,omitempty" mapstructure:"range,omitempty" json:"range,omitempty"`
+
+	// FileSize is the size of the file in bytes read in the sequential mode.
+	FileSize uint64 `yaml:"file_size,omitempty" mapstructure:"file_size,omitempty" json:"file_size,omitempty"`
+
+	// ChunkSize is the size of every request in bytes in the sequential mode.
+	ChunkSize uint64 `yaml:"chunk_size,omitempty" mapstructure:"chunk_size,omitempty" json:"chunk_size,omitempty"`
+
+	// Streams is the number of the passes read in turn in the sequential mode.
+	Streams uint32 `yaml:"streams,omitempty" mapstructure:"streams,omitempty" json:"streams,omitempty"`
+
+	// URLCount is the number of the tasks in the pool in the sequential mode.
+	URLCount uint32 `yaml:"url_count,omitempty" mapstructure:"url_count,omitempty" json:"url_count,omitempty"`
+}
+
+// Target returns the URL of the file to request, the small file of the file server by default,
+// or the large file in the sequential mode to read the chunks from.
+func (c *ProxyLoadConfig) Target(fileServer string) string {
+	if c.TargetURL != "" {
+		return c.TargetURL
+	}
+
+	if c.Mode == LoadModeSequential {
+		return strings.TrimSuffix(fileServer, "/") + "/large"
+	}
+
+	return strings.TrimSuffix(fileServer, "/") + "/small"
+}
+
+// FileServerConfig is the configuration of the file server generating the files on the fly.
+type FileServerConfig struct {
+	// Addr is the address to listen on.
+	Addr string `yaml:"addr,omitempty" mapstructure:"addr,omitempty" json:"addr,omitempty"`
+
+	// Seed is the seed of the pseudo-random content of the files, the same seed serves the same content.
+	Seed uint64 `yaml:"seed" mapstructure:"seed" json:"seed"`
+}
+
+// OriginConfig is the configuration of the file server emulating a slow origin, it is set by the query
+// parameters of the file URLs, so the file server must be the file server of dfbench.
+type OriginConfig struct {
+	// Bandwidth is the bandwidth cap of every connection per second, e.g. 10MiB, default is "" to be unlimited.
+	Bandwidth string `yaml:"bandwidth,omitempty" mapstructure:"bandwidth,omitempty" json:"bandwidth,omitempty"`
+
+	// AggregateBandwidth is the bandwidth cap per second shared by all connections, e.g. 100MiB, default
+	// is "" to be unlimited.
+	AggregateBandwidth string `yaml:"aggregate_bandwidth,omitempty" mapstructure:"aggregate_bandwidth,omitempty" json:"aggregate_bandwidth,omitempty"`
+
+	// TTFB is the delay before the first byte of every response.
+	TTFB time.Duration `yaml:"ttfb,omitempty" mapstructure:"ttfb,omitempty" json:"ttfb,omitempty"`
+
+	// TTFBJitter is the maximum random deviation of the delay before the first byte.
+	TTFBJitter time.Duration `yaml:"ttfb_jitter,omitempty" mapstructure:"ttfb_jitter,omitempty" json:"ttfb_jitter,omitempty"`
+
+	// Fault is the fault profile injected into the responses of the faulty tasks [error, reset, stall, truncate],
+	// default is "" to inject no fault.
+	Fault string `yaml:"fault,omitempty" mapstructure:"fault,omitempty" json:"fault,omitempty"`
+
+	// FaultRate is the fraction of the requests the fault is injected into in (0, 1], default is 0.5.
+	FaultRate float64 `yaml:"fault_rate,omitempty" mapstructure:"fault_rate,omitempty" json:"fault_rate,omitempty"`
+
+	// FaultAfter is the body sent before the connection is reset, stalled or closed, e.g. 1MiB, default is "" to fail
+	// before the first byte of the body.
+	FaultAfter string `yaml:"fault_after,omitempty" mapstructure:"fault_after,omitempty" json:"fault_after,omitempty"`
+
+	// FaultStall is the duration of the stall of the stall fault.
+	FaultStall time.Duration `yaml:"fault_stall,omitempty" mapstructure:"fault_stall,omitempty" json:"fault_stall,omitempty"`
+
+	// FaultStatus is the status code of the error fault, default is 503.
+	FaultStatus int `yaml:"fault_status,omitempty" mapstructure:"fault_status,omitempty" json:"fault_status,omitempty"`
+}
+
+// Origin returns the origin of the file server by the configuration, the fault is set only if the fault
+// profile is specified.
+func (c *OriginConfig) Origin() (*backend.Origin, error) {
+	origin := &backend.Origin{TTFB: c.TTFB, TTFBJitter: c.TTFBJitter}
+	for _, bandwidth := range []struct {
+		name  string
+		value string
+		size  *uint64
+	}{
+		{"bandwidth", c.Bandwidth, &origin.Bandwidth},
+		{"aggregate bandwidth", c.AggregateBandwidth, &origin.AggregateBandwidth},
+	} {
+		if bandwidth.value == "" {
+			continue
+		}
+
+		size, err := humanize.ParseBytes(bandwidth.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", bandwidth.name, bandwidth.value, err)
+		}
+
+		*bandwidth.size = size
+	}
+
+	if c.Fault == "" {
+		return origin, nil
+	}
+
+	fault := &backend.Fault{Profile: c.Fault, Rate: c.FaultRate, Stall: c.FaultStall, Status: c.FaultStatus}
+	if c.FaultAfter != "" {
+		after, err := humanize.ParseBytes(c.FaultAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault after %q: %w", c.FaultAfter, err)
+		}
+
+		fault.After = after
+	}
+
+	if err := fault.Validate(); err != nil {
+		return nil, err
+	}
+
+	origin.Fault = fault
+	return origin, nil
+}
+
+// ImageConfig is the configuration for benchmarking the image pulls through the dfdaemon registry mirror.
+type ImageConfig struct {
+	// Namespace is the namespace of the dragonfly client pods.
+	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace,omitempty" json:"namespace,omitempty"`
 
 	// Number is the number of times to run the benchmark.
-	Number uint32 `yaml:"number,omitempty" mapstructure:"number,omitempty"`
+	Number uint32 `yaml:"number,omitempty" mapstructure:"number,omitempty" json:"number,omitempty"`
+
+	// Images is the images to pull on every node.
+	Images []string `yaml:"images,omitempty" mapstructure:"images,omitempty" json:"images,omitempty"`
+
+	// Registry is the registry to pull the images without a registry host from, e.g. the local registry
+	// deployed in the cluster, default is "" to pull the images as they are.
+	Registry string `yaml:"registry,omitempty" mapstructure:"registry,omitempty" json:"registry,omitempty"`
+
+	// Puller is the tool to pull the images [ctr, crictl], default is ctr.
+	Puller string `yaml:"puller,omitempty" mapstructure:"puller,omitempty" json:"puller,omitempty"`
+
+	// HostsDir is the directory of the containerd registry hosts configuration with the dfdaemon mirror, used by ctr.
+	HostsDir string `yaml:"hosts_dir,omitempty" mapstructure:"hosts_dir,omitempty" json:"hosts_dir,omitempty"`
+
+	// Cache is the cache mode of the pulls [cold, hot], default is cold. The images of the local registry
+	// are copied to a new repository for every iteration in the cold cache mode, and for the first
+	// iteration only in the hot cache mode, the other images are pulled from the cache after the first iteration.
+	Cache string `yaml:"cache,omitempty" mapstructure:"cache,omitempty" json:"cache,omitempty"`
+	// Preheat is true to preheat the image by the manager before every pull.
+	Preheat bool `yaml:"preheat,omitempty" mapstructure:"preheat,omitempty" json:"preheat,omitempty"`
+}
+
+// References returns the references of the images to pull, the images without a registry host are
+// prefixed with the registry if specified.
+func (c *ImageConfig) References() []string {
+	references := make([]string, 0, len(c.Images))
+	for _, image := range c.Images {
+		if c.Registry != "" && !hasRegistryHost(image) {
+			image = strings.TrimSuffix(c.Registry, "/") + "/" + image
+		}
+
+		references = append(references, image)
+	}
+
+	return references
+}
+
+// hasRegistryHost returns true if the first component of the image reference is a registry host,
+// it follows the rule of the docker reference that the host contains a dot or a port, or is localhost.
+func hasRegistryHost(image string) bool {
+	host, _, ok := strings.Cut(image, "/")
+	if !ok {
+		return false
+	}
+
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
+
+// ManagerConfig is the configuration of the Dragonfly manager.
+type ManagerConfig struct {
+	// URL is the base URL of the REST API of the manager, default is http://dragonfly-manager.<namespace>.svc:8080.
+	URL string `yaml:"url,omitempty" mapstructure:"url,omitempty" json:"url,omitempty"`
+
+	// Token is the personal access token to access the open API of the manager, it is not written into the report.
+	Token string `yaml:"token,omitempty" mapstructure:"token,omitempty" json:"-"`
+
+	// Scope is the scope of the peers to preheat [single_seed_peer, all_seed_peers, all_peers],
+	// default is "" to use the default of the manager.
+	Scope string `yaml:"scope,omitempty" mapstructure:"scope,omitempty" json:"scope,omitempty"`
+}
+
+// BaseURL returns the base URL of the REST API of the manager, the manager service in the
+// namespace is used if the URL is not specified.
+func (c *ManagerConfig) BaseURL(namespace string) string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	return fmt.Sprintf("http://dragonfly-manager.%s.svc:8080", namespace)
+}
+
+// ReportConfig is the configuration for reporting the benchmark results.
+type ReportConfig struct {
+	// Format is the output format of the report [table, json, csv, markdown], default is table.
+	Format string `yaml:"format,omitempty" mapstructure:"format,omitempty" json:"format,omitempty"`
+
+	// File is the path of the file to write the report, default is "" to write to stdout.
+	File string `yaml:"file,omitempty" mapstructure:"file,omitempty" json:"file,omitempty"`
+
+	// Columns is the columns of the statistics table, "all" selects all columns, default is "" to select the default columns.
+	Columns []string `yaml:"columns,omitempty" mapstructure:"columns,omitempty" json:"columns,omitempty"`
+}
+
+// CompareConfig is the configuration for comparing the benchmark results with the baseline.
+type CompareConfig struct {
+	// Baseline is the path of the baseline report in JSON format, default is "" to skip the comparison.
+	Baseline string `yaml:"baseline,omitempty" mapstructure:"baseline,omitempty" json:"baseline,omitempty"`
+
+	// LatencyThreshold is the maximum latency regression in percent.
+	LatencyThreshold float64 `yaml:"latency_threshold,omitempty" mapstructure:"latency_threshold,omitempty" json:"latency_threshold,omitempty"`
+
+	// BackToSourceRateThreshold is the maximum back-to-source rate increase in percentage points.
+	BackToSourceRateThreshold float64 `yaml:"back_to_source_rate_threshold,omitempty" mapstructure:"back_to_source_rate_threshold,omitempty" json:"back_to_source_rate_threshold,omitempty"`
 }
 
 // New bench configuration.
@@ -82,12 +543,126 @@ func New() *Config {
 			Namespace:     "dragonfly-system",
 			Downloader:    DownloaderDfget,
 			FileSizeLevel: "",
+			Executor:      ExecutorKubernetes,
+			OutputDir:     "/tmp/dfbench",
+			Parallelism:   1,
+			StartPattern:  StartPatternAllAtOnce,
+			WaveSize:      1,
+			Cache:         CacheCold,
+			RangeMode:     RangeModeFull,
+			ChunkSize:     1 << 20,
+			TaskPoolSize:  32,
+			Origin: OriginConfig{
+				FaultRate:   0.5,
+				FaultStatus: 503,
+			},
 		},
 		Nydus: NydusConfig{
+			Number:        1,
+			Namespace:     "nydus-snapshotter",
+			LabelSelector: "app=nydus-snapshotter",
+			Container:     "nydus-snapshotter",
+		},
+		ProxyLoad: ProxyLoadConfig{
+			Mode:       LoadModeRepeat,
+			Proxy:      "http://127.0.0.1:4001",
+			Rate:       100,
+			Duration:   time.Minute,
+			Timeout:    30 * time.Second,
+			MaxWorkers: 64,
+			FileSize:   1 << 30,
+			ChunkSize:  1 << 20,
+			Streams:    128,
+			URLCount:   32,
+		},
+		FileServer: FileServerConfig{
+			Addr: ":80",
+		},
+		Image: ImageConfig{
 			Number:    1,
-			Namespace: "nydus-snapshotter",
+			Namespace: "dragonfly-system",
+			Puller:    PullerCtr,
+			HostsDir:  "/etc/containerd/certs.d",
+			Cache:     CacheCold,
+		},
+		Report: ReportConfig{
+			Format: OutputFormatTable,
 		},
+		Compare: CompareConfig{
+			LatencyThreshold:          10,
+			BackToSourceRateThreshold: 5,
+		},
+	}
+}
+
+// Downloaders returns the downloaders to use for the benchmark in order.
+func (c *DragonflyConfig) Downloaders() []string {
+	var downloaders []string
+	for _, downloader := range strings.Split(c.Downloader, ",") {
+		if downloader = strings.TrimSpace(downloader); downloader != "" {
+			downloaders = append(downloaders, downloader)
+		}
+	}
+
+	return downloaders
+}
+
+// FileSizeLevels returns the file size levels to run, the custom file sizes take precedence over the file
+// size level, and all file size levels are returned if neither is specified. The invalid sizes are skipped.
+func (c *DragonflyConfig) FileSizeLevels() []backend.FileSizeLevel {
+	if c.FileSize == "" {
+		if c.FileSizeLevel == "" {
+			return backend.FileSizeLevels
+		}
+
+		return []backend.FileSizeLevel{backend.FileSizeLevel(c.FileSizeLevel)}
+	}
+
+	var fileSizeLevels []backend.FileSizeLevel
+	for _, size := range strings.Split(c.FileSize, ",") {
+		if size = strings.TrimSpace(size); size == "" {
+			continue
+		}
+
+		fileSizeLevel, err := backend.ParseFileSizeLevel(size)
+		if err != nil {
+			continue
+		}
+
+		fileSizeLevels = append(fileSizeLevels, fileSizeLevel)
+	}
+
+	return fileSizeLevels
+}
+
+// ByteRange returns the byte range of the fixed range mode without the unit, e.g. 0-1023.
+func (c *DragonflyConfig) ByteRange() string {
+	return strings.TrimPrefix(c.Range, "bytes=")
+}
+
+// Load loads the configuration from the YAML file and the environment variables, the environment
+// variables take precedence over the file and the file is skipped if the path is empty.
+func (c *Config) Load(path string) error {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if err := bindEnvs(v, "", reflect.TypeOf(*c)); err != nil {
+		return err
 	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+
+	if err := v.UnmarshalExact(c); err != nil {
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	return nil
 }
 
 // Validate the configuration.
@@ -96,5 +671,224 @@ func (c *Config) Validate() error {
 		return errors.New("timeout must be greater than 1 minute")
 	}
 
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid log level %q", c.LogLevel)
+	}
+
+	if err := validateNamespace("dragonfly", c.Dragonfly.Namespace); err != nil {
+		return err
+	}
+
+	if c.Dragonfly.Number == 0 {
+		return errors.New("dragonfly number must be greater than 0")
+	}
+
+	if c.Dragonfly.Cooldown < 0 {
+		return errors.New("dragonfly cooldown must not be negative")
+	}
+
+	downloaders := c.Dragonfly.Downloaders()
+	if len(downloaders) == 0 {
+		return errors.New("dragonfly downloader must not be empty")
+	}
+
+	for _, downloader := range downloaders {
+		if !slices.Contains([]string{DownloaderDfget, DownloaderProxy, DownloaderDfcache, DownloaderDfstore, DownloaderDirect}, downloader) {
+			return fmt.Errorf("invalid dragonfly downloader %q, must be one of [%s, %s, %s, %s, %s]", downloader, DownloaderDfget, DownloaderProxy, DownloaderDfcache, DownloaderDfstore, DownloaderDirect)
+		}
+	}
+
+	if slices.Contains(downloaders, DownloaderDfstore) && c.Dragonfly.Bucket == "" {
+		return errors.New("dragonfly bucket must not be empty for the dfstore downloader")
+	}
+
+	if c.Dragonfly.FileSizeLevel != "" && !slices.Contains(backend.FileSizeLevels, backend.FileSizeLevel(c.Dragonfly.FileSizeLevel)) {
+		return fmt.Errorf("invalid dragonfly file size level %q", c.Dragonfly.FileSizeLevel)
+	}
+
+	if c.Dragonfly.FileSize != "" {
+		if c.Dragonfly.FileSizeLevel != "" {
+			return errors.New("dragonfly file size and file size level are mutually exclusive")
+		}
+
+		for _, size := range strings.Split(c.Dragonfly.FileSize, ",") {
+			if _, err := backend.ParseFileSizeLevel(strings.TrimSpace(size)); err != nil {
+				return fmt.Errorf("invalid dragonfly file size: %w", err)
+			}
+		}
+	}
+
+	if c.Dragonfly.Parallelism == 0 {
+		return errors.New("dragonfly parallelism must be greater than 0")
+	}
+
+	if !slices.Contains([]string{StartPatternAllAtOnce, StartPatternRamp, StartPatternWaves, StartPatternSeedFirst}, c.Dragonfly.StartPattern) {
+		return fmt.Errorf("invalid dragonfly start pattern %q, must be one of [%s, %s, %s, %s]", c.Dragonfly.StartPattern, StartPatternAllAtOnce, StartPatternRamp, StartPatternWaves, StartPatternSeedFirst)
+	}
+
+	if c.Dragonfly.WaveSize == 0 {
+		return errors.New("dragonfly wave size must be greater than 0")
+	}
+
+	if c.Dragonfly.StartPattern == StartPatternRamp && c.Dragonfly.RampDuration <= 0 {
+		return errors.New("dragonfly ramp duration must be greater than 0 for the ramp start pattern")
+	}
+
+	if !slices.Contains([]string{CacheCold, CacheHot}, c.Dragonfly.Cache) {
+		return fmt.Errorf("invalid dragonfly cache mode %q, must be one of [%s, %s]", c.Dragonfly.Cache, CacheCold, CacheHot)
+	}
+
+	if !slices.Contains([]string{RangeModeFull, RangeModeFixed, RangeModeRandom, RangeModeSequential}, c.Dragonfly.RangeMode) {
+		return fmt.Errorf("invalid dragonfly range mode %q, must be one of [%s, %s, %s, %s]", c.Dragonfly.RangeMode, RangeModeFull, RangeModeFixed, RangeModeRandom, RangeModeSequential)
+	}
+
+	if c.Dragonfly.RangeMode == RangeModeFixed && !byteRangeRegexp.MatchString(c.Dragonfly.Range) {
+		return fmt.Errorf("invalid dragonfly range %q for the fixed range mode, must be in the format of <start>-[end]", c.Dragonfly.Range)
+	}
+
+	if c.Dragonfly.RangeMode != RangeModeFixed && c.Dragonfly.Range != "" {
+		return errors.New("dragonfly range is only supported by the fixed range mode")
+	}
+
+	if c.Dragonfly.ChunkSize == 0 {
+		return errors.New("dragonfly chunk size must be greater than 0")
+	}
+
+	if c.Dragonfly.TaskPoolSize == 0 {
+		return errors.New("dragonfly task pool size must be greater than 0")
+	}
+
+	if _, err := c.Dragonfly.Origin.Origin(); err != nil {
+		return fmt.Errorf("invalid dragonfly origin: %w", err)
+	}
+
+	if c.Dragonfly.Origin.TTFB < 0 || c.Dragonfly.Origin.TTFBJitter < 0 {
+		return errors.New("dragonfly origin ttfb and ttfb jitter must not be negative")
+	}
+
+	if !slices.Contains([]string{ExecutorKubernetes, ExecutorLocal}, c.Dragonfly.Executor) {
+		return fmt.Errorf("invalid dragonfly executor %q, must be one of [%s, %s]", c.Dragonfly.Executor, ExecutorKubernetes, ExecutorLocal)
+	}
+
+	// The output dir is removed entirely by the cleanup, so the root or a relative path is rejected.
+	if !path.IsAbs(c.Dragonfly.OutputDir) || path.Clean(c.Dragonfly.OutputDir) == "/" {
+		return fmt.Errorf("invalid dragonfly output dir %q, must be an absolute path other than /", c.Dragonfly.OutputDir)
+	}
+
+	if err := validateNamespace("nydus", c.Nydus.Namespace); err != nil {
+		return err
+	}
+
+	if c.Nydus.Number == 0 {
+		return errors.New("nydus number must be greater than 0")
+	}
+
+	if !slices.Contains([]string{LoadModeRepeat, LoadModeRandom, LoadModeSequential}, c.ProxyLoad.Mode) {
+		return fmt.Errorf("invalid proxy load mode %q, must be one of [%s, %s, %s]", c.ProxyLoad.Mode, LoadModeRepeat, LoadModeRandom, LoadModeSequential)
+	}
+
+	if c.ProxyLoad.Range != "" && c.ProxyLoad.Mode == LoadModeSequential {
+		return errors.New("proxy load range is not supported in the sequential mode, it requests the chunks by range")
+	}
+
+	if c.ProxyLoad.Range != "" && !byteRangeRegexp.MatchString(c.ProxyLoad.Range) {
+		return fmt.Errorf("invalid proxy load range %q, must be in the format of <start>-[end]", c.ProxyLoad.Range)
+	}
+
+	if c.ProxyLoad.Duration <= 0 || c.ProxyLoad.Timeout <= 0 {
+		return errors.New("proxy load duration and timeout must be greater than 0")
+	}
+
+	// The last requests are sent at the end of the duration and wait for the request timeout at most.
+	if c.ProxyLoad.Duration+c.ProxyLoad.Timeout >= c.Timeout {
+		return fmt.Errorf("proxy load duration %s plus request timeout %s must be less than the timeout %s", c.ProxyLoad.Duration, c.ProxyLoad.Timeout, c.Timeout)
+	}
+
+	if c.ProxyLoad.MaxWorkers == 0 || c.ProxyLoad.Streams == 0 || c.ProxyLoad.URLCount == 0 {
+		return errors.New("proxy load max workers, streams and url count must be greater than 0")
+	}
+
+	if c.ProxyLoad.FileSize == 0 || c.ProxyLoad.ChunkSize == 0 {
+		return errors.New("proxy load file size and chunk size must be greater than 0")
+	}
+
+	if c.FileServer.Addr == "" {
+		return errors.New("file server addr must not be empty")
+	}
+
+	if err := validateNamespace("image", c.Image.Namespace); err != nil {
+		return err
+	}
+
+	if c.Image.Number == 0 {
+		return errors.New("image number must be greater than 0")
+	}
+
+	if !slices.Contains([]string{PullerCtr, PullerCrictl}, c.Image.Puller) {
+		return fmt.Errorf("invalid image puller %q, must be one of [%s, %s]", c.Image.Puller, PullerCtr, PullerCrictl)
+	}
+
+	if !slices.Contains([]string{CacheCold, CacheHot}, c.Image.Cache) {
+		return fmt.Errorf("invalid image cache mode %q, must be one of [%s, %s]", c.Image.Cache, CacheCold, CacheHot)
+	}
+
+	if (c.Dragonfly.Preheat || c.Image.Preheat) && c.Manager.Token == "" {
+		return errors.New("manager token must not be empty for preheat")
+	}
+
+	if !slices.Contains([]string{"", "single_seed_peer", "all_seed_peers", "all_peers"}, c.Manager.Scope) {
+		return fmt.Errorf("invalid manager preheat scope %q, must be one of [single_seed_peer, all_seed_peers, all_peers]", c.Manager.Scope)
+	}
+
+	if !slices.Contains([]string{OutputFormatTable, OutputFormatJSON, OutputFormatCSV, OutputFormatMarkdown}, c.Report.Format) {
+		return fmt.Errorf("invalid output format %q", c.Report.Format)
+	}
+
+	if c.Compare.LatencyThreshold < 0 || c.Compare.BackToSourceRateThreshold < 0 {
+		return errors.New("compare thresholds must not be negative")
+	}
+
+	return nil
+}
+
+// validateNamespace validates the namespace is a valid kubernetes namespace name.
+func validateNamespace(name, namespace string) error {
+	if namespace == "" {
+		return fmt.Errorf("%s namespace must not be empty", name)
+	}
+
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return fmt.Errorf("invalid %s namespace %q: %s", name, namespace, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// bindEnvs binds the environment variables of all keys in the struct type to the viper instance.
+func bindEnvs(v *viper.Viper, prefix string, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if key == "" {
+			continue
+		}
+
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindEnvs(v, key, field.Type); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := v.BindEnv(key); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
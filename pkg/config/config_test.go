@@ -0,0 +1,550 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+// writeConfigFile writes the content to a config file and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "dfbench.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		check   func(t *testing.T, c *Config)
+		wantErr string
+	}{
+		{
+			name: "no file keeps the defaults",
+			check: func(t *testing.T, c *Config) {
+				if c.Dragonfly.Number != 1 || c.Dragonfly.Namespace != "dragonfly-system" || c.Timeout != 30*time.Minute {
+					t.Errorf("config = %+v, want the defaults", c)
+				}
+			},
+		},
+		{
+			name: "file overrides the defaults",
+			file: `timeout: 1h
+dragonfly:
+  number: 3
+  cooldown: 5s
+report:
+  columns: [p50, p99]
+`,
+			check: func(t *testing.T, c *Config) {
+				if c.Timeout != time.Hour || c.Dragonfly.Number != 3 || c.Dragonfly.Cooldown != 5*time.Second {
+					t.Errorf("timeout, number and cooldown = %v, %d and %v, want 1h, 3 and 5s", c.Timeout, c.Dragonfly.Number, c.Dragonfly.Cooldown)
+				}
+
+				if !slices.Equal(c.Report.Columns, []string{"p50", "p99"}) {
+					t.Errorf("columns = %v, want [p50 p99]", c.Report.Columns)
+				}
+
+				// The keys absent from the file keep the defaults.
+				if c.Dragonfly.Namespace != "dragonfly-system" || c.Dragonfly.Downloader != DownloaderDfget {
+					t.Errorf("namespace and downloader = %q and %q, want the defaults", c.Dragonfly.Namespace, c.Dragonfly.Downloader)
+				}
+			},
+		},
+		{
+			name: "env overrides the file",
+			file: `dragonfly:
+  number: 3
+  namespace: from-file
+`,
+			env: map[string]string{
+				"DFBENCH_DRAGONFLY_NUMBER": "5",
+				"DFBENCH_TIMEOUT":          "2h",
+				"DFBENCH_REPORT_COLUMNS":   "times,avg",
+			},
+			check: func(t *testing.T, c *Config) {
+				if c.Dragonfly.Number != 5 || c.Timeout != 2*time.Hour {
+					t.Errorf("number and timeout = %d and %v, want 5 and 2h", c.Dragonfly.Number, c.Timeout)
+				}
+
+				if c.Dragonfly.Namespace != "from-file" {
+					t.Errorf("namespace = %q, want %q", c.Dragonfly.Namespace, "from-file")
+				}
+
+				if !slices.Equal(c.Report.Columns, []string{"times", "avg"}) {
+					t.Errorf("columns = %v, want [times avg]", c.Report.Columns)
+				}
+			},
+		},
+		{
+			name: "env without file",
+			env:  map[string]string{"DFBENCH_DRAGONFLY_DOWNLOADER": DownloaderProxy},
+			check: func(t *testing.T, c *Config) {
+				if c.Dragonfly.Downloader != DownloaderProxy {
+					t.Errorf("downloader = %q, want %q", c.Dragonfly.Downloader, DownloaderProxy)
+				}
+			},
+		},
+		{
+			name: "unknown key",
+			file: `dragonfly:
+  numbr: 3
+`,
+			wantErr: "numbr",
+		},
+		{
+			name:    "unknown section",
+			file:    "dragonfy:\n  number: 3\n",
+			wantErr: "dragonfy",
+		},
+		{
+			name: "invalid value",
+			file: `dragonfly:
+  number: many
+`,
+			wantErr: "failed to decode config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var path string
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+			}
+
+			c := New()
+			err := c.Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if err := New().Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of missing file succeeded, want error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{
+			name:   "default",
+			mutate: func(*Config) {},
+		},
+		{
+			name:    "timeout too short",
+			mutate:  func(c *Config) { c.Timeout = time.Minute },
+			wantErr: "timeout must be greater than 1 minute",
+		},
+		{
+			name:    "invalid log level",
+			mutate:  func(c *Config) { c.LogLevel = "verbose" },
+			wantErr: `invalid log level "verbose"`,
+		},
+		{
+			name:    "empty dragonfly namespace",
+			mutate:  func(c *Config) { c.Dragonfly.Namespace = "" },
+			wantErr: "dragonfly namespace must not be empty",
+		},
+		{
+			name:    "invalid dragonfly namespace",
+			mutate:  func(c *Config) { c.Dragonfly.Namespace = "Dragonfly_System" },
+			wantErr: `invalid dragonfly namespace "Dragonfly_System"`,
+		},
+		{
+			name:    "zero number",
+			mutate:  func(c *Config) { c.Dragonfly.Number = 0 },
+			wantErr: "dragonfly number must be greater than 0",
+		},
+		{
+			name:    "negative cooldown",
+			mutate:  func(c *Config) { c.Dragonfly.Cooldown = -time.Second },
+			wantErr: "dragonfly cooldown must not be negative",
+		},
+		{
+			name:    "unknown downloader",
+			mutate:  func(c *Config) { c.Dragonfly.Downloader = "wget" },
+			wantErr: `invalid dragonfly downloader "wget"`,
+		},
+		{
+			name:   "several downloaders",
+			mutate: func(c *Config) { c.Dragonfly.Downloader = "dfget, proxy,direct" },
+		},
+		{
+			name:    "empty downloader",
+			mutate:  func(c *Config) { c.Dragonfly.Downloader = " , " },
+			wantErr: "dragonfly downloader must not be empty",
+		},
+		{
+			name:    "dfstore without bucket",
+			mutate:  func(c *Config) { c.Dragonfly.Downloader = "dfget,dfstore" },
+			wantErr: "dragonfly bucket must not be empty for the dfstore downloader",
+		},
+		{
+			name: "dfstore with bucket",
+			mutate: func(c *Config) {
+				c.Dragonfly.Downloader = DownloaderDfstore
+				c.Dragonfly.Bucket = "dfbench"
+			},
+		},
+		{
+			name:   "file size level",
+			mutate: func(c *Config) { c.Dragonfly.FileSizeLevel = "small" },
+		},
+		{
+			name:    "unknown file size level",
+			mutate:  func(c *Config) { c.Dragonfly.FileSizeLevel = "huge" },
+			wantErr: `invalid dragonfly file size level "huge"`,
+		},
+		{
+			name:   "file sizes",
+			mutate: func(c *Config) { c.Dragonfly.FileSize = "256MiB, 3GiB,1000" },
+		},
+		{
+			name:    "zero file size",
+			mutate:  func(c *Config) { c.Dragonfly.FileSize = "256MiB,0" },
+			wantErr: `invalid dragonfly file size: invalid file size "0": must be greater than 0`,
+		},
+		{
+			name:    "overflowing file size",
+			mutate:  func(c *Config) { c.Dragonfly.FileSize = "100000EiB" },
+			wantErr: `invalid dragonfly file size: invalid file size "100000EiB"`,
+		},
+		{
+			name: "file size with file size level",
+			mutate: func(c *Config) {
+				c.Dragonfly.FileSize = "256MiB"
+				c.Dragonfly.FileSizeLevel = "small"
+			},
+			wantErr: "dragonfly file size and file size level are mutually exclusive",
+		},
+		{
+			name:    "zero parallelism",
+			mutate:  func(c *Config) { c.Dragonfly.Parallelism = 0 },
+			wantErr: "dragonfly parallelism must be greater than 0",
+		},
+		{
+			name:    "unknown start pattern",
+			mutate:  func(c *Config) { c.Dragonfly.StartPattern = "burst" },
+			wantErr: `invalid dragonfly start pattern "burst"`,
+		},
+		{
+			name:    "zero wave size",
+			mutate:  func(c *Config) { c.Dragonfly.WaveSize = 0 },
+			wantErr: "dragonfly wave size must be greater than 0",
+		},
+		{
+			name:    "ramp without duration",
+			mutate:  func(c *Config) { c.Dragonfly.StartPattern = StartPatternRamp },
+			wantErr: "dragonfly ramp duration must be greater than 0",
+		},
+		{
+			name: "ramp with duration",
+			mutate: func(c *Config) {
+				c.Dragonfly.StartPattern = StartPatternRamp
+				c.Dragonfly.RampDuration = time.Minute
+			},
+		},
+		{
+			name:    "unknown cache mode",
+			mutate:  func(c *Config) { c.Dragonfly.Cache = "warm" },
+			wantErr: `invalid dragonfly cache mode "warm"`,
+		},
+		{
+			name:   "hot cache mode",
+			mutate: func(c *Config) { c.Dragonfly.Cache = CacheHot },
+		},
+		{
+			name:    "unknown executor",
+			mutate:  func(c *Config) { c.Dragonfly.Executor = "ssh" },
+			wantErr: `invalid dragonfly executor "ssh"`,
+		},
+		{
+			name:    "relative output dir",
+			mutate:  func(c *Config) { c.Dragonfly.OutputDir = "dfbench" },
+			wantErr: `invalid dragonfly output dir "dfbench"`,
+		},
+		{
+			name:    "root output dir",
+			mutate:  func(c *Config) { c.Dragonfly.OutputDir = "/tmp/.." },
+			wantErr: `invalid dragonfly output dir "/tmp/.."`,
+		},
+		{
+			name:    "empty output dir",
+			mutate:  func(c *Config) { c.Dragonfly.OutputDir = "" },
+			wantErr: `invalid dragonfly output dir ""`,
+		},
+		{
+			name:    "empty nydus namespace",
+			mutate:  func(c *Config) { c.Nydus.Namespace = "" },
+			wantErr: "nydus namespace must not be empty",
+		},
+		{
+			name:    "zero nydus number",
+			mutate:  func(c *Config) { c.Nydus.Number = 0 },
+			wantErr: "nydus number must be greater than 0",
+		},
+		{
+			name:    "unknown proxy load mode",
+			mutate:  func(c *Config) { c.ProxyLoad.Mode = "burst" },
+			wantErr: `invalid proxy load mode "burst"`,
+		},
+		{
+			name: "proxy load range in the sequential mode",
+			mutate: func(c *Config) {
+				c.ProxyLoad.Mode = LoadModeSequential
+				c.ProxyLoad.Range = "0-1023"
+			},
+			wantErr: "proxy load range is not supported in the sequential mode",
+		},
+		{
+			name:    "invalid proxy load range",
+			mutate:  func(c *Config) { c.ProxyLoad.Range = "1023-0-1" },
+			wantErr: `invalid proxy load range "1023-0-1"`,
+		},
+		{
+			name:   "open proxy load range",
+			mutate: func(c *Config) { c.ProxyLoad.Range = "bytes=1024-" },
+		},
+		{
+			name:    "zero proxy load duration",
+			mutate:  func(c *Config) { c.ProxyLoad.Duration = 0 },
+			wantErr: "proxy load duration and timeout must be greater than 0",
+		},
+		{
+			name: "proxy load exceeding the timeout",
+			mutate: func(c *Config) {
+				c.ProxyLoad.Duration = 29 * time.Minute
+				c.ProxyLoad.Timeout = time.Minute
+			},
+			wantErr: "proxy load duration 29m0s plus request timeout 1m0s must be less than the timeout 30m0s",
+		},
+		{
+			name: "proxy load within the timeout",
+			mutate: func(c *Config) {
+				c.ProxyLoad.Duration = 29 * time.Minute
+				c.ProxyLoad.Timeout = 59 * time.Second
+			},
+		},
+		{
+			name:    "zero proxy load max workers",
+			mutate:  func(c *Config) { c.ProxyLoad.MaxWorkers = 0 },
+			wantErr: "proxy load max workers, streams and url count must be greater than 0",
+		},
+		{
+			name:    "zero proxy load chunk size",
+			mutate:  func(c *Config) { c.ProxyLoad.ChunkSize = 0 },
+			wantErr: "proxy load file size and chunk size must be greater than 0",
+		},
+		{
+			name:    "empty image namespace",
+			mutate:  func(c *Config) { c.Image.Namespace = "" },
+			wantErr: "image namespace must not be empty",
+		},
+		{
+			name:    "zero image number",
+			mutate:  func(c *Config) { c.Image.Number = 0 },
+			wantErr: "image number must be greater than 0",
+		},
+		{
+			name:    "unknown image puller",
+			mutate:  func(c *Config) { c.Image.Puller = "docker" },
+			wantErr: `invalid image puller "docker"`,
+		},
+		{
+			name:   "crictl image puller",
+			mutate: func(c *Config) { c.Image.Puller = PullerCrictl },
+		},
+		{
+			name:    "unknown image cache mode",
+			mutate:  func(c *Config) { c.Image.Cache = "warm" },
+			wantErr: `invalid image cache mode "warm"`,
+		},
+		{
+			name:   "hot image cache mode",
+			mutate: func(c *Config) { c.Image.Cache = CacheHot },
+		},
+		{
+			name:    "dragonfly preheat without manager token",
+			mutate:  func(c *Config) { c.Dragonfly.Preheat = true },
+			wantErr: "manager token must not be empty for preheat",
+		},
+		{
+			name:    "image preheat without manager token",
+			mutate:  func(c *Config) { c.Image.Preheat = true },
+			wantErr: "manager token must not be empty for preheat",
+		},
+		{
+			name: "preheat with manager token",
+			mutate: func(c *Config) {
+				c.Image.Preheat = true
+				c.Manager.Token = "token"
+				c.Manager.Scope = "all_peers"
+			},
+		},
+		{
+			name:    "unknown manager preheat scope",
+			mutate:  func(c *Config) { c.Manager.Scope = "all_nodes" },
+			wantErr: `invalid manager preheat scope "all_nodes"`,
+		},
+		{
+			name:    "unknown output format",
+			mutate:  func(c *Config) { c.Report.Format = "xml" },
+			wantErr: `invalid output format "xml"`,
+		},
+		{
+			name:    "negative compare threshold",
+			mutate:  func(c *Config) { c.Compare.BackToSourceRateThreshold = -1 },
+			wantErr: "compare thresholds must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			tt.mutate(c)
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate failed: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		registry   string
+		images     []string
+		references []string
+	}{
+		{
+			name:       "without registry",
+			images:     []string{"library/nginx:latest", "docker.io/library/redis"},
+			references: []string{"library/nginx:latest", "docker.io/library/redis"},
+		},
+		{
+			name:     "with registry",
+			registry: "127.0.0.1:30500/",
+			images: []string{
+				"library/nginx:latest",
+				"nginx",
+				"docker.io/library/redis",
+				"localhost/busybox",
+				"127.0.0.1:5000/alpine",
+				"team/app@sha256:abc",
+			},
+			references: []string{
+				"127.0.0.1:30500/library/nginx:latest",
+				"127.0.0.1:30500/nginx",
+				"docker.io/library/redis",
+				"localhost/busybox",
+				"127.0.0.1:5000/alpine",
+				"127.0.0.1:30500/team/app@sha256:abc",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ImageConfig{Registry: tt.registry, Images: tt.images}
+			if references := c.References(); !slices.Equal(references, tt.references) {
+				t.Errorf("References() = %q, want %q", references, tt.references)
+			}
+		})
+	}
+}
+
+func TestFileSizeLevels(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileSize      string
+		fileSizeLevel string
+		expected      []backend.FileSizeLevel
+	}{
+		{
+			name:     "all file size levels",
+			expected: backend.FileSizeLevels,
+		},
+		{
+			name:          "file size level",
+			fileSizeLevel: "medium",
+			expected:      []backend.FileSizeLevel{backend.FileSizeLevelMedium},
+		},
+		{
+			name:     "file sizes in order",
+			fileSize: "3GiB, 268435456,small",
+			expected: []backend.FileSizeLevel{"3GiB", "256MiB", backend.FileSizeLevelSmall},
+		},
+		{
+			name:     "invalid and empty file sizes are skipped",
+			fileSize: "1KB,,0,huge,1025",
+			expected: []backend.FileSizeLevel{"1KB", "1025B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DragonflyConfig{FileSize: tt.fileSize, FileSizeLevel: tt.fileSizeLevel}
+			if levels := c.FileSizeLevels(); !slices.Equal(levels, tt.expected) {
+				t.Errorf("FileSizeLevels() = %q, want %q", levels, tt.expected)
+			}
+		})
+	}
+}
@@ -18,22 +18,44 @@ package dragonfly
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/url"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dragonflyoss/perf-tests/pkg/backend"
 	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/manager"
 	"github.com/dragonflyoss/perf-tests/pkg/stats"
-	"github.com/dragonflyoss/perf-tests/pkg/util"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 const (
-	OutputDir = "/tmp"
+	// curlTimingPrefix is the prefix of the timing line written by curl.
+	curlTimingPrefix = "dfbench_timing"
+
+	// curlTimingFormat is the curl write-out format of the connect, TTFB and total time in seconds.
+	curlTimingFormat = "\\n" + curlTimingPrefix + " %{time_connect} %{time_starttransfer} %{time_total}\\n"
+
+	// sequentialScript reads the file of the size from head to tail in chunks by the curl command,
+	// the command reads the range from $start to $end.
+	sequentialScript = `size=%d; chunk=%d; start=0
+while [ "$start" -lt "$size" ]; do
+  end=$((start + chunk - 1)); [ "$end" -ge "$size" ] && end=$((size - 1))
+  %s || exit 1
+  start=$((start + chunk))
+done`
 )
 
 // Dragonfly represents a benchmark runner for Dragonfly.
@@ -50,255 +72,1013 @@ type Dragonfly interface {
 	// DownloadFileByProxy downloads file by proxy.
 	DownloadFileByProxy(context.Context, backend.FileSizeLevel) error
 
+	// DownloadFileByDfcache downloads file by dfcache.
+	DownloadFileByDfcache(context.Context, backend.FileSizeLevel) error
+
+	// DownloadFileByDfstore downloads file by dfstore.
+	DownloadFileByDfstore(context.Context, backend.FileSizeLevel) error
+
+	// DownloadFileByDirect downloads file from the file server directly.
+	DownloadFileByDirect(context.Context, backend.FileSizeLevel) error
+
 	// Cleanup cleans up the downloaded files.
 	Cleanup(context.Context) error
 }
 
 // dragonfly implements the Dragonfly interface.
 type dragonfly struct {
-	// namespace is the namespace of the benchmark.
-	namespace string
+	// config is the configuration of the benchmark.
+	config *config.DragonflyConfig
+
+	// executor is the executor to run commands in the client pods.
+	executor executor.Executor
 
 	// fileServer is the file server of the benchmark.
 	fileServer backend.FileServer
 
 	// stats is the statistics of the benchmark.
 	stats stats.Stats
+
+	// manager is the client of the manager to preheat the tasks.
+	manager manager.Manager
+
+	// downloadURLs is the URLs reused by the downloader and file size level in the hot cache mode.
+	downloadURLs map[string][]*url.URL
+
+	// taskPools is the pools of the tasks read in turn by the downloader and file size level
+	// in the sequential range mode.
+	taskPools map[string]*taskPool
+
+	// digests is the expected digests of the files by file size level and byte range in the verification.
+	digests *sync.Map
+}
+
+// taskPool represents a fixed pool of the tasks read in turn.
+type taskPool struct {
+	// urls is the URLs of the tasks.
+	urls []*url.URL
+
+	// next is the number of the tasks taken from the pool.
+	next int
 }
 
 // New creates a new benchmark runner for Dragonfly.
-func New(namespace string, fileServer backend.FileServer, stats stats.Stats) Dragonfly {
-	return &dragonfly{namespace, fileServer, stats}
+func New(config *config.DragonflyConfig, executor executor.Executor, fileServer backend.FileServer, stats stats.Stats, manager manager.Manager) Dragonfly {
+	return &dragonfly{config, executor, fileServer, stats, manager, make(map[string][]*url.URL), make(map[string]*taskPool), &sync.Map{}}
 }
 
 // Run runs all benchmarks by downloader.
 func (d *dragonfly) Run(ctx context.Context, downloader string) error {
+	for _, fileSizeLevel := range backend.FileSizeLevels {
+		if err := d.RunByFileSizes(ctx, downloader, fileSizeLevel); err != nil {
+			logrus.Errorf("failed to download %s file by %s: %v", fileSizeLevel, downloader, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunByFileSizes runs benchmarks by file sizes, the warmup iterations are run first and
+// discarded, then the measured iterations are run with the cooldown between iterations.
+func (d *dragonfly) RunByFileSizes(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel) error {
+	var download func(context.Context, backend.FileSizeLevel) error
 	switch downloader {
 	case config.DownloaderDfget:
-		return d.runByDfget(ctx)
+		download = d.DownloadFileByDfget
 	case config.DownloaderProxy:
-		return d.runByProxy(ctx)
+		download = d.DownloadFileByProxy
+	case config.DownloaderDfcache:
+		download = d.DownloadFileByDfcache
+	case config.DownloaderDfstore:
+		download = d.DownloadFileByDfstore
+	case config.DownloaderDirect:
+		download = d.DownloadFileByDirect
 	default:
 		return errors.New("unknown downloader")
 	}
+
+	for i := uint32(1); i <= d.config.Warmup; i++ {
+		logrus.Debugf("warming up %s file by %s %d/%d", fileSizeLevel, downloader, i, d.config.Warmup)
+		downloadURLs, _, err := d.getDownloadURLs(downloader, fileSizeLevel, false)
+		if err != nil {
+			return err
+		}
+
+		if _, err := d.seed(ctx, downloader, fileSizeLevel, downloadURLs); err != nil {
+			logrus.Errorf("failed to warm up: %v", err)
+			return err
+		}
+
+		if _, err := d.downloadFiles(ctx, downloader, fileSizeLevel, downloadURLs, false); err != nil {
+			logrus.Errorf("failed to warm up: %v", err)
+			return err
+		}
+
+		if err := d.cooldown(ctx); err != nil {
+			return err
+		}
+	}
+
+	for i := uint32(1); i <= d.config.Number; i++ {
+		logrus.Debugf("downloading %s file by %s %d/%d", fileSizeLevel, downloader, i, d.config.Number)
+		if err := download(ctx, fileSizeLevel); err != nil {
+			return err
+		}
+
+		if i < d.config.Number {
+			if err := d.cooldown(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DownloadFileByDfget downloads file by dfget.
+func (d *dragonfly) DownloadFileByDfget(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
+	return d.download(ctx, config.DownloaderDfget, fileSizeLevel)
+}
+
+// DownloadFileByProxy downloads file by proxy, the whole file or the byte ranges by the range mode.
+func (d *dragonfly) DownloadFileByProxy(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
+	return d.download(ctx, config.DownloaderProxy, fileSizeLevel)
+}
+
+// DownloadFileByDfcache downloads file by dfcache.
+func (d *dragonfly) DownloadFileByDfcache(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
+	return d.download(ctx, config.DownloaderDfcache, fileSizeLevel)
 }
 
-// Run runs all benchmarks by dfget.
-func (d *dragonfly) runByDfget(ctx context.Context) error {
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelNano); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
+// DownloadFileByDfstore downloads file by dfstore.
+func (d *dragonfly) DownloadFileByDfstore(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
+	return d.download(ctx, config.DownloaderDfstore, fileSizeLevel)
+}
+
+// DownloadFileByDirect downloads file from the file server directly.
+func (d *dragonfly) DownloadFileByDirect(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
+	return d.download(ctx, config.DownloaderDirect, fileSizeLevel)
+}
+
+// download downloads the file in all client pods by the downloader and collects the statistics,
+// the client metrics are not collected for the direct downloads bypassing the dfdaemon.
+func (d *dragonfly) download(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel) error {
+	// The tasks of the previous iteration are reused in the hot cache mode.
+	downloadURLs, warm, err := d.getDownloadURLs(downloader, fileSizeLevel, d.config.Cache == config.CacheHot)
+	if err != nil {
 		return err
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelMicro); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
-		return err
+	if !warm {
+		uploads, err := d.seed(ctx, downloader, fileSizeLevel, downloadURLs)
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range uploads {
+			d.stats.AddTiming(upload)
+		}
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelSmall); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
+	if err := d.measure(ctx, downloader, fileSizeLevel, downloadURLs, warm, false, false); err != nil {
 		return err
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelMedium); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
-		return err
+	// Download the faulty tasks after the tasks without faults to compare the latency, dfcache and
+	// dfstore are skipped as their downloads do not fetch from the file server.
+	if d.config.Origin.Fault != "" && !d.sequential(downloader) && downloader != config.DownloaderDfcache && downloader != config.DownloaderDfstore {
+		faultyURLs, err := d.getFaultyURLs(downloader, fileSizeLevel)
+		if err != nil {
+			return err
+		}
+
+		if err := d.measure(ctx, downloader, fileSizeLevel, faultyURLs, false, false, true); err != nil {
+			return err
+		}
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelLarge); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
-		return err
+	// Download the preheated tasks after the tasks without preheat to compare the latency.
+	if !d.config.Preheat || warm || d.sequential(downloader) || (downloader != config.DownloaderDfget && downloader != config.DownloaderProxy) {
+		return nil
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelXLarge); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
+	preheatURLs, _, err := d.getDownloadURLs(downloader, fileSizeLevel, false)
+	if err != nil {
 		return err
 	}
 
-	if err := d.DownloadFileByDfget(ctx, backend.FileSizeLevelXXLarge); err != nil {
-		logrus.Errorf("failed to download %s file by dfget: %v", backend.FileSizeLevelNano, err)
+	timing, err := d.preheat(ctx, downloader, fileSizeLevel, preheatURLs)
+	if err != nil {
 		return err
 	}
+	d.stats.AddTiming(timing)
 
-	return nil
+	return d.measure(ctx, downloader, fileSizeLevel, preheatURLs, false, true, false)
 }
 
-// Run runs all benchmarks by proxy.
-func (d *dragonfly) runByProxy(ctx context.Context) error {
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelNano); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
-		return err
+// measure downloads the files in all client pods and records the client side timings and the client metrics.
+// The failed downloads of the faulty tasks are recorded as failed, and the client metrics of them are not
+// collected.
+func (d *dragonfly) measure(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, downloadURLs []*url.URL, warm, preheated, faulty bool) error {
+	viaDfdaemon := downloader != config.DownloaderDirect && !faulty
+	if viaDfdaemon {
+		if err := d.stats.ResetClientMetrics(ctx); err != nil {
+			logrus.Errorf("failed to reset client metrics: %v", err)
+			return err
+		}
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelMicro); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
-		return err
+	// The traffic served by the file server for the tasks is counted from zero by removing the traffic
+	// of the tasks before the downloads, e.g. the traffic of the cold downloads of the warm tasks.
+	countOrigin := d.config.OriginTraffic && !faulty
+	ids := uniqueTasks(downloadURLs)
+	if countOrigin {
+		if _, err := d.takeOriginTraffic(ctx, downloader, ids); err != nil {
+			return err
+		}
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelSmall); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
+	timings, err := d.downloadFiles(ctx, downloader, fileSizeLevel, downloadURLs, faulty)
+	if err != nil {
 		return err
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelMedium); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
-		return err
+	if countOrigin {
+		tasks, err := d.takeOriginTraffic(ctx, downloader, ids)
+		if err != nil {
+			return err
+		}
+
+		traffic := &stats.OriginTraffic{Downloader: downloader, FileSizeLevel: fileSizeLevel, Warm: warm, Preheated: preheated}
+		addTaskTraffic(traffic, ids, tasks)
+		d.stats.AddOriginTraffic(traffic)
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelLarge); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
-		return err
+	for _, timing := range timings {
+		timing.Warm = warm
+		timing.Preheated = preheated
+		timing.Faulty = faulty
+		d.stats.AddTiming(timing)
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelXLarge); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
-		return err
+	if !viaDfdaemon {
+		return nil
 	}
 
-	if err := d.DownloadFileByProxy(ctx, backend.FileSizeLevelXXLarge); err != nil {
-		logrus.Errorf("failed to download %s file by proxy: %v", backend.FileSizeLevelNano, err)
+	if err := d.stats.CollectClientMetrics(ctx, downloader, fileSizeLevel, warm, preheated); err != nil {
+		logrus.Errorf("failed to collect client metrics: %v", err)
 		return err
 	}
 
 	return nil
 }
 
-// RunByFileSizes runs benchmarks by file sizes.
-func (d *dragonfly) RunByFileSizes(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel) error {
-	switch downloader {
-	case config.DownloaderDfget:
-		return d.DownloadFileByDfget(ctx, fileSizeLevel)
-	case config.DownloaderProxy:
-		return d.DownloadFileByProxy(ctx, fileSizeLevel)
-	default:
-		return errors.New("unknown downloader")
+// takeOriginTraffic returns the traffic served by the file server for the tasks of the downloader by uuid
+// and removes it from the file server, the traffic of the paths of a task is added up, and the request
+// is sent in the first client pod.
+func (d *dragonfly) takeOriginTraffic(ctx context.Context, downloader string, ids []string) (map[string]*backend.TaskTraffic, error) {
+	pods, err := d.getClientPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statsURL, err := d.fileServer.GetStatsURL(downloader, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := d.executor.Exec(ctx, pods[0], "sh", "-c", fmt.Sprintf("curl -sSf --noproxy '*' -X DELETE '%s'", statsURL.String()))
+	if err != nil {
+		logrus.Errorf("failed to get origin traffic: %v \nmessage: %s", err, string(output))
+		return nil, err
 	}
+
+	var tasks []*backend.TaskTraffic
+	if err := json.Unmarshal(output, &tasks); err != nil {
+		return nil, fmt.Errorf("invalid origin traffic %q: %w", string(output), err)
+	}
+
+	traffic := make(map[string]*backend.TaskTraffic, len(tasks))
+	for _, task := range tasks {
+		if t, ok := traffic[task.UUID]; ok {
+			t.Requests += task.Requests
+			t.Bytes += task.Bytes
+			continue
+		}
+
+		traffic[task.UUID] = task
+	}
+
+	return traffic, nil
 }
 
-// DownloadFileByDfget downloads file by dfget.
-func (d *dragonfly) DownloadFileByDfget(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
-	if err := d.stats.ResetClientMetrics(ctx); err != nil {
-		logrus.Errorf("failed to reset client metrics: %v", err)
-		return err
+// addTaskTraffic adds the traffic served by the file server for the tasks of the uuids during the
+// downloads to the traffic.
+func addTaskTraffic(traffic *stats.OriginTraffic, ids []string, tasks map[string]*backend.TaskTraffic) {
+	for _, id := range ids {
+		traffic.Tasks++
+		if task, ok := tasks[id]; ok {
+			traffic.Requests += task.Requests
+			traffic.Bytes += task.Bytes
+		}
+	}
+}
+
+// uniqueTasks returns the unique uuids of the tasks of the URLs, the URLs of a task are repeated in
+// the sequential range mode.
+func uniqueTasks(downloadURLs []*url.URL) []string {
+	var ids []string
+	for _, downloadURL := range downloadURLs {
+		if id := downloadURL.Query().Get("uuid"); !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
 	}
 
+	return ids
+}
+
+// preheat preheats the files of the URLs by the manager and returns the timing of the preheat job,
+// the request is sent in the first client pod.
+func (d *dragonfly) preheat(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, downloadURLs []*url.URL) (*stats.Timing, error) {
 	pods, err := d.getClientPods(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	downloadURL, err := d.fileServer.GetFileURL(fileSizeLevel, "dfget")
+	urls := make([]string, 0, len(downloadURLs))
+	for _, downloadURL := range downloadURLs {
+		urls = append(urls, downloadURL.String())
+	}
+
+	start := time.Now()
+	job, err := d.manager.Preheat(ctx, pods[0], &manager.PreheatArgs{Type: manager.PreheatTypeFile, URLs: urls})
 	if err != nil {
-		logrus.Errorf("failed to get file URL: %v", err)
-		return err
+		logrus.Errorf("failed to preheat: %v", err)
+		return nil, err
 	}
 
-	var eg errgroup.Group
-	for _, pod := range pods {
-		podExec := util.NewPodExec(d.namespace, pod, "client")
-		eg.Go(func(podExec *util.PodExec) func() error {
-			return func() error {
-				if err := d.downloadFileByDfget(ctx, podExec, downloadURL, fileSizeLevel); err != nil {
-					return err
+	logrus.Debugf("preheat job %d is finished", job.ID)
+	return &stats.Timing{
+		PodName:       pods[0],
+		Downloader:    downloader,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+		Preheated:     true,
+		Preheat:       true,
+	}, nil
+}
+
+// seed imports the files of the URLs into the cache by dfcache or puts them into the object storage by
+// dfstore in the first client pod, and returns the client side timings of the uploads. Nothing is done
+// for the other downloaders fetching the files from the file server.
+func (d *dragonfly) seed(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, downloadURLs []*url.URL) ([]*stats.Timing, error) {
+	if downloader != config.DownloaderDfcache && downloader != config.DownloaderDfstore {
+		return nil, nil
+	}
+
+	pods, err := d.getClientPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pod := pods[0]
+	endpoint := d.executor.GetEndpoint(pod)
+
+	uploads := make([]*stats.Timing, 0, len(downloadURLs))
+	for _, downloadURL := range downloadURLs {
+		sourcePath, err := d.getOutput(fileSizeLevel, "source")
+		if err != nil {
+			logrus.Errorf("failed to get output path: %v", err)
+			return nil, err
+		}
+
+		output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("curl -sS --noproxy '*' '%s' --create-dirs --output %s", downloadURL.String(), sourcePath))
+		if err != nil {
+			logrus.Errorf("failed to fetch file: %v \nmessage: %s", err, string(output))
+			return nil, err
+		}
+
+		var cmd string
+		switch downloader {
+		case config.DownloaderDfcache:
+			cmd = fmt.Sprintf("dfcache import --id %s%s %s", cacheID(downloadURL), endpointFlag(endpoint.Socket), sourcePath)
+		case config.DownloaderDfstore:
+			cmd = fmt.Sprintf("dfstore cp --endpoint http://%s %s %s", endpoint.ObjectStorageAddr, sourcePath, d.objectURL(downloadURL, fileSizeLevel))
+		}
+
+		start := time.Now()
+		output, err = d.executor.Exec(ctx, pod, "sh", "-c", cmd)
+		if err != nil {
+			logrus.Errorf("failed to upload file: %v \nmessage: %s", err, string(output))
+			return nil, err
+		}
+
+		logrus.Debugf("%s output: %s", downloader, string(output))
+		uploads = append(uploads, &stats.Timing{
+			PodName:       pod,
+			Downloader:    downloader,
+			FileSizeLevel: fileSizeLevel,
+			Elapsed:       time.Since(start),
+			Upload:        true,
+		})
+	}
+
+	return uploads, nil
+}
+
+// getDownloadURLs returns the URLs of the parallel downloads, every URL is a new task unless reuse is
+// true, then the URLs of the previous call are returned and warm is true if they are reused.
+func (d *dragonfly) getDownloadURLs(downloader string, fileSizeLevel backend.FileSizeLevel, reuse bool) ([]*url.URL, bool, error) {
+	key := fmt.Sprintf("%s/%s", downloader, fileSizeLevel)
+	if d.sequential(downloader) {
+		return d.getPooledURLs(key, downloader, fileSizeLevel)
+	}
+
+	if downloadURLs, ok := d.downloadURLs[key]; ok && reuse {
+		return downloadURLs, true, nil
+	}
+
+	parallelism := int(max(d.config.Parallelism, 1))
+	downloadURLs := make([]*url.URL, 0, parallelism)
+	for range parallelism {
+		downloadURL, err := d.fileServer.GetFileURL(fileSizeLevel, downloader)
+		if err != nil {
+			logrus.Errorf("failed to get file URL: %v", err)
+			return nil, false, err
+		}
+
+		downloadURLs = append(downloadURLs, downloadURL)
+	}
+
+	if reuse {
+		d.downloadURLs[key] = downloadURLs
+	}
+
+	return downloadURLs, false, nil
+}
+
+// getFaultyURLs returns the URLs of the new faulty tasks of the parallel downloads, the responses of
+// the tasks are injected with the fault of the origin.
+func (d *dragonfly) getFaultyURLs(downloader string, fileSizeLevel backend.FileSizeLevel) ([]*url.URL, error) {
+	parallelism := int(max(d.config.Parallelism, 1))
+	faultyURLs := make([]*url.URL, 0, parallelism)
+	for range parallelism {
+		faultyURL, err := d.fileServer.GetFaultyFileURL(fileSizeLevel, downloader)
+		if err != nil {
+			logrus.Errorf("failed to get faulty file URL: %v", err)
+			return nil, err
+		}
+
+		faultyURLs = append(faultyURLs, faultyURL)
+	}
+
+	return faultyURLs, nil
+}
+
+// getPooledURLs returns the URLs of the next tasks in the pool of the downloader and file size level
+// by the parallelism, the pool is created at the first call, and the tasks are warm if all tasks in
+// the pool have been taken before.
+func (d *dragonfly) getPooledURLs(key string, downloader string, fileSizeLevel backend.FileSizeLevel) ([]*url.URL, bool, error) {
+	pool, ok := d.taskPools[key]
+	if !ok {
+		pool = &taskPool{urls: make([]*url.URL, 0, d.config.TaskPoolSize)}
+		for range d.config.TaskPoolSize {
+			downloadURL, err := d.fileServer.GetFileURL(fileSizeLevel, downloader)
+			if err != nil {
+				logrus.Errorf("failed to get file URL: %v", err)
+				return nil, false, err
+			}
+
+			pool.urls = append(pool.urls, downloadURL)
+		}
+
+		d.taskPools[key] = pool
+	}
+
+	warm := pool.next >= len(pool.urls)
+	parallelism := int(max(d.config.Parallelism, 1))
+	downloadURLs := make([]*url.URL, 0, parallelism)
+	for range parallelism {
+		downloadURLs = append(downloadURLs, pool.urls[pool.next%len(pool.urls)])
+		pool.next++
+	}
+
+	return downloadURLs, warm, nil
+}
+
+// sequential returns true if the files are read in chunks by the downloader in the sequential range mode.
+func (d *dragonfly) sequential(downloader string) bool {
+	return downloader == config.DownloaderProxy && d.config.RangeMode == config.RangeModeSequential
+}
+
+// downloadFiles downloads the URLs in all client pods by the downloader and returns the client side
+// timings of the downloads. The pods are started in waves by the start pattern, at most concurrency
+// pods download at the same time, and every pod downloads all URLs in parallel, the task of every
+// URL is shared by all pods. The failed downloads are returned as the failed timings instead of the
+// error if faulty is true.
+func (d *dragonfly) downloadFiles(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, downloadURLs []*url.URL, faulty bool) ([]*stats.Timing, error) {
+	pods, err := d.getClientPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parallelism := len(downloadURLs)
+
+	var limit *semaphore.Weighted
+	if d.config.Concurrency > 0 {
+		limit = semaphore.NewWeighted(int64(d.config.Concurrency))
+	}
+
+	results := make([][]*stats.Timing, len(pods)*parallelism)
+	downloadPod := func(i int, wave int) error {
+		if limit != nil {
+			if err := limit.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer limit.Release(1)
+		}
+
+		var eg errgroup.Group
+		for j, downloadURL := range downloadURLs {
+			eg.Go(func(j int, downloadURL *url.URL) func() error {
+				return func() error {
+					start := time.Now()
+					timings, err := d.downloadFile(ctx, downloader, pods[i], downloadURL, fileSizeLevel)
+					if err != nil {
+						if !faulty || ctx.Err() != nil {
+							return err
+						}
+
+						timings = []*stats.Timing{{
+							PodName:       pods[i],
+							Downloader:    downloader,
+							FileSizeLevel: fileSizeLevel,
+							Elapsed:       time.Since(start),
+							Failed:        true,
+						}}
+					}
+
+					for _, timing := range timings {
+						timing.Wave = wave
+					}
+
+					results[i*parallelism+j] = timings
+					return nil
 				}
-				return nil
+			}(j, downloadURL))
+		}
+
+		return eg.Wait()
+	}
+
+	waves := d.startWaves(len(pods))
+	if d.config.StartPattern == config.StartPatternRamp {
+		// Start the waves linearly over the ramp duration without waiting for the previous waves.
+		var eg errgroup.Group
+		for wave, indexes := range waves {
+			delay := d.config.RampDuration * time.Duration(wave) / time.Duration(len(waves))
+			for _, i := range indexes {
+				eg.Go(func(i int, wave int) func() error {
+					return func() error {
+						select {
+						case <-time.After(delay):
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+
+						return downloadPod(i, wave)
+					}
+				}(i, wave))
 			}
-		}(podExec))
+		}
+
+		if err := eg.Wait(); err != nil {
+			logrus.Errorf("error processing pods: %v", err)
+			return nil, err
+		}
+
+		return slices.Concat(results...), nil
 	}
 
-	if err := eg.Wait(); err != nil {
-		logrus.Errorf("error processing pods: %v", err)
-		return err
+	for wave, indexes := range waves {
+		logrus.Debugf("starting wave %d/%d of %d pods", wave+1, len(waves), len(indexes))
+		var eg errgroup.Group
+		for _, i := range indexes {
+			eg.Go(func(i int, wave int) func() error {
+				return func() error {
+					return downloadPod(i, wave)
+				}
+			}(i, wave))
+		}
+
+		if err := eg.Wait(); err != nil {
+			logrus.Errorf("error processing pods: %v", err)
+			return nil, err
+		}
 	}
 
-	if err := d.stats.CollectClientMetrics(ctx, config.DownloaderDfget, fileSizeLevel); err != nil {
-		logrus.Errorf("failed to collect client metrics: %v", err)
-		return err
+	return slices.Concat(results...), nil
+}
+
+// startWaves returns the indexes of the pods in every wave by the start pattern.
+func (d *dragonfly) startWaves(pods int) [][]int {
+	indexes := make([]int, pods)
+	for i := range indexes {
+		indexes[i] = i
 	}
 
-	return nil
+	switch d.config.StartPattern {
+	case config.StartPatternSeedFirst:
+		if pods <= 1 {
+			return [][]int{indexes}
+		}
+
+		return [][]int{indexes[:1], indexes[1:]}
+	case config.StartPatternRamp, config.StartPatternWaves:
+		size := int(max(d.config.WaveSize, 1))
+		var waves [][]int
+		for start := 0; start < pods; start += size {
+			waves = append(waves, indexes[start:min(start+size, pods)])
+		}
+
+		return waves
+	default:
+		return [][]int{indexes}
+	}
+}
+
+// downloadFile downloads the file in the pod by the downloader, and returns the client side timing
+// of every request, several requests are sent only in the sequential range mode.
+func (d *dragonfly) downloadFile(ctx context.Context, downloader string, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) ([]*stats.Timing, error) {
+	var (
+		timing *stats.Timing
+		err    error
+	)
+	switch downloader {
+	case config.DownloaderDfget:
+		timing, err = d.downloadFileByDfget(ctx, pod, downloadURL, fileSizeLevel)
+	case config.DownloaderProxy:
+		return d.downloadFileByProxy(ctx, pod, downloadURL, fileSizeLevel)
+	case config.DownloaderDfcache:
+		timing, err = d.downloadFileByDfcache(ctx, pod, downloadURL, fileSizeLevel)
+	case config.DownloaderDfstore:
+		timing, err = d.downloadFileByDfstore(ctx, pod, downloadURL, fileSizeLevel)
+	case config.DownloaderDirect:
+		timing, err = d.downloadFileByDirect(ctx, pod, downloadURL, fileSizeLevel)
+	default:
+		return nil, errors.New("unknown downloader")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []*stats.Timing{timing}, nil
 }
 
 // downloadFileByDfget downloads file by dfget.
-func (d *dragonfly) downloadFileByDfget(ctx context.Context, podExec *util.PodExec, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) error {
+func (d *dragonfly) downloadFileByDfget(ctx context.Context, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) (*stats.Timing, error) {
 	outputPath, err := d.getOutput(fileSizeLevel, "dfget")
 	if err != nil {
 		logrus.Errorf("failed to get output path: %v", err)
-		return err
+		return nil, err
 	}
 
-	output, err := podExec.Command(ctx, "sh", "-c", fmt.Sprintf("dfget '%s' --output %s", downloadURL.String(), outputPath)).CombinedOutput()
+	args := fmt.Sprintf("'%s' --output %s%s", downloadURL.String(), outputPath, endpointFlag(d.executor.GetEndpoint(pod).Socket))
+
+	start := time.Now()
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("mkdir -p %s && dfget %s", d.config.OutputDir, args))
 	if err != nil {
 		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
-		return err
+		return nil, err
 	}
 
 	logrus.Debugf("dfget output: %s", string(output))
-	return nil
+	timing := &stats.Timing{
+		PodName:       pod,
+		Downloader:    config.DownloaderDfget,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+	}
+
+	if err := d.verify(ctx, pod, outputPath, fileSizeLevel, "", timing); err != nil {
+		return nil, err
+	}
+
+	return timing, nil
 }
 
-// DownloadFileByProxy downloads file by proxy.
-func (d *dragonfly) DownloadFileByProxy(ctx context.Context, fileSizeLevel backend.FileSizeLevel) error {
-	if err := d.stats.ResetClientMetrics(ctx); err != nil {
-		logrus.Errorf("failed to reset client metrics: %v", err)
-		return err
+// downloadFileByProxy downloads file by proxy, the whole file, a fixed or random byte range, or all
+// chunks of the file in the sequential range mode.
+func (d *dragonfly) downloadFileByProxy(ctx context.Context, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) ([]*stats.Timing, error) {
+	outputPath, err := d.getOutput(fileSizeLevel, "proxy")
+	if err != nil {
+		logrus.Errorf("failed to get output path: %v", err)
+		return nil, err
 	}
 
-	pods, err := d.getClientPods(ctx)
+	proxyURL := fmt.Sprintf("http://%s", d.executor.GetEndpoint(pod).ProxyAddr)
+	cmd := fmt.Sprintf("curl -sS -x %s '%s' --create-dirs --output %s -w '%s'", proxyURL, downloadURL.String(), outputPath, curlTimingFormat)
+
+	var byteRange string
+	switch d.config.RangeMode {
+	case config.RangeModeFixed:
+		byteRange = d.config.ByteRange()
+		cmd += fmt.Sprintf(" -r %s", byteRange)
+	case config.RangeModeRandom:
+		byteRange = randomRange(fileSizeLevel.Bytes(), d.config.ChunkSize)
+		cmd += fmt.Sprintf(" -r %s", byteRange)
+	case config.RangeModeSequential:
+		// Read the chunks in a loop in the pod to avoid the exec overhead of every chunk,
+		// curl writes a timing line for every chunk.
+		cmd = fmt.Sprintf(sequentialScript, fileSizeLevel.Bytes(), d.config.ChunkSize, cmd+" -r $start-$end")
+	}
+
+	start := time.Now()
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", cmd)
 	if err != nil {
-		return err
+		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
+		return nil, err
+	}
+
+	logrus.Debugf("curl output: %s", string(output))
+	timing := &stats.Timing{
+		PodName:       pod,
+		Downloader:    config.DownloaderProxy,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+		Range:         byteRange,
 	}
 
-	downloadURL, err := d.fileServer.GetFileURL(fileSizeLevel, "proxy")
+	if d.config.RangeMode == config.RangeModeSequential {
+		// The exec overhead is shared by the chunks, so the elapsed time of every chunk is the total time reported by curl.
+		timings, err := parseCurlTimings(output, timing)
+		if err != nil {
+			logrus.Errorf("failed to parse curl timing: %v", err)
+			return nil, err
+		}
+
+		for _, timing := range timings {
+			timing.Elapsed = timing.Total
+		}
+
+		return timings, nil
+	}
+
+	if err := parseCurlTiming(output, timing); err != nil {
+		logrus.Warnf("failed to parse curl timing: %v", err)
+	}
+
+	if err := d.verify(ctx, pod, outputPath, fileSizeLevel, byteRange, timing); err != nil {
+		return nil, err
+	}
+
+	return []*stats.Timing{timing}, nil
+}
+
+// randomRange returns a byte range of the chunk size at a random offset of the file, the whole file
+// is returned if the chunk size is not less than the file size.
+func randomRange(size, chunkSize uint64) string {
+	if chunkSize >= size {
+		return fmt.Sprintf("0-%d", size-1)
+	}
+
+	start := rand.Uint64N(size - chunkSize + 1)
+	return fmt.Sprintf("%d-%d", start, start+chunkSize-1)
+}
+
+// downloadFileByDfcache exports the file imported from the URL by dfcache.
+func (d *dragonfly) downloadFileByDfcache(ctx context.Context, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) (*stats.Timing, error) {
+	outputPath, err := d.getOutput(fileSizeLevel, "dfcache")
 	if err != nil {
-		logrus.Errorf("failed to get file URL: %v", err)
-		return err
+		logrus.Errorf("failed to get output path: %v", err)
+		return nil, err
 	}
 
-	var eg errgroup.Group
-	for _, pod := range pods {
-		podExec := util.NewPodExec(d.namespace, pod, "client")
-		eg.Go(func(ctx context.Context, podExec *util.PodExec) func() error {
-			return func() error {
-				if err := d.downloadFileByProxy(ctx, podExec, downloadURL, fileSizeLevel); err != nil {
-					return err
-				}
-				return nil
-			}
-		}(ctx, podExec))
+	start := time.Now()
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("mkdir -p %s && dfcache export --output %s%s %s", d.config.OutputDir, outputPath, endpointFlag(d.executor.GetEndpoint(pod).Socket), cacheID(downloadURL)))
+	if err != nil {
+		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
+		return nil, err
 	}
 
-	if err := eg.Wait(); err != nil {
-		logrus.Errorf("error processing pods: %v", err)
+	logrus.Debugf("dfcache output: %s", string(output))
+	timing := &stats.Timing{
+		PodName:       pod,
+		Downloader:    config.DownloaderDfcache,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+	}
+
+	if err := d.verify(ctx, pod, outputPath, fileSizeLevel, "", timing); err != nil {
+		return nil, err
+	}
+
+	return timing, nil
+}
+
+// downloadFileByDfstore copies the object put from the URL by dfstore.
+func (d *dragonfly) downloadFileByDfstore(ctx context.Context, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) (*stats.Timing, error) {
+	outputPath, err := d.getOutput(fileSizeLevel, "dfstore")
+	if err != nil {
+		logrus.Errorf("failed to get output path: %v", err)
+		return nil, err
+	}
+
+	start := time.Now()
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("mkdir -p %s && dfstore cp --endpoint http://%s %s %s", d.config.OutputDir, d.executor.GetEndpoint(pod).ObjectStorageAddr, d.objectURL(downloadURL, fileSizeLevel), outputPath))
+	if err != nil {
+		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
+		return nil, err
+	}
+
+	logrus.Debugf("dfstore output: %s", string(output))
+	timing := &stats.Timing{
+		PodName:       pod,
+		Downloader:    config.DownloaderDfstore,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+	}
+
+	if err := d.verify(ctx, pod, outputPath, fileSizeLevel, "", timing); err != nil {
+		return nil, err
+	}
+
+	return timing, nil
+}
+
+// downloadFileByDirect downloads file from the file server directly by curl without the dfdaemon.
+func (d *dragonfly) downloadFileByDirect(ctx context.Context, pod string, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) (*stats.Timing, error) {
+	outputPath, err := d.getOutput(fileSizeLevel, "direct")
+	if err != nil {
+		logrus.Errorf("failed to get output path: %v", err)
+		return nil, err
+	}
+
+	start := time.Now()
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("curl -sS --noproxy '*' '%s' --create-dirs --output %s -w '%s'", downloadURL.String(), outputPath, curlTimingFormat))
+	if err != nil {
+		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
+		return nil, err
+	}
+
+	logrus.Debugf("curl output: %s", string(output))
+	timing := &stats.Timing{
+		PodName:       pod,
+		Downloader:    config.DownloaderDirect,
+		FileSizeLevel: fileSizeLevel,
+		Elapsed:       time.Since(start),
+	}
+
+	if err := parseCurlTiming(output, timing); err != nil {
+		logrus.Warnf("failed to parse curl timing: %v", err)
+	}
+
+	if err := d.verify(ctx, pod, outputPath, fileSizeLevel, "", timing); err != nil {
+		return nil, err
+	}
+
+	return timing, nil
+}
+
+// verify compares the size and the SHA-256 digest of the downloaded file of the output path in the pod
+// with the expected ones advertised by the file server for the file or the byte range, and marks the
+// timing as corrupted if they mismatch. The file is hashed in the pod after the download is timed, and
+// nothing is done if the verification is disabled.
+func (d *dragonfly) verify(ctx context.Context, pod string, outputPath string, fileSizeLevel backend.FileSizeLevel, byteRange string, timing *stats.Timing) error {
+	if !d.config.Verify {
+		return nil
+	}
+
+	expected, err := d.getDigest(ctx, pod, fileSizeLevel, byteRange)
+	if err != nil {
+		logrus.Errorf("failed to get digest: %v", err)
 		return err
 	}
 
-	if err := d.stats.CollectClientMetrics(ctx, config.DownloaderProxy, fileSizeLevel); err != nil {
-		logrus.Errorf("failed to collect client metrics: %v", err)
+	// Nothing is written if the file is missing, e.g. the downloader exits successfully without the output.
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("[ ! -f %s ] || { wc -c < %s && sha256sum %s; }", outputPath, outputPath, outputPath))
+	if err != nil {
+		logrus.Errorf("failed to hash file: %v \nmessage: %s", err, string(output))
 		return err
 	}
 
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		logrus.Warnf("downloaded file %s in pod %s is missing, expected %d bytes of %s", outputPath, pod, expected.Size, expected.Digest)
+		timing.Corrupted = true
+		return nil
+	}
+
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid hash output %q", string(output))
+	}
+
+	size, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid hash output %q: %w", string(output), err)
+	}
+
+	actual := "sha256:" + fields[1]
+	if size != expected.Size || actual != expected.Digest {
+		logrus.Warnf("downloaded file %s in pod %s is corrupted, got %d bytes of %s, expected %d bytes of %s", outputPath, pod, size, actual, expected.Size, expected.Digest)
+		timing.Corrupted = true
+	}
+
 	return nil
 }
 
-// downloadFileByProxy downloads file by proxy.
-func (d *dragonfly) downloadFileByProxy(ctx context.Context, podExec *util.PodExec, downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) error {
-	outputPath, err := d.getOutput(fileSizeLevel, "proxy")
+// getDigest returns the expected digest of the file of the file size level or its byte range, the
+// digest is requested from the file server in the pod at the first call and cached.
+func (d *dragonfly) getDigest(ctx context.Context, pod string, fileSizeLevel backend.FileSizeLevel, byteRange string) (*backend.Digest, error) {
+	key := fmt.Sprintf("%s/%s", fileSizeLevel, byteRange)
+	if digest, ok := d.digests.Load(key); ok {
+		return digest.(*backend.Digest), nil
+	}
+
+	digestURL, err := d.fileServer.GetDigestURL(fileSizeLevel)
 	if err != nil {
-		logrus.Errorf("failed to get output path: %v", err)
-		return err
+		return nil, err
 	}
 
-	output, err := podExec.Command(ctx, "sh", "-c", fmt.Sprintf("curl -x %s '%s' --output %s", "http://127.0.0.1:4001", downloadURL.String(), outputPath)).CombinedOutput()
+	cmd := fmt.Sprintf("curl -sSf --noproxy '*' '%s'", digestURL.String())
+	if byteRange != "" {
+		cmd += fmt.Sprintf(" -r %s", byteRange)
+	}
+
+	output, err := d.executor.Exec(ctx, pod, "sh", "-c", cmd)
+	if err != nil {
+		logrus.Errorf("failed to request digest: %v \nmessage: %s", err, string(output))
+		return nil, err
+	}
+
+	digest := &backend.Digest{}
+	if err := json.Unmarshal(output, digest); err != nil {
+		return nil, fmt.Errorf("invalid digest %q: %w", string(output), err)
+	}
+
+	d.digests.Store(key, digest)
+	return digest, nil
+}
+
+// parseCurlTiming parses the timing written by curl with the curlTimingFormat into the timing.
+func parseCurlTiming(output []byte, timing *stats.Timing) error {
+	timings, err := parseCurlTimings(output, timing)
 	if err != nil {
-		logrus.Errorf("failed to download file: %v \nmessage: %s", err, string(output))
 		return err
 	}
 
-	logrus.Debugf("curl output: %s", string(output))
+	*timing = *timings[0]
 	return nil
 }
 
+// parseCurlTimings parses every timing line written by curl into a copy of the timing.
+func parseCurlTimings(output []byte, timing *stats.Timing) ([]*stats.Timing, error) {
+	var timings []*stats.Timing
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != curlTimingPrefix {
+			continue
+		}
+
+		var seconds [3]float64
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			seconds[i] = v
+		}
+
+		t := *timing
+		t.Connect = time.Duration(seconds[0] * float64(time.Second))
+		t.TTFB = time.Duration(seconds[1] * float64(time.Second))
+		t.Total = time.Duration(seconds[2] * float64(time.Second))
+		timings = append(timings, &t)
+	}
+
+	if len(timings) == 0 {
+		return nil, errors.New("curl timing not found")
+	}
+
+	return timings, nil
+}
+
+// cooldown waits for the cooldown duration between iterations.
+func (d *dragonfly) cooldown(ctx context.Context) error {
+	if d.config.Cooldown <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(d.config.Cooldown):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Cleanup cleans up the downloaded files.
 func (d *dragonfly) Cleanup(ctx context.Context) error {
 	pods, err := d.getClientPods(ctx)
@@ -308,10 +1088,9 @@ func (d *dragonfly) Cleanup(ctx context.Context) error {
 
 	var eg errgroup.Group
 	for _, pod := range pods {
-		podExec := util.NewPodExec(d.namespace, pod, "client")
-		eg.Go(func(podExec *util.PodExec) func() error {
+		eg.Go(func(pod string) func() error {
 			return func() error {
-				output, err := podExec.Command(ctx, "sh", "-c", fmt.Sprintf("rm -rf %s/*", OutputDir)).CombinedOutput()
+				output, err := d.executor.Exec(ctx, pod, "sh", "-c", fmt.Sprintf("rm -rf %s/*", d.config.OutputDir))
 				if err != nil {
 					logrus.Errorf("failed to cleanup: %v \nmessage: %s", err, string(output))
 					return err
@@ -319,7 +1098,7 @@ func (d *dragonfly) Cleanup(ctx context.Context) error {
 
 				return nil
 			}
-		}(podExec))
+		}(pod))
 	}
 
 	if err := eg.Wait(); err != nil {
@@ -332,7 +1111,7 @@ func (d *dragonfly) Cleanup(ctx context.Context) error {
 
 // getClientPods returns the client pods.
 func (d *dragonfly) getClientPods(ctx context.Context) ([]string, error) {
-	pods, err := util.GetPods(ctx, d.namespace, "component=client")
+	pods, err := d.executor.GetPods(ctx, "component=client")
 	if err != nil {
 		logrus.Errorf("failed to get pods: %v", err)
 		return nil, err
@@ -346,7 +1125,26 @@ func (d *dragonfly) getClientPods(ctx context.Context) ([]string, error) {
 	return pods, nil
 }
 
+// objectURL returns the object URL of dfstore for the file of the URL.
+func (d *dragonfly) objectURL(downloadURL *url.URL, fileSizeLevel backend.FileSizeLevel) string {
+	return fmt.Sprintf("d7y://%s/dfbench/%s-%s", d.config.Bucket, string(fileSizeLevel), downloadURL.Query().Get("uuid"))
+}
+
+// cacheID returns the ID of the dfcache entry for the file of the URL.
+func cacheID(downloadURL *url.URL) string {
+	return fmt.Sprintf("dfbench-%s", downloadURL.Query().Get("uuid"))
+}
+
+// endpointFlag returns the flag of the dfdaemon unix socket, empty for the default socket.
+func endpointFlag(socket string) string {
+	if socket == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" --endpoint %s", socket)
+}
+
 // getOutput returns the output path.
 func (d *dragonfly) getOutput(fileSizeLevel backend.FileSizeLevel, tag string) (string, error) {
-	return path.Join(OutputDir, fmt.Sprintf("%s-%s-%s", string(fileSizeLevel), tag, uuid.New().String())), nil
+	return path.Join(d.config.OutputDir, fmt.Sprintf("%s-%s-%s", string(fileSizeLevel), tag, uuid.New().String())), nil
 }
@@ -0,0 +1,854 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dragonfly
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+)
+
+// fakeMetrics is the dfdaemon metrics served by the fake curl, one download of the task size level 1.
+const fakeMetrics = `# TYPE dragonfly_client_download_task_duration_milliseconds histogram
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="100"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="+Inf"} 1
+dragonfly_client_download_task_duration_milliseconds_sum{task_size_level="1"} 50
+dragonfly_client_download_task_duration_milliseconds_count{task_size_level="1"} 1
+`
+
+// writeFakeBinaries writes the fake dfget and curl logging their arguments to the returned file, and
+// prepends them to PATH, so the runner can be run by the local executor without a dfdaemon. The fake
+// dfget writes the file of --output, and logs the number of dfgets running at its start to inflight.log
+// next to the returned file, it fails for the URLs containing dfbench-fail.
+func writeFakeBinaries(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls.log")
+	inflight := filepath.Join(dir, "inflight")
+	binaries := map[string]string{
+		"dfget": `echo "dfget $*" >> ` + calls + `
+case "$*" in
+  *dfbench-fail*) echo "download failed" >&2; exit 1 ;;
+esac
+mkdir -p ` + inflight + ` && touch ` + inflight + `/$$
+ls ` + inflight + ` | wc -l >> ` + inflight + `.log
+while [ $# -gt 0 ]; do
+  [ "$1" != "--output" ] || echo dfbench > "$2"
+  shift
+done
+sleep 0.05
+rm ` + inflight + `/$$`,
+		"curl": `echo "curl $*" >> ` + calls + `
+case "$*" in
+  *"-X DELETE"*) ;;
+  *metrics*) cat <<'EOF'
+` + fakeMetrics + `EOF
+  ;;
+esac`,
+	}
+
+	for name, script := range binaries {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+			t.Fatalf("failed to write fake %s: %v", name, err)
+		}
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+// recordExecutor is the executor recording the shell commands executed in the pods without running them.
+type recordExecutor struct {
+	executor.Executor
+
+	// cmds is the shell commands executed in the pods.
+	cmds []string
+}
+
+// Exec records the shell command.
+func (r *recordExecutor) Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error) {
+	r.cmds = append(r.cmds, cmd[len(cmd)-1])
+	return nil, nil
+}
+
+// newTestConfig returns the configuration of the benchmark storing the downloaded files in a temporary
+// directory, so the tests do not touch the output directory of the host.
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg := config.New()
+	cfg.Dragonfly.OutputDir = filepath.Join(t.TempDir(), "dfbench")
+	return cfg
+}
+
+func TestRunByFileSizesWithFakeBinaries(t *testing.T) {
+	tests := []struct {
+		name      string
+		number    uint32
+		warmup    uint32
+		cooldown  time.Duration
+		endpoints []executor.Endpoint
+	}{
+		{
+			name:   "single iteration",
+			number: 1,
+		},
+		{
+			name:   "iterations with warmup",
+			number: 3,
+			warmup: 2,
+		},
+		{
+			name:     "iterations with warmup and cooldown",
+			number:   2,
+			warmup:   1,
+			cooldown: 20 * time.Millisecond,
+		},
+		{
+			name:      "multiple endpoints",
+			number:    2,
+			endpoints: []executor.Endpoint{executor.DefaultEndpoint(), {ProxyAddr: "127.0.0.1:5001", MetricsAddr: "127.0.0.1:5002"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := writeFakeBinaries(t)
+
+			cfg := newTestConfig(t)
+			cfg.Dragonfly.Number = tt.number
+			cfg.Dragonfly.Warmup = tt.warmup
+			cfg.Dragonfly.Cooldown = tt.cooldown
+			local := executor.NewLocal(tt.endpoints...)
+			pods, err := local.GetPods(context.Background(), "component=client")
+			if err != nil {
+				t.Fatalf("GetPods failed: %v", err)
+			}
+
+			s := stats.New(cfg, local)
+			d := New(&cfg.Dragonfly, local, backend.NewFileServerWithBaseURL("http://127.0.0.1:8080", nil), s, nil)
+			start := time.Now()
+			if err := d.RunByFileSizes(context.Background(), config.DownloaderDfget, backend.FileSizeLevelNano); err != nil {
+				t.Fatalf("RunByFileSizes failed: %v", err)
+			}
+
+			// The cooldown follows every warmup iteration and separates the measured iterations.
+			if cooldowns := time.Duration(tt.warmup+tt.number-1) * tt.cooldown; time.Since(start) < cooldowns {
+				t.Errorf("run took %v, want at least %v of cooldown", time.Since(start), cooldowns)
+			}
+
+			// The downloads and timings of the warmup iterations are discarded.
+			measured := int(tt.number) * len(pods)
+			if timings := s.GetTimings(); len(timings) != measured {
+				t.Errorf("got %d timings, want %d", len(timings), measured)
+			}
+
+			if downloads := s.GetDownloads(); len(downloads) != measured {
+				t.Errorf("got %d downloads, want %d", len(downloads), measured)
+			}
+
+			content, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatalf("failed to read calls: %v", err)
+			}
+
+			var dfgets int
+			for _, call := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				if strings.HasPrefix(call, "dfget http://127.0.0.1:8080/nano?") {
+					dfgets++
+				}
+			}
+
+			if expected := int(tt.number+tt.warmup) * len(pods); dfgets != expected {
+				t.Errorf("got %d dfget calls, want %d:\n%s", dfgets, expected, string(content))
+			}
+
+			files, err := os.ReadDir(cfg.Dragonfly.OutputDir)
+			if err != nil {
+				t.Fatalf("failed to read output dir: %v", err)
+			}
+
+			if expected := int(tt.number+tt.warmup) * len(pods); len(files) != expected {
+				t.Errorf("got %d downloaded files, want %d", len(files), expected)
+			}
+
+			if err := d.Cleanup(context.Background()); err != nil {
+				t.Fatalf("Cleanup failed: %v", err)
+			}
+
+			if files, err := os.ReadDir(cfg.Dragonfly.OutputDir); err != nil || len(files) != 0 {
+				t.Errorf("got %d files after cleanup, want 0: %v", len(files), err)
+			}
+		})
+	}
+}
+
+func TestDownloadFilesFanOut(t *testing.T) {
+	endpoints := []executor.Endpoint{
+		{ProxyAddr: "127.0.0.1:4001", MetricsAddr: "127.0.0.1:4002"},
+		{ProxyAddr: "127.0.0.1:5001", MetricsAddr: "127.0.0.1:5002"},
+		{ProxyAddr: "127.0.0.1:6001", MetricsAddr: "127.0.0.1:6002"},
+	}
+
+	tests := []struct {
+		name        string
+		concurrency uint32
+		parallelism uint32
+		maxInflight int
+	}{
+		{
+			name:        "all pods at the same time",
+			parallelism: 1,
+			maxInflight: 3,
+		},
+		{
+			name:        "one pod at a time",
+			concurrency: 1,
+			parallelism: 1,
+			maxInflight: 1,
+		},
+		{
+			name:        "one pod at a time with parallel downloads",
+			concurrency: 1,
+			parallelism: 3,
+			maxInflight: 3,
+		},
+		{
+			name:        "two pods at a time with parallel downloads",
+			concurrency: 2,
+			parallelism: 2,
+			maxInflight: 4,
+		},
+		{
+			name:        "zero parallelism downloads once",
+			concurrency: 2,
+			maxInflight: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := writeFakeBinaries(t)
+
+			cfg := newTestConfig(t)
+			cfg.Dragonfly.Concurrency = tt.concurrency
+			cfg.Dragonfly.Parallelism = tt.parallelism
+			local := executor.NewLocal(endpoints...)
+			d := New(&cfg.Dragonfly, local, backend.NewFileServerWithBaseURL("http://127.0.0.1:8080", nil), stats.New(cfg, local), nil).(*dragonfly)
+			downloadURLs, _, err := d.getDownloadURLs(config.DownloaderDfget, backend.FileSizeLevelNano, false)
+			if err != nil {
+				t.Fatalf("getDownloadURLs failed: %v", err)
+			}
+
+			timings, err := d.downloadFiles(context.Background(), config.DownloaderDfget, backend.FileSizeLevelNano, downloadURLs, false)
+			if err != nil {
+				t.Fatalf("downloadFiles failed: %v", err)
+			}
+
+			parallelism := int(max(tt.parallelism, 1))
+			if len(timings) != len(endpoints)*parallelism {
+				t.Errorf("got %d timings, want %d", len(timings), len(endpoints)*parallelism)
+			}
+
+			for i, timing := range timings {
+				if timing == nil {
+					t.Errorf("timing %d is not recorded", i)
+				}
+			}
+
+			content, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatalf("failed to read calls: %v", err)
+			}
+
+			// Every parallel download is a different task shared by all pods.
+			urls := make(map[string]int)
+			for _, call := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				urls[strings.Fields(call)[1]]++
+			}
+
+			if len(urls) != parallelism {
+				t.Errorf("got %d tasks, want %d:\n%s", len(urls), parallelism, string(content))
+			}
+
+			for url, count := range urls {
+				if count != len(endpoints) {
+					t.Errorf("task %s is downloaded %d times, want %d", url, count, len(endpoints))
+				}
+			}
+
+			content, err = os.ReadFile(strings.TrimSuffix(calls, "calls.log") + "inflight.log")
+			if err != nil {
+				t.Fatalf("failed to read inflight: %v", err)
+			}
+
+			for _, line := range strings.Fields(string(content)) {
+				inflight, err := strconv.Atoi(line)
+				if err != nil {
+					t.Fatalf("invalid inflight %q: %v", line, err)
+				}
+
+				if inflight > tt.maxInflight {
+					t.Errorf("got %d dfgets running at the same time, want at most %d", inflight, tt.maxInflight)
+				}
+			}
+		})
+	}
+}
+
+func TestStartWaves(t *testing.T) {
+	tests := []struct {
+		name         string
+		startPattern string
+		waveSize     uint32
+		pods         int
+		expected     [][]int
+	}{
+		{
+			name:         "all at once",
+			startPattern: config.StartPatternAllAtOnce,
+			waveSize:     2,
+			pods:         3,
+			expected:     [][]int{{0, 1, 2}},
+		},
+		{
+			name:         "seed first",
+			startPattern: config.StartPatternSeedFirst,
+			pods:         3,
+			expected:     [][]int{{0}, {1, 2}},
+		},
+		{
+			name:         "seed first of a single pod",
+			startPattern: config.StartPatternSeedFirst,
+			pods:         1,
+			expected:     [][]int{{0}},
+		},
+		{
+			name:         "waves of the wave size",
+			startPattern: config.StartPatternWaves,
+			waveSize:     2,
+			pods:         5,
+			expected:     [][]int{{0, 1}, {2, 3}, {4}},
+		},
+		{
+			name:         "ramp of zero wave size",
+			startPattern: config.StartPatternRamp,
+			pods:         3,
+			expected:     [][]int{{0}, {1}, {2}},
+		},
+		{
+			name:         "wave size larger than the pods",
+			startPattern: config.StartPatternWaves,
+			waveSize:     10,
+			pods:         3,
+			expected:     [][]int{{0, 1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &dragonfly{config: &config.DragonflyConfig{StartPattern: tt.startPattern, WaveSize: tt.waveSize}}
+			waves := d.startWaves(tt.pods)
+			if !slices.EqualFunc(waves, tt.expected, slices.Equal[[]int]) {
+				t.Errorf("startWaves(%d) = %v, want %v", tt.pods, waves, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadFilesStartPattern(t *testing.T) {
+	endpoints := []executor.Endpoint{
+		{ProxyAddr: "127.0.0.1:4001", MetricsAddr: "127.0.0.1:4002"},
+		{ProxyAddr: "127.0.0.1:5001", MetricsAddr: "127.0.0.1:5002"},
+		{ProxyAddr: "127.0.0.1:6001", MetricsAddr: "127.0.0.1:6002"},
+	}
+
+	tests := []struct {
+		name         string
+		startPattern string
+		waveSize     uint32
+		rampDuration time.Duration
+		waves        []int
+		maxInflight  int
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "seed first",
+			startPattern: config.StartPatternSeedFirst,
+			waveSize:     1,
+			waves:        []int{0, 1, 1},
+			maxInflight:  2,
+		},
+		{
+			name:         "waves",
+			startPattern: config.StartPatternWaves,
+			waveSize:     1,
+			waves:        []int{0, 1, 2},
+			maxInflight:  1,
+		},
+		{
+			name:         "ramp",
+			startPattern: config.StartPatternRamp,
+			waveSize:     2,
+			rampDuration: 400 * time.Millisecond,
+			waves:        []int{0, 0, 1},
+			maxInflight:  3,
+			minElapsed:   200 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := writeFakeBinaries(t)
+
+			cfg := newTestConfig(t)
+			cfg.Dragonfly.StartPattern = tt.startPattern
+			cfg.Dragonfly.WaveSize = tt.waveSize
+			cfg.Dragonfly.RampDuration = tt.rampDuration
+			local := executor.NewLocal(endpoints...)
+			d := New(&cfg.Dragonfly, local, backend.NewFileServerWithBaseURL("http://127.0.0.1:8080", nil), stats.New(cfg, local), nil).(*dragonfly)
+			downloadURLs, _, err := d.getDownloadURLs(config.DownloaderDfget, backend.FileSizeLevelNano, false)
+			if err != nil {
+				t.Fatalf("getDownloadURLs failed: %v", err)
+			}
+
+			start := time.Now()
+			timings, err := d.downloadFiles(context.Background(), config.DownloaderDfget, backend.FileSizeLevelNano, downloadURLs, false)
+			if err != nil {
+				t.Fatalf("downloadFiles failed: %v", err)
+			}
+
+			// The ramp starts the last wave after its share of the ramp duration.
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("downloadFiles took %v, want at least %v", elapsed, tt.minElapsed)
+			}
+
+			var waves []int
+			for _, timing := range timings {
+				waves = append(waves, timing.Wave)
+			}
+
+			if !slices.Equal(waves, tt.waves) {
+				t.Errorf("got waves %v, want %v", waves, tt.waves)
+			}
+
+			content, err := os.ReadFile(strings.TrimSuffix(calls, "calls.log") + "inflight.log")
+			if err != nil {
+				t.Fatalf("failed to read inflight: %v", err)
+			}
+
+			for _, line := range strings.Fields(string(content)) {
+				if inflight, err := strconv.Atoi(line); err != nil || inflight > tt.maxInflight {
+					t.Errorf("got %q dfgets running at the same time, want at most %d", line, tt.maxInflight)
+				}
+			}
+		})
+	}
+}
+
+func TestRunByFileSizesHotCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache string
+		warm  []bool
+		tasks int
+	}{
+		{
+			name:  "cold cache downloads new tasks",
+			cache: config.CacheCold,
+			warm:  []bool{false, false, false},
+			tasks: 4,
+		},
+		{
+			name:  "hot cache reuses the task of the first iteration",
+			cache: config.CacheHot,
+			warm:  []bool{false, true, true},
+			tasks: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := writeFakeBinaries(t)
+
+			cfg := newTestConfig(t)
+			cfg.Dragonfly.Number = uint32(len(tt.warm))
+			cfg.Dragonfly.Warmup = 1
+			cfg.Dragonfly.Cache = tt.cache
+			local := executor.NewLocal()
+			s := stats.New(cfg, local)
+			d := New(&cfg.Dragonfly, local, backend.NewFileServerWithBaseURL("http://127.0.0.1:8080", nil), s, nil)
+			if err := d.RunByFileSizes(context.Background(), config.DownloaderDfget, backend.FileSizeLevelNano); err != nil {
+				t.Fatalf("RunByFileSizes failed: %v", err)
+			}
+
+			var warm, expected int
+			for _, timing := range s.GetTimings() {
+				if timing.Warm {
+					warm++
+				}
+			}
+
+			for _, w := range tt.warm {
+				if w {
+					expected++
+				}
+			}
+
+			if warm != expected {
+				t.Errorf("got %d warm timings, want %d", warm, expected)
+			}
+
+			content, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatalf("failed to read calls: %v", err)
+			}
+
+			// The warmup iteration downloads a new task in both cache modes.
+			tasks := make(map[string]struct{})
+			for _, call := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				if strings.HasPrefix(call, "dfget ") {
+					tasks[strings.Fields(call)[1]] = struct{}{}
+				}
+			}
+
+			if len(tasks) != tt.tasks {
+				t.Errorf("got %d tasks, want %d:\n%s", len(tasks), tt.tasks, string(content))
+			}
+		})
+	}
+}
+
+func TestDfcacheAndDfstoreCommands(t *testing.T) {
+	downloadURL, err := url.Parse("http://file-server/nano?tag=dfcache&uuid=1234")
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		downloader string
+		socket     string
+		seed       []string
+		download   string
+	}{
+		{
+			name:       "dfcache",
+			downloader: config.DownloaderDfcache,
+			seed: []string{
+				"curl -sS --noproxy '*' 'http://file-server/nano?tag=dfcache&uuid=1234' --create-dirs --output <output>",
+				"dfcache import --id dfbench-1234 <output>",
+			},
+			download: "mkdir -p /tmp/dfbench && dfcache export --output <output> dfbench-1234",
+		},
+		{
+			name:       "dfcache with socket",
+			downloader: config.DownloaderDfcache,
+			socket:     "/run/dfdaemon.sock",
+			seed: []string{
+				"curl -sS --noproxy '*' 'http://file-server/nano?tag=dfcache&uuid=1234' --create-dirs --output <output>",
+				"dfcache import --id dfbench-1234 --endpoint /run/dfdaemon.sock <output>",
+			},
+			download: "mkdir -p /tmp/dfbench && dfcache export --output <output> --endpoint /run/dfdaemon.sock dfbench-1234",
+		},
+		{
+			name:       "dfstore",
+			downloader: config.DownloaderDfstore,
+			socket:     "/run/dfdaemon.sock",
+			seed: []string{
+				"curl -sS --noproxy '*' 'http://file-server/nano?tag=dfcache&uuid=1234' --create-dirs --output <output>",
+				"dfstore cp --endpoint http://127.0.0.1:65004 <output> d7y://dfbench/dfbench/nano-1234",
+			},
+			download: "mkdir -p /tmp/dfbench && dfstore cp --endpoint http://127.0.0.1:65004 d7y://dfbench/dfbench/nano-1234 <output>",
+		},
+		{
+			name:       "dfget is not seeded",
+			downloader: config.DownloaderDfget,
+			download:   "mkdir -p /tmp/dfbench && dfget 'http://file-server/nano?tag=dfcache&uuid=1234' --output <output>",
+		},
+	}
+
+	output := regexp.MustCompile(`/tmp/dfbench/nano-[a-z]+-[0-9a-f-]{36}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := executor.DefaultEndpoint()
+			endpoint.Socket = tt.socket
+			record := &recordExecutor{Executor: executor.NewLocal(endpoint)}
+			cfg := config.New()
+			cfg.Dragonfly.Bucket = "dfbench"
+			d := New(&cfg.Dragonfly, record, backend.NewFileServerWithBaseURL("http://file-server", nil), stats.New(cfg, record), nil).(*dragonfly)
+
+			uploads, err := d.seed(context.Background(), tt.downloader, backend.FileSizeLevelNano, []*url.URL{downloadURL})
+			if err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+
+			if len(uploads) != len(tt.seed)/2 {
+				t.Errorf("got %d uploads, want %d", len(uploads), len(tt.seed)/2)
+			}
+
+			for _, upload := range uploads {
+				if !upload.Upload || upload.Downloader != tt.downloader {
+					t.Errorf("upload timing = %+v, want an upload by %s", upload, tt.downloader)
+				}
+			}
+
+			pods, err := record.GetPods(context.Background(), "component=client")
+			if err != nil {
+				t.Fatalf("GetPods failed: %v", err)
+			}
+
+			if _, err := d.downloadFile(context.Background(), tt.downloader, pods[0], downloadURL, backend.FileSizeLevelNano); err != nil {
+				t.Fatalf("downloadFile failed: %v", err)
+			}
+
+			var cmds []string
+			for _, cmd := range record.cmds {
+				cmds = append(cmds, output.ReplaceAllString(cmd, "<output>"))
+			}
+
+			if expected := append(slices.Clone(tt.seed), tt.download); !slices.Equal(cmds, expected) {
+				t.Errorf("got commands:\n%s\nwant:\n%s", strings.Join(cmds, "\n"), strings.Join(expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d := &dragonfly{config: &config.DragonflyConfig{Cooldown: time.Hour}}
+	if err := d.cooldown(ctx); err != context.Canceled {
+		t.Errorf("cooldown of canceled context = %v, want %v", err, context.Canceled)
+	}
+
+	d.config.Cooldown = 0
+	if err := d.cooldown(ctx); err != nil {
+		t.Errorf("cooldown of zero duration = %v, want nil", err)
+	}
+}
+
+func TestParseCurlTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected stats.Timing
+		wantErr  bool
+	}{
+		{
+			name:   "timing after the body",
+			output: "hello\ndfbench_timing 0.001500 0.250000 1.500000\n",
+			expected: stats.Timing{
+				PodName: "client-0",
+				Elapsed: 2 * time.Second,
+				Connect: 1500 * time.Microsecond,
+				TTFB:    250 * time.Millisecond,
+				Total:   1500 * time.Millisecond,
+			},
+		},
+		{
+			name:   "first of several timings",
+			output: "\ndfbench_timing 0.1 0.2 0.3\ndfbench_timing 1 2 3\n",
+			expected: stats.Timing{
+				PodName: "client-0",
+				Elapsed: 2 * time.Second,
+				Connect: 100 * time.Millisecond,
+				TTFB:    200 * time.Millisecond,
+				Total:   300 * time.Millisecond,
+			},
+		},
+		{
+			name:    "timing not found",
+			output:  "curl: (7) Failed to connect\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timing",
+			output:  "dfbench_timing 0.1 abc 0.3\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := &stats.Timing{PodName: "client-0", Elapsed: 2 * time.Second}
+			err := parseCurlTiming([]byte(tt.output), timing)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseCurlTiming(%q) = %+v, want error", tt.output, timing)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseCurlTiming(%q) failed: %v", tt.output, err)
+			}
+
+			if *timing != tt.expected {
+				t.Errorf("parseCurlTiming(%q) = %+v, want %+v", tt.output, *timing, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCurlTimings(t *testing.T) {
+	output := "dfbench_timing 0.1 0.2 0.3\nignored line\ndfbench_timing 0.01 0.02 0.03\ndfbench_timing 1 2\n"
+	timings, err := parseCurlTimings([]byte(output), &stats.Timing{PodName: "client-0", Range: "0-1023"})
+	if err != nil {
+		t.Fatalf("parseCurlTimings failed: %v", err)
+	}
+
+	expected := []time.Duration{300 * time.Millisecond, 30 * time.Millisecond}
+	if len(timings) != len(expected) {
+		t.Fatalf("got %d timings, want %d", len(timings), len(expected))
+	}
+
+	for i, timing := range timings {
+		if timing.Total != expected[i] || timing.PodName != "client-0" || timing.Range != "0-1023" {
+			t.Errorf("timing %d = %+v, want total %v of client-0 and range 0-1023", i, timing, expected[i])
+		}
+	}
+
+	if timings[0] == timings[1] {
+		t.Error("timings share the same copy")
+	}
+}
+
+func TestRandomRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      uint64
+		chunkSize uint64
+	}{
+		{name: "chunk in the file", size: 10 << 20, chunkSize: 1 << 20},
+		{name: "chunk of one byte", size: 1 << 10, chunkSize: 1},
+		{name: "chunk of the file size", size: 1 << 20, chunkSize: 1 << 20},
+		{name: "chunk larger than the file", size: 1 << 10, chunkSize: 1 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				byteRange := randomRange(tt.size, tt.chunkSize)
+				first, last, ok := strings.Cut(byteRange, "-")
+				if !ok {
+					t.Fatalf("invalid range %q", byteRange)
+				}
+
+				start, err := strconv.ParseUint(first, 10, 64)
+				if err != nil {
+					t.Fatalf("invalid range %q: %v", byteRange, err)
+				}
+
+				end, err := strconv.ParseUint(last, 10, 64)
+				if err != nil {
+					t.Fatalf("invalid range %q: %v", byteRange, err)
+				}
+
+				if end >= tt.size || end-start+1 != min(tt.size, tt.chunkSize) {
+					t.Fatalf("randomRange(%d, %d) = %q out of the file or not of the chunk size", tt.size, tt.chunkSize, byteRange)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadFilesFaulty(t *testing.T) {
+	tests := []struct {
+		name    string
+		faulty  bool
+		wantErr bool
+	}{
+		{name: "failed downloads are timings of the faulty tasks", faulty: true},
+		{name: "failed downloads fail the iteration", faulty: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFakeBinaries(t)
+
+			cfg := newTestConfig(t)
+			local := executor.NewLocal(executor.DefaultEndpoint(), executor.DefaultEndpoint())
+			d := New(&cfg.Dragonfly, local, backend.NewFileServerWithBaseURL("http://127.0.0.1:8080", nil), stats.New(cfg, local), nil).(*dragonfly)
+			downloadURLs := []*url.URL{
+				{Scheme: "http", Host: "127.0.0.1:8080", Path: "/nano", RawQuery: "uuid=ok"},
+				{Scheme: "http", Host: "127.0.0.1:8080", Path: "/nano", RawQuery: "uuid=dfbench-fail"},
+			}
+
+			timings, err := d.downloadFiles(context.Background(), config.DownloaderDfget, backend.FileSizeLevelNano, downloadURLs, tt.faulty)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("downloadFiles succeeded, want error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("downloadFiles failed: %v", err)
+			}
+
+			// Every pod downloads the URLs in order, the second download of every pod fails.
+			var failed []bool
+			for _, timing := range timings {
+				failed = append(failed, timing.Failed)
+				if timing.Downloader != config.DownloaderDfget || timing.FileSizeLevel != backend.FileSizeLevelNano || timing.Elapsed <= 0 {
+					t.Errorf("timing = %+v, want the elapsed time of a nano download by dfget", timing)
+				}
+			}
+
+			if expected := []bool{false, true, false, true}; !slices.Equal(failed, expected) {
+				t.Errorf("failed = %v, want %v", failed, expected)
+			}
+		})
+	}
+}
+
+func TestAddTaskTraffic(t *testing.T) {
+	var downloadURLs []*url.URL
+	for _, id := range []string{"a", "b", "a", "c"} {
+		downloadURLs = append(downloadURLs, &url.URL{Path: "/large", RawQuery: "tag=dfget&uuid=" + id})
+	}
+
+	ids := uniqueTasks(downloadURLs)
+	if !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Fatalf("uniqueTasks() = %v, want [a b c]", ids)
+	}
+
+	// The task c is not fetched from the origin.
+	traffic := &stats.OriginTraffic{}
+	addTaskTraffic(traffic, ids, map[string]*backend.TaskTraffic{
+		"a": {UUID: "a", Requests: 3, Bytes: 30},
+		"b": {UUID: "b", Requests: 1, Bytes: 10},
+	})
+	if traffic.Tasks != 3 || traffic.Requests != 4 || traffic.Bytes != 40 {
+		t.Errorf("traffic = %+v, want 3 tasks, 4 requests and 40 bytes", traffic)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultProxyAddr is the default address of the dfdaemon proxy.
+	DefaultProxyAddr = "127.0.0.1:4001"
+
+	// DefaultMetricsAddr is the default address of the dfdaemon metrics server.
+	DefaultMetricsAddr = "127.0.0.1:4002"
+
+	// DefaultObjectStorageAddr is the default address of the dfdaemon object storage server.
+	DefaultObjectStorageAddr = "127.0.0.1:65004"
+)
+
+// Endpoint represents the dfdaemon serving the downloads of a pod.
+type Endpoint struct {
+	// ProxyAddr is the address of the dfdaemon proxy.
+	ProxyAddr string
+
+	// MetricsAddr is the address of the dfdaemon metrics server.
+	MetricsAddr string
+
+	// Socket is the path of the dfdaemon unix socket used by dfget and dfcache, empty means their default.
+	Socket string
+
+	// ObjectStorageAddr is the address of the dfdaemon object storage server used by dfstore.
+	ObjectStorageAddr string
+}
+
+// DefaultEndpoint returns the endpoint of the dfdaemon listening on the default addresses.
+func DefaultEndpoint() Endpoint {
+	return Endpoint{ProxyAddr: DefaultProxyAddr, MetricsAddr: DefaultMetricsAddr, ObjectStorageAddr: DefaultObjectStorageAddr}
+}
+
+// ParseEndpoint parses the endpoint in the format of "proxy=<addr>,metrics=<addr>,socket=<path>,object-storage=<addr>",
+// the omitted keys are set to the default values.
+func ParseEndpoint(s string) (Endpoint, error) {
+	endpoint := DefaultEndpoint()
+	for _, kv := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || value == "" {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q", s)
+		}
+
+		switch key {
+		case "proxy":
+			endpoint.ProxyAddr = value
+		case "metrics":
+			endpoint.MetricsAddr = value
+		case "socket":
+			endpoint.Socket = value
+		case "object-storage":
+			endpoint.ObjectStorageAddr = value
+		default:
+			return Endpoint{}, fmt.Errorf("invalid endpoint key %q", key)
+		}
+	}
+
+	return endpoint, nil
+}
+
+// Executor represents an executor for running commands on the benchmark targets.
+type Executor interface {
+	// GetPods returns the names of the pods matching the label selector.
+	GetPods(ctx context.Context, label string) ([]string, error)
+
+	// Exec executes the command in the pod and returns the combined output.
+	Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error)
+
+	// CopyTo copies the local file to the path in the pod.
+	CopyTo(ctx context.Context, pod string, src string, dst string) error
+
+	// CopyFrom copies the file in the pod to the local path.
+	CopyFrom(ctx context.Context, pod string, src string, dst string) error
+
+	// GetEndpoint returns the dfdaemon endpoint serving the pod.
+	GetEndpoint(pod string) Endpoint
+}
@@ -0,0 +1,79 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import "testing"
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected Endpoint
+		wantErr  bool
+	}{
+		{
+			name:     "proxy only",
+			s:        "proxy=127.0.0.1:5001",
+			expected: Endpoint{ProxyAddr: "127.0.0.1:5001", MetricsAddr: DefaultMetricsAddr, ObjectStorageAddr: DefaultObjectStorageAddr},
+		},
+		{
+			name: "all keys",
+			s:    "proxy=127.0.0.1:5001, metrics=127.0.0.1:5002,socket=/tmp/dfdaemon.sock,object-storage=127.0.0.1:5004",
+			expected: Endpoint{
+				ProxyAddr:         "127.0.0.1:5001",
+				MetricsAddr:       "127.0.0.1:5002",
+				Socket:            "/tmp/dfdaemon.sock",
+				ObjectStorageAddr: "127.0.0.1:5004",
+			},
+		},
+		{
+			name:    "missing value",
+			s:       "proxy=",
+			wantErr: true,
+		},
+		{
+			name:    "missing separator",
+			s:       "proxy",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			s:       "proxy=127.0.0.1:5001,scheduler=127.0.0.1:8002",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := ParseEndpoint(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseEndpoint(%q) = %+v, want error", tt.s, endpoint)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseEndpoint(%q) failed: %v", tt.s, err)
+			}
+
+			if endpoint != tt.expected {
+				t.Errorf("ParseEndpoint(%q) = %+v, want %+v", tt.s, endpoint, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// defaultQPS is the client side QPS limit of the kubernetes client,
+	// every benchmark step fans out one exec request per pod.
+	defaultQPS = 100
+
+	// defaultBurst is the client side burst limit of the kubernetes client.
+	defaultBurst = 200
+)
+
+// kubernetes implements the Executor interface by the kubernetes API.
+type kubernetes struct {
+	// namespace is the namespace of the pods.
+	namespace string
+
+	// container is the container of the pods to execute commands in.
+	container string
+
+	// config is the rest config of the kubernetes client.
+	config *rest.Config
+
+	// clientset is the kubernetes clientset.
+	clientset k8s.Interface
+}
+
+// NewKubernetes creates a new executor backed by the kubernetes API, commands are executed
+// in the container of the pods by the websocket protocol and fall back to SPDY.
+func NewKubernetes(kubeconfig string, namespace string, container string) (Executor, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		loadingRules.ExplicitPath = kubeconfig
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	config.QPS = defaultQPS
+	config.Burst = defaultBurst
+
+	clientset, err := k8s.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	return &kubernetes{namespace, container, config, clientset}, nil
+}
+
+// GetPods returns the names of the pods matching the label selector.
+func (k *kubernetes) GetPods(ctx context.Context, label string) ([]string, error) {
+	podList, err := k.clientset.CoreV1().Pods(k.namespace).List(ctx, metav1.ListOptions{LabelSelector: label})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods: %w", err)
+	}
+
+	pods := make([]string, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		pods = append(pods, pod.Name)
+	}
+
+	return pods, nil
+}
+
+// Exec executes the command in the pod and returns the combined output.
+func (k *kubernetes) Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error) {
+	var output syncBuffer
+	if err := k.stream(ctx, pod, cmd, nil, &output, &output); err != nil {
+		return output.Bytes(), err
+	}
+
+	return output.Bytes(), nil
+}
+
+// CopyTo copies the local file to the path in the pod.
+func (k *kubernetes) CopyTo(ctx context.Context, pod string, src string, dst string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var stderr bytes.Buffer
+	if err := k.stream(ctx, pod, []string{"sh", "-c", fmt.Sprintf("cat > '%s'", dst)}, file, io.Discard, &stderr); err != nil {
+		return fmt.Errorf("failed to copy %s to %s/%s: %w \nmessage: %s", src, pod, dst, err, stderr.String())
+	}
+
+	return nil
+}
+
+// CopyFrom copies the file in the pod to the local path.
+func (k *kubernetes) CopyFrom(ctx context.Context, pod string, src string, dst string) error {
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var stderr bytes.Buffer
+	if err := k.stream(ctx, pod, []string{"cat", src}, nil, file, &stderr); err != nil {
+		return fmt.Errorf("failed to copy %s/%s to %s: %w \nmessage: %s", pod, src, dst, err, stderr.String())
+	}
+
+	return nil
+}
+
+// GetEndpoint returns the dfdaemon endpoint serving the pod, the dfdaemon runs
+// in the client pod and listens on the default addresses.
+func (k *kubernetes) GetEndpoint(pod string) Endpoint {
+	return DefaultEndpoint()
+}
+
+// stream executes the command in the pod and streams the stdin, stdout and stderr.
+func (k *kubernetes) stream(ctx context.Context, pod string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	logrus.Debugf(`exec command in %s/%s: "%s"`, k.namespace, pod, strings.Join(cmd, `" "`))
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(k.namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: k.container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	websocketExecutor, err := remotecommand.NewWebSocketExecutor(k.config, "GET", req.URL().String())
+	if err != nil {
+		return err
+	}
+
+	spdyExecutor, err := remotecommand.NewSPDYExecutor(k.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	executor, err := remotecommand.NewFallbackExecutor(websocketExecutor, spdyExecutor, shouldFallback)
+	if err != nil {
+		return err
+	}
+
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+// shouldFallback returns whether the exec by the websocket protocol falls back to SPDY, it falls back
+// if the API server does not upgrade the connection to websocket or the HTTPS proxy is not supported.
+func shouldFallback(err error) bool {
+	return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes of stdout and stderr.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write writes the data to the buffer.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes returns the content of the buffer.
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
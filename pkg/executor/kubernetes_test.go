@@ -0,0 +1,103 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestShouldFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "upgrade failure",
+			err:      &httpstream.UpgradeFailureError{Cause: errors.New("websocket: bad handshake")},
+			expected: true,
+		},
+		{
+			name:     "wrapped upgrade failure",
+			err:      fmt.Errorf("failed to exec: %w", &httpstream.UpgradeFailureError{Cause: errors.New("403 Forbidden")}),
+			expected: true,
+		},
+		{
+			name:     "https proxy",
+			err:      errors.New("proxy: unknown scheme: https"),
+			expected: true,
+		},
+		{
+			name:     "command exit code",
+			err:      errors.New("command terminated with exit code 1"),
+			expected: false,
+		},
+		{
+			name:     "no error",
+			err:      nil,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldFallback(tt.err); got != tt.expected {
+				t.Errorf("shouldFallback(%v) = %t, want %t", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKubernetesGetPods(t *testing.T) {
+	pod := func(namespace, name, component string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{"component": component},
+		}}
+	}
+
+	k := &kubernetes{
+		namespace: "dragonfly-system",
+		container: "client",
+		clientset: fake.NewClientset(
+			pod("dragonfly-system", "client-0", "client"),
+			pod("dragonfly-system", "client-1", "client"),
+			pod("dragonfly-system", "scheduler-0", "scheduler"),
+			pod("default", "client-2", "client"),
+		),
+	}
+
+	pods, err := k.GetPods(context.Background(), "component=client")
+	if err != nil {
+		t.Fatalf("GetPods failed: %v", err)
+	}
+
+	slices.Sort(pods)
+	if expected := []string{"client-0", "client-1"}; !slices.Equal(pods, expected) {
+		t.Errorf("GetPods = %v, want %v", pods, expected)
+	}
+}
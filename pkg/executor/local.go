@@ -0,0 +1,110 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// localPodPrefix is the name prefix of the pseudo pods of the local executor.
+const localPodPrefix = "local-"
+
+// local implements the Executor interface by the local processes, every endpoint
+// is exposed as a pseudo pod named by its index, e.g. local-0.
+type local struct {
+	// endpoints is the dfdaemon endpoints on the local machine.
+	endpoints []Endpoint
+}
+
+// NewLocal creates a new executor running commands on the local machine against the dfdaemon endpoints,
+// the dfdaemon listening on the default addresses is used if no endpoint is specified.
+func NewLocal(endpoints ...Endpoint) Executor {
+	if len(endpoints) == 0 {
+		endpoints = []Endpoint{DefaultEndpoint()}
+	}
+
+	return &local{endpoints}
+}
+
+// GetPods returns the names of the pseudo pods of all endpoints, the label selector is ignored.
+func (l *local) GetPods(ctx context.Context, label string) ([]string, error) {
+	pods := make([]string, 0, len(l.endpoints))
+	for i := range l.endpoints {
+		pods = append(pods, fmt.Sprintf("%s%d", localPodPrefix, i))
+	}
+
+	return pods, nil
+}
+
+// Exec executes the command on the local machine and returns the combined output.
+func (l *local) Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	logrus.Debugf(`exec command in %s: "%s"`, pod, strings.Join(cmd, `" "`))
+	return exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput()
+}
+
+// CopyTo copies the local file to the path, the pod is ignored as it shares the local filesystem.
+func (l *local) CopyTo(ctx context.Context, pod string, src string, dst string) error {
+	return copyFile(src, dst)
+}
+
+// CopyFrom copies the file to the local path, the pod is ignored as it shares the local filesystem.
+func (l *local) CopyFrom(ctx context.Context, pod string, src string, dst string) error {
+	return copyFile(src, dst)
+}
+
+// GetEndpoint returns the dfdaemon endpoint serving the pseudo pod.
+func (l *local) GetEndpoint(pod string) Endpoint {
+	var i int
+	if _, err := fmt.Sscanf(pod, localPodPrefix+"%d", &i); err != nil || i < 0 || i >= len(l.endpoints) {
+		return DefaultEndpoint()
+	}
+
+	return l.endpoints[i]
+}
+
+// copyFile copies the file from src to dst.
+func copyFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+
+	return nil
+}
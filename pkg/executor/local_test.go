@@ -0,0 +1,168 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFakeBinary writes the shell script as the executable named by name to a directory prepended
+// to PATH, so the commands run by the local executor can be faked.
+func writeFakeBinary(t *testing.T, name, script string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("failed to write fake %s: %v", name, err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestLocalGetPods(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []Endpoint
+		expected  []string
+	}{
+		{
+			name:     "default endpoint",
+			expected: []string{"local-0"},
+		},
+		{
+			name:      "multiple endpoints",
+			endpoints: []Endpoint{DefaultEndpoint(), {ProxyAddr: "127.0.0.1:5001", MetricsAddr: "127.0.0.1:5002"}},
+			expected:  []string{"local-0", "local-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods, err := NewLocal(tt.endpoints...).GetPods(context.Background(), "app=dragonfly")
+			if err != nil {
+				t.Fatalf("GetPods failed: %v", err)
+			}
+
+			if !slices.Equal(pods, tt.expected) {
+				t.Errorf("GetPods = %v, want %v", pods, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalGetEndpoint(t *testing.T) {
+	endpoint := Endpoint{ProxyAddr: "127.0.0.1:5001", MetricsAddr: "127.0.0.1:5002"}
+	executor := NewLocal(DefaultEndpoint(), endpoint)
+
+	tests := []struct {
+		pod      string
+		expected Endpoint
+	}{
+		{pod: "local-0", expected: DefaultEndpoint()},
+		{pod: "local-1", expected: endpoint},
+		{pod: "local-2", expected: DefaultEndpoint()},
+		{pod: "client-0", expected: DefaultEndpoint()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pod, func(t *testing.T) {
+			if got := executor.GetEndpoint(tt.pod); got != tt.expected {
+				t.Errorf("GetEndpoint(%q) = %+v, want %+v", tt.pod, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalExec(t *testing.T) {
+	writeFakeBinary(t, "dfget", `echo "dfget $*"; [ "$1" != "--fail" ] || { echo "download failed" >&2; exit 1; }`)
+
+	tests := []struct {
+		name     string
+		cmd      []string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "fake binary",
+			cmd:      []string{"dfget", "-O", "/tmp/file", "http://file-server/small"},
+			expected: "dfget -O /tmp/file http://file-server/small\n",
+		},
+		{
+			name:     "shell command",
+			cmd:      []string{"sh", "-c", "dfget http://file-server/nano && echo done"},
+			expected: "dfget http://file-server/nano\ndone\n",
+		},
+		{
+			name:     "combined output of failure",
+			cmd:      []string{"dfget", "--fail"},
+			expected: "dfget --fail\ndownload failed\n",
+			wantErr:  true,
+		},
+		{
+			name:    "empty command",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := NewLocal().Exec(context.Background(), "local-0", tt.cmd...)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Exec(%q) error = %v, want error %t", tt.cmd, err, tt.wantErr)
+			}
+
+			if string(output) != tt.expected {
+				t.Errorf("Exec(%q) = %q, want %q", tt.cmd, string(output), tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalCopy(t *testing.T) {
+	dir := t.TempDir()
+	src, dst, back := filepath.Join(dir, "src"), filepath.Join(dir, "dst"), filepath.Join(dir, "back")
+	if err := os.WriteFile(src, []byte("dfbench"), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", src, err)
+	}
+
+	executor := NewLocal()
+	if err := executor.CopyTo(context.Background(), "local-0", src, dst); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+
+	if err := executor.CopyFrom(context.Background(), "local-0", dst, back); err != nil {
+		t.Fatalf("CopyFrom failed: %v", err)
+	}
+
+	content, err := os.ReadFile(back)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", back, err)
+	}
+
+	if string(content) != "dfbench" {
+		t.Errorf("copied content = %q, want %q", string(content), "dfbench")
+	}
+
+	if err := executor.CopyTo(context.Background(), "local-0", filepath.Join(dir, "missing"), dst); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("CopyTo of missing file error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+)
+
+// blockSize is the size of the blocks of the content, every block is generated by its own seeded
+// random generator, so any byte range is generated without the preceding bytes.
+const blockSize = 64 << 10
+
+// content is the pseudo-random content of a file generated on the fly from the seed, the content
+// of a smaller file is the prefix of the content of a larger file with the same seed.
+type content struct {
+	// seed is the seed of the content.
+	seed uint64
+
+	// size is the size of the content in bytes.
+	size int64
+
+	// offset is the offset of the next read.
+	offset int64
+
+	// index is the index of the generated block, -1 if no block is generated.
+	index int64
+
+	// block is the generated block.
+	block []byte
+}
+
+// newContent creates the content of the size generated from the seed.
+func newContent(seed uint64, size int64) io.ReadSeeker {
+	return &content{seed: seed, size: size, index: -1, block: make([]byte, blockSize)}
+}
+
+// Read reads the content from the offset, at most to the end of the block of the offset.
+func (c *content) Read(p []byte) (int, error) {
+	if c.offset >= c.size {
+		return 0, io.EOF
+	}
+
+	index := c.offset / blockSize
+	if index != c.index {
+		var seed [32]byte
+		binary.LittleEndian.PutUint64(seed[:8], c.seed)
+		binary.LittleEndian.PutUint64(seed[8:16], uint64(index))
+		if _, err := rand.NewChaCha8(seed).Read(c.block); err != nil {
+			return 0, err
+		}
+
+		c.index = index
+	}
+
+	end := min(blockSize, c.size-index*blockSize)
+	n := copy(p, c.block[c.offset-index*blockSize:end])
+	c.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset of the next read.
+func (c *content) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	c.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,134 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// readContent reads the length bytes from the offset of the content of the size generated from the seed.
+func readContent(t *testing.T, seed uint64, size, offset, length int64) []byte {
+	t.Helper()
+
+	content := newContent(seed, size)
+	if _, err := content.Seek(offset, io.SeekStart); err != nil {
+		t.Fatalf("failed to seek to %d: %v", offset, err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, length))
+	if err != nil {
+		t.Fatalf("failed to read content: %v", err)
+	}
+
+	return data
+}
+
+func TestContentRead(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+	}{
+		{name: "empty", size: 0},
+		{name: "one byte", size: 1},
+		{name: "one block", size: blockSize},
+		{name: "blocks and a partial block", size: 3*blockSize + 123},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := io.ReadAll(newContent(42, tt.size))
+			if err != nil {
+				t.Fatalf("failed to read content: %v", err)
+			}
+
+			if int64(len(data)) != tt.size {
+				t.Fatalf("read %d bytes, want %d", len(data), tt.size)
+			}
+
+			again, err := io.ReadAll(newContent(42, tt.size))
+			if err != nil {
+				t.Fatalf("failed to read content: %v", err)
+			}
+
+			if !bytes.Equal(data, again) {
+				t.Error("content of the same seed differs")
+			}
+		})
+	}
+}
+
+func TestContentSeed(t *testing.T) {
+	const size = 2*blockSize + 1
+	a, b := readContent(t, 1, size, 0, size), readContent(t, 2, size, 0, size)
+	if bytes.Equal(a, b) {
+		t.Error("content of different seeds is the same")
+	}
+
+	if bytes.Count(a, []byte{0}) > size/16 {
+		t.Error("content is not random")
+	}
+}
+
+func TestContentPrefix(t *testing.T) {
+	small := readContent(t, 42, blockSize+10, 0, blockSize+10)
+	large := readContent(t, 42, 4*blockSize, 0, 4*blockSize)
+	if !bytes.Equal(small, large[:len(small)]) {
+		t.Error("content of the smaller file is not the prefix of the larger file")
+	}
+}
+
+func TestContentSeek(t *testing.T) {
+	const size = 3*blockSize + 100
+	whole := readContent(t, 42, size, 0, size)
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+	}{
+		{name: "head", offset: 0, length: 10},
+		{name: "inside a block", offset: 100, length: 1000},
+		{name: "across blocks", offset: blockSize - 10, length: 2*blockSize + 20},
+		{name: "tail", offset: size - 50, length: 50},
+		{name: "beyond the end", offset: size - 10, length: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readContent(t, 42, size, tt.offset, tt.length)
+			expected := whole[tt.offset:min(tt.offset+tt.length, size)]
+			if !bytes.Equal(data, expected) {
+				t.Errorf("read %d bytes from %d mismatches the whole content", len(data), tt.offset)
+			}
+		})
+	}
+
+	content := newContent(42, size)
+	if _, err := content.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seek to negative position succeeded")
+	}
+
+	if offset, err := content.Seek(-10, io.SeekEnd); err != nil || offset != size-10 {
+		t.Errorf("seek from the end = %d, %v, want %d", offset, err, size-10)
+	}
+
+	if offset, err := content.Seek(5, io.SeekCurrent); err != nil || offset != size-5 {
+		t.Errorf("seek from the current = %d, %v, want %d", offset, err, size-5)
+	}
+}
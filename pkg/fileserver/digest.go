@@ -0,0 +1,128 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/sirupsen/logrus"
+)
+
+// digest is the digest of a whole file computed once for all requests.
+type digest struct {
+	// once computes the digest at the first request.
+	once sync.Once
+
+	// value is the computed digest.
+	value *backend.Digest
+
+	// err is the error of computing the digest.
+	err error
+}
+
+// serveDigest serves the digest of the file of the size, or of the byte range of the Range header
+// in the format of bytes=<start>-[end]. The digests of the whole files are cached by size.
+func (f *fileServer) serveDigest(w http.ResponseWriter, r *http.Request, size int64) {
+	var (
+		value *backend.Digest
+		err   error
+	)
+	if header := r.Header.Get("Range"); header != "" {
+		start, end, ok := parseRange(header, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			http.Error(w, fmt.Sprintf("invalid range %q", header), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		value, err = f.computeDigest(size, start, end-start+1)
+	} else {
+		entry, _ := f.digests.LoadOrStore(size, &digest{})
+		d := entry.(*digest)
+		d.once.Do(func() {
+			d.value, d.err = f.computeDigest(size, 0, size)
+		})
+
+		value, err = d.value, d.err
+	}
+
+	if err != nil {
+		logrus.Errorf("failed to compute digest: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.Errorf("failed to write digest: %v", err)
+	}
+}
+
+// computeDigest computes the SHA-256 digest of the length bytes from the offset of the content of
+// the file of the size.
+func (f *fileServer) computeDigest(size, offset, length int64) (*backend.Digest, error) {
+	content := newContent(f.config.Seed, size)
+	if _, err := content.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.LimitReader(content, length))
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.Digest{Size: uint64(n), Digest: "sha256:" + hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// parseRange parses the single byte range of the Range header in the format of bytes=<start>-[end]
+// for the file of the size, the end is truncated to the end of the file as the file is served.
+func parseRange(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+	}
+
+	return start, min(end, size-1), true
+}
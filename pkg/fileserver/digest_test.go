@@ -0,0 +1,136 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// sha256Digest returns the digest of the data in the format of sha256:<hex>.
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		size       int64
+		start, end int64
+		ok         bool
+	}{
+		{header: "bytes=0-99", size: 1000, start: 0, end: 99, ok: true},
+		{header: "bytes=100-", size: 1000, start: 100, end: 999, ok: true},
+		{header: "bytes=900-2000", size: 1000, start: 900, end: 999, ok: true},
+		{header: "bytes=999-999", size: 1000, start: 999, end: 999, ok: true},
+		{header: "bytes=1000-", size: 1000},
+		{header: "bytes=10-5", size: 1000},
+		{header: "bytes=-100", size: 1000},
+		{header: "bytes=a-b", size: 1000},
+		{header: "bytes=0", size: 1000},
+		{header: "items=0-99", size: 1000},
+		{header: "bytes=0-", size: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			start, end, ok := parseRange(tt.header, tt.size)
+			if ok != tt.ok || (ok && (start != tt.start || end != tt.end)) {
+				t.Errorf("parseRange(%q, %d) = %d, %d, %t, want %d, %d, %t", tt.header, tt.size, start, end, ok, tt.start, tt.end, tt.ok)
+			}
+		})
+	}
+}
+
+func TestComputeDigest(t *testing.T) {
+	const size = 3*blockSize + 7
+	f := New(&config.FileServerConfig{Seed: testSeed}).(*fileServer)
+	whole := readContent(t, testSeed, size, 0, size)
+
+	tests := []struct {
+		name           string
+		offset, length int64
+	}{
+		{name: "whole file", offset: 0, length: size},
+		{name: "head", offset: 0, length: 1},
+		{name: "across blocks", offset: blockSize - 1, length: blockSize + 2},
+		{name: "tail", offset: size - 7, length: 7},
+		{name: "empty", offset: 0, length: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digest, err := f.computeDigest(size, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("computeDigest failed: %v", err)
+			}
+
+			data := whole[tt.offset : tt.offset+tt.length]
+			if digest.Size != uint64(tt.length) || digest.Digest != sha256Digest(data) {
+				t.Errorf("computeDigest = %+v, want size %d and %s", digest, tt.length, sha256Digest(data))
+			}
+		})
+	}
+}
+
+func TestServeDigest(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{name: "file size level", path: "/small", status: http.StatusOK},
+		{name: "file of any size", path: "/size/300000", status: http.StatusOK},
+		{name: "cached file size level", path: "/small", status: http.StatusOK},
+		{name: "range", path: "/small", headers: map[string]string{"Range": "bytes=65530-65540"}, status: http.StatusOK},
+		{name: "open range", path: "/size/300000", headers: map[string]string{"Range": "bytes=299990-"}, status: http.StatusOK},
+		{name: "invalid range", path: "/small", headers: map[string]string{"Range": "bytes=2000000-"}, status: http.StatusRequestedRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(t, server, "/"+backend.DigestPath+tt.path, tt.headers)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			var digest backend.Digest
+			if err := json.Unmarshal(body, &digest); err != nil {
+				t.Fatalf("invalid digest %q: %v", string(body), err)
+			}
+
+			// The digest must match the content served by the same path and range.
+			_, content := get(t, server, tt.path, tt.headers)
+			if digest.Size != uint64(len(content)) || digest.Digest != sha256Digest(content) {
+				t.Errorf("digest = %+v, want size %d and %s", digest, len(content), sha256Digest(content))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/sirupsen/logrus"
+)
+
+// errTruncated is the error of the writes after the response is truncated by the fault.
+var errTruncated = errors.New("response is truncated by the injected fault")
+
+// injectFault injects the fault of the origin into the response by the fault rate, it returns false
+// if the response is finished by the fault, otherwise the response writer to write the body to.
+func injectFault(ctx context.Context, w http.ResponseWriter, origin *backend.Origin) (http.ResponseWriter, bool) {
+	fault := origin.Fault
+	if fault == nil || rand.Float64() >= fault.Rate {
+		return w, true
+	}
+
+	if fault.Profile == backend.FaultError {
+		http.Error(w, fmt.Sprintf("injected %s fault", fault.Profile), fault.Status)
+		return w, false
+	}
+
+	return &faultyWriter{ResponseWriter: w, ctx: ctx, fault: fault}, true
+}
+
+// faultyWriter is the response writer resetting, stalling or truncating the response after the bytes
+// of the body of the fault are written.
+type faultyWriter struct {
+	http.ResponseWriter
+
+	// ctx is the context of the request.
+	ctx context.Context
+
+	// fault is the fault injected into the response.
+	fault *backend.Fault
+
+	// written is the bytes of the body written.
+	written uint64
+
+	// injected is true if the fault is injected.
+	injected bool
+}
+
+// Write writes the body until the bytes of the fault are written, then injects the fault.
+func (f *faultyWriter) Write(p []byte) (int, error) {
+	if f.injected && f.fault.Profile == backend.FaultStall {
+		return f.ResponseWriter.Write(p)
+	}
+
+	var written int
+	if f.written < f.fault.After {
+		n, err := f.ResponseWriter.Write(p[:min(uint64(len(p)), f.fault.After-f.written)])
+		written += n
+		f.written += uint64(n)
+		if err != nil || written == len(p) {
+			return written, err
+		}
+	}
+
+	f.injected = true
+	switch f.fault.Profile {
+	case backend.FaultStall:
+		timer := time.NewTimer(f.fault.Stall)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-f.ctx.Done():
+			return written, f.ctx.Err()
+		}
+
+		n, err := f.ResponseWriter.Write(p[written:])
+		return written + n, err
+	case backend.FaultReset:
+		return written, f.reset()
+	default:
+		// The server closes the connection as the body is shorter than the Content-Length.
+		return written, errTruncated
+	}
+}
+
+// Unwrap returns the underlying response writer for the response controller.
+func (f *faultyWriter) Unwrap() http.ResponseWriter {
+	return f.ResponseWriter
+}
+
+// reset flushes the written response and resets the connection by closing it with the zero linger, so
+// a TCP RST is sent instead of a FIN.
+func (f *faultyWriter) reset() error {
+	controller := http.NewResponseController(f.ResponseWriter)
+	if err := controller.Flush(); err != nil {
+		return err
+	}
+
+	conn, _, err := controller.Hijack()
+	if err != nil {
+		logrus.Warnf("failed to hijack connection, the response is truncated instead: %v", err)
+		return errTruncated
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetLinger(0); err != nil {
+			logrus.Warnf("failed to set linger of connection: %v", err)
+		}
+	}
+
+	if err := conn.Close(); err != nil {
+		return err
+	}
+
+	return errTruncated
+}
@@ -0,0 +1,212 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+func TestInjectFault(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  *backend.Fault
+		ok     bool
+		faulty bool
+		status int
+	}{
+		{name: "no fault", ok: true, status: http.StatusOK},
+		{name: "error", fault: &backend.Fault{Profile: backend.FaultError, Rate: 1, Status: http.StatusBadGateway}, status: http.StatusBadGateway},
+		{name: "truncate", fault: &backend.Fault{Profile: backend.FaultTruncate, Rate: 1}, ok: true, faulty: true, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			w, ok := injectFault(context.Background(), recorder, &backend.Origin{Fault: tt.fault})
+			if ok != tt.ok {
+				t.Fatalf("injectFault ok = %t, want %t", ok, tt.ok)
+			}
+
+			if _, faulty := w.(*faultyWriter); faulty != tt.faulty {
+				t.Errorf("injectFault faulty writer = %t, want %t", faulty, tt.faulty)
+			}
+
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestFaultyWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   backend.Fault
+		writes  []int
+		written []int
+		err     error
+		minCost time.Duration
+	}{
+		{
+			name:    "truncate in the first write",
+			fault:   backend.Fault{Profile: backend.FaultTruncate, After: 100},
+			writes:  []int{1000},
+			written: []int{100},
+			err:     errTruncated,
+		},
+		{
+			name:    "truncate across writes",
+			fault:   backend.Fault{Profile: backend.FaultTruncate, After: 100},
+			writes:  []int{60, 60, 60},
+			written: []int{60, 40},
+			err:     errTruncated,
+		},
+		{
+			name:    "truncate at the write boundary",
+			fault:   backend.Fault{Profile: backend.FaultTruncate, After: 120},
+			writes:  []int{60, 60, 60},
+			written: []int{60, 60, 0},
+			err:     errTruncated,
+		},
+		{
+			name:    "truncate before the first byte",
+			fault:   backend.Fault{Profile: backend.FaultTruncate},
+			writes:  []int{60},
+			written: []int{0},
+			err:     errTruncated,
+		},
+		{
+			name:    "reset without a connection is truncated",
+			fault:   backend.Fault{Profile: backend.FaultReset, After: 10},
+			writes:  []int{60},
+			written: []int{10},
+			err:     errTruncated,
+		},
+		{
+			name:    "stall once",
+			fault:   backend.Fault{Profile: backend.FaultStall, After: 100, Stall: 100 * time.Millisecond},
+			writes:  []int{60, 60, 60},
+			written: []int{60, 60, 60},
+			minCost: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			w := &faultyWriter{ResponseWriter: recorder, ctx: context.Background(), fault: &tt.fault}
+
+			var (
+				total int
+				err   error
+			)
+			start := time.Now()
+			for i, size := range tt.writes {
+				var n int
+				n, err = w.Write(make([]byte, size))
+				if i >= len(tt.written) {
+					t.Fatalf("write %d after the fault", i)
+				}
+
+				if n != tt.written[i] {
+					t.Errorf("write %d = %d bytes, want %d", i, n, tt.written[i])
+				}
+
+				total += n
+				if err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+
+			if recorder.Body.Len() != total {
+				t.Errorf("recorded %d bytes, want %d", recorder.Body.Len(), total)
+			}
+
+			if cost := time.Since(start); cost < tt.minCost {
+				t.Errorf("writes took %v, want at least %v", cost, tt.minCost)
+			}
+		})
+	}
+}
+
+func TestFaultyWriterStallCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := httptest.NewRecorder()
+	w := &faultyWriter{ResponseWriter: recorder, ctx: ctx, fault: &backend.Fault{Profile: backend.FaultStall, After: 10, Stall: time.Minute}}
+	n, err := w.Write(make([]byte, 100))
+	if n != 10 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Write = %d, %v, want 10, %v", n, err, context.Canceled)
+	}
+}
+
+func TestServeFileFault(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   int
+		// atMost is true if the body may be cut short, as the unread bytes are discarded by the reset.
+		atMost bool
+	}{
+		{name: "error", query: "fault=error&fault_status=503", status: http.StatusServiceUnavailable},
+		{name: "truncate", query: "fault=truncate&fault_after=1000", status: http.StatusOK, body: 1000},
+		{name: "reset", query: "fault=reset&fault_after=1000", status: http.StatusOK, body: 1000, atMost: true},
+		{name: "no fault by the rate", query: "fault=truncate&fault_rate=0.0000001", status: http.StatusOK, body: 65536},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := server.Client().Get(server.URL + "/size/65536?" + tt.query)
+			if err != nil {
+				t.Fatalf("failed to get file: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if tt.body < 65536 && err == nil {
+				t.Errorf("read %d bytes of the faulty response without error", len(body))
+			}
+
+			if len(body) != tt.body && (!tt.atMost || len(body) > tt.body) {
+				t.Errorf("read %d bytes, want %d", len(body), tt.body)
+			}
+		})
+	}
+}
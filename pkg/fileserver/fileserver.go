@@ -0,0 +1,147 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// shutdownTimeout is the timeout to wait for the requests in flight when the file server is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// FileServer represents the origin of the files downloaded by the benchmarks.
+type FileServer interface {
+	// Serve serves the files until the context is done.
+	Serve(context.Context) error
+}
+
+// fileServer implements the FileServer interface.
+type fileServer struct {
+	// config is the configuration of the file server.
+	config *config.FileServerConfig
+
+	// digests is the digests of the whole files by size, they are computed at the first request.
+	digests *sync.Map
+
+	// limiters is the limiters of the aggregate bandwidth caps shared by the connections.
+	limiters *sync.Map
+
+	// traffic is the traffic served by task.
+	traffic *sync.Map
+}
+
+// New creates a new file server serving the files of the file size levels, e.g. /small, and the
+// files of any size, e.g. /size/268435456, their digests under /digest, and the traffic served by
+// task under /stats. The content is generated on the fly from the seed, so
+// nothing is stored on the disk and the same content is served for the same seed and size.
+func New(config *config.FileServerConfig) FileServer {
+	return &fileServer{config, &sync.Map{}, &sync.Map{}, &sync.Map{}}
+}
+
+// Serve serves the files until the context is done.
+func (f *fileServer) Serve(ctx context.Context) error {
+	server := &http.Server{Addr: f.config.Addr, Handler: f.handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("failed to shutdown file server: %v", err)
+		}
+	}()
+
+	logrus.Infof("file server is listening on %s", f.config.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorf("failed to serve files: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// handler returns the handler of the files and their digests, the tag and uuid query strings are
+// ignored, so the requests of the different tasks are served the same file.
+func (f *fileServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	handleSizes(mux, "", f.serveFile)
+	handleSizes(mux, "/"+backend.DigestPath, f.serveDigest)
+	mux.HandleFunc("GET /"+backend.StatsPath, f.serveStats)
+	mux.HandleFunc("DELETE /"+backend.StatsPath, f.serveStats)
+	return mux
+}
+
+// handleSizes registers the handler of the files of the file size levels and the files of any size
+// under the prefix, the handler is called with the size of the file.
+func handleSizes(mux *http.ServeMux, prefix string, handle func(http.ResponseWriter, *http.Request, int64)) {
+	mux.HandleFunc("GET "+prefix+"/zero", func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, 0)
+	})
+
+	for _, fileSizeLevel := range backend.FileSizeLevels {
+		mux.HandleFunc("GET "+prefix+"/"+string(fileSizeLevel), func(w http.ResponseWriter, r *http.Request) {
+			handle(w, r, int64(fileSizeLevel.Bytes()))
+		})
+	}
+
+	mux.HandleFunc("GET "+prefix+"/size/{size}", func(w http.ResponseWriter, r *http.Request) {
+		size, err := strconv.ParseInt(r.PathValue("size"), 10, 64)
+		if err != nil || size < 0 {
+			http.Error(w, fmt.Sprintf("invalid size %q", r.PathValue("size")), http.StatusBadRequest)
+			return
+		}
+
+		handle(w, r, size)
+	})
+}
+
+// serveFile serves the file of the size, the Range, If-Range and If-None-Match headers are handled
+// by the ETag of the seed and the size. The response is delayed, capped and injected with the fault
+// by the origin of the query parameters.
+func (f *fileServer) serveFile(w http.ResponseWriter, r *http.Request, size int64) {
+	logrus.Debugf("serving %s of %d bytes for %s with range %q", r.URL, size, r.RemoteAddr, r.Header.Get("Range"))
+	origin, err := backend.ParseOrigin(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := delay(r.Context(), origin); err != nil {
+		logrus.Debugf("request %s is canceled before the first byte: %v", r.URL, err)
+		return
+	}
+
+	w = f.shape(r.Context(), f.count(w, r), origin)
+	w, ok := injectFault(r.Context(), w, origin)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, f.config.Seed, size))
+	http.ServeContent(w, r, "", time.Time{}, newContent(f.config.Seed, size))
+}
@@ -0,0 +1,188 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// testSeed is the seed of the content served by the test file server.
+const testSeed = 42
+
+// newTestServer starts the file server of the test seed.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(New(&config.FileServerConfig{Seed: testSeed}).(*fileServer).handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get sends the GET request of the path with the headers to the server.
+func get(t *testing.T, server *httptest.Server, path string, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body of %s: %v", path, err)
+	}
+
+	return resp, body
+}
+
+func TestServeFile(t *testing.T) {
+	server := newTestServer(t)
+	small := readContent(t, testSeed, 1<<20, 0, 1<<20)
+	etag := fmt.Sprintf(`"%x-%x"`, testSeed, 1<<20)
+
+	tests := []struct {
+		name         string
+		path         string
+		headers      map[string]string
+		status       int
+		body         []byte
+		contentRange string
+	}{
+		{
+			name:   "file size level",
+			path:   "/small?tag=dfget&uuid=1",
+			status: http.StatusOK,
+			body:   small,
+		},
+		{
+			name:   "file of any size",
+			path:   "/size/1048576",
+			status: http.StatusOK,
+			body:   small,
+		},
+		{
+			name:   "zero",
+			path:   "/zero",
+			status: http.StatusOK,
+			body:   []byte{},
+		},
+		{
+			name:         "range",
+			path:         "/small",
+			headers:      map[string]string{"Range": "bytes=1000-1999"},
+			status:       http.StatusPartialContent,
+			body:         small[1000:2000],
+			contentRange: "bytes 1000-1999/1048576",
+		},
+		{
+			name:         "suffix range",
+			path:         "/small",
+			headers:      map[string]string{"Range": "bytes=-100"},
+			status:       http.StatusPartialContent,
+			body:         small[len(small)-100:],
+			contentRange: "bytes 1048476-1048575/1048576",
+		},
+		{
+			name:         "range beyond the end",
+			path:         "/size/100",
+			headers:      map[string]string{"Range": "bytes=200-"},
+			status:       http.StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */100",
+		},
+		{
+			name:    "matching If-None-Match",
+			path:    "/small",
+			headers: map[string]string{"If-None-Match": etag},
+			status:  http.StatusNotModified,
+			body:    []byte{},
+		},
+		{
+			name:         "matching If-Range",
+			path:         "/small",
+			headers:      map[string]string{"Range": "bytes=0-9", "If-Range": etag},
+			status:       http.StatusPartialContent,
+			body:         small[:10],
+			contentRange: "bytes 0-9/1048576",
+		},
+		{
+			name:    "mismatching If-Range",
+			path:    "/small",
+			headers: map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`},
+			status:  http.StatusOK,
+			body:    small,
+		},
+		{
+			name:   "invalid size",
+			path:   "/size/abc",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown file",
+			path:   "/huge",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(t, server, tt.path, tt.headers)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if tt.body != nil && !bytes.Equal(body, tt.body) {
+				t.Errorf("body of %d bytes mismatches the content of %d bytes", len(body), len(tt.body))
+			}
+
+			if got := resp.Header.Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+
+			if resp.StatusCode == http.StatusOK && resp.ContentLength != int64(len(tt.body)) {
+				t.Errorf("Content-Length = %d, want %d", resp.ContentLength, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestServeFileETag(t *testing.T) {
+	server := newTestServer(t)
+	for _, fileSizeLevel := range []backend.FileSizeLevel{backend.FileSizeLevelNano, backend.FileSizeLevelMicro} {
+		resp, _ := get(t, server, "/"+string(fileSizeLevel), nil)
+		if expected := fmt.Sprintf(`"%x-%x"`, testSeed, fileSizeLevel.Bytes()); resp.Header.Get("ETag") != expected {
+			t.Errorf("ETag of %s = %q, want %q", fileSizeLevel, resp.Header.Get("ETag"), expected)
+		}
+	}
+}
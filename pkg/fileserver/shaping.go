@@ -0,0 +1,121 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"golang.org/x/time/rate"
+)
+
+// maxBurst is the maximum bytes written at once by the shaped response, so the bandwidth is smooth.
+const maxBurst = 32 << 10
+
+// delay waits for the delay before the first byte of the origin, the delay is uniformly distributed
+// in [TTFB - TTFBJitter, TTFB + TTFBJitter] and at least 0.
+func delay(ctx context.Context, origin *backend.Origin) error {
+	d := origin.TTFB
+	if origin.TTFBJitter > 0 {
+		d += time.Duration(rand.Int64N(int64(2*origin.TTFBJitter)+1)) - origin.TTFBJitter
+	}
+
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shape returns the response writer capped by the bandwidth of the connection and the aggregate
+// bandwidth of the origin, the response writer is returned as is if the origin is unlimited.
+func (f *fileServer) shape(ctx context.Context, w http.ResponseWriter, origin *backend.Origin) http.ResponseWriter {
+	var limiters []*rate.Limiter
+	if origin.Bandwidth > 0 {
+		limiters = append(limiters, newLimiter(origin.Bandwidth))
+	}
+
+	if origin.AggregateBandwidth > 0 {
+		limiter, _ := f.limiters.LoadOrStore(origin.AggregateBandwidth, newLimiter(origin.AggregateBandwidth))
+		limiters = append(limiters, limiter.(*rate.Limiter))
+	}
+
+	if len(limiters) == 0 {
+		return w
+	}
+
+	return &shapedWriter{ResponseWriter: w, ctx: ctx, limiters: limiters}
+}
+
+// newLimiter creates a limiter of the bandwidth in bytes per second.
+func newLimiter(bandwidth uint64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bandwidth), int(max(1, min(bandwidth, maxBurst))))
+}
+
+// shapedWriter is the response writer capped by the limiters, the body is written in bursts once
+// all limiters allow them.
+type shapedWriter struct {
+	http.ResponseWriter
+
+	// ctx is the context of the request.
+	ctx context.Context
+
+	// limiters is the bandwidth caps of the response.
+	limiters []*rate.Limiter
+}
+
+// Write writes the body in bursts allowed by all limiters.
+func (s *shapedWriter) Write(p []byte) (int, error) {
+	burst := maxBurst
+	for _, limiter := range s.limiters {
+		burst = min(burst, limiter.Burst())
+	}
+
+	var written int
+	for written < len(p) {
+		n := min(burst, len(p)-written)
+		for _, limiter := range s.limiters {
+			if err := limiter.WaitN(s.ctx, n); err != nil {
+				return written, err
+			}
+		}
+
+		m, err := s.ResponseWriter.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Unwrap returns the underlying response writer for the response controller.
+func (s *shapedWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
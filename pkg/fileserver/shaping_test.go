@@ -0,0 +1,167 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// recordingWriter is the response recorder recording the size of every write.
+type recordingWriter struct {
+	*httptest.ResponseRecorder
+
+	// writes is the size of every write.
+	writes []int
+}
+
+// Write records the size of the write.
+func (r *recordingWriter) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, len(p))
+	return r.ResponseRecorder.Write(p)
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		origin   backend.Origin
+		min, max time.Duration
+	}{
+		{name: "no delay", max: 10 * time.Millisecond},
+		{name: "ttfb", origin: backend.Origin{TTFB: 50 * time.Millisecond}, min: 50 * time.Millisecond, max: 500 * time.Millisecond},
+		{name: "ttfb with jitter", origin: backend.Origin{TTFB: 50 * time.Millisecond, TTFBJitter: 20 * time.Millisecond}, min: 30 * time.Millisecond, max: 500 * time.Millisecond},
+		{name: "jitter only", origin: backend.Origin{TTFBJitter: 20 * time.Millisecond}, max: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if err := delay(context.Background(), &tt.origin); err != nil {
+				t.Fatalf("delay failed: %v", err)
+			}
+
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("delay = %v, want in [%v, %v]", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := delay(ctx, &backend.Origin{TTFB: time.Minute}); err == nil {
+		t.Error("delay of the canceled request succeeded")
+	}
+}
+
+func TestShape(t *testing.T) {
+	f := New(&config.FileServerConfig{Seed: testSeed}).(*fileServer)
+	w := httptest.NewRecorder()
+	if shaped := f.shape(context.Background(), w, &backend.Origin{}); shaped != http.ResponseWriter(w) {
+		t.Error("response of the unlimited origin is shaped")
+	}
+
+	a := f.shape(context.Background(), w, &backend.Origin{AggregateBandwidth: 1 << 20}).(*shapedWriter)
+	b := f.shape(context.Background(), w, &backend.Origin{Bandwidth: 1 << 30, AggregateBandwidth: 1 << 20}).(*shapedWriter)
+	if len(a.limiters) != 1 || len(b.limiters) != 2 || a.limiters[0] != b.limiters[1] {
+		t.Error("aggregate limiter is not shared by the connections of the same cap")
+	}
+}
+
+func TestShapedWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		bandwidth uint64
+		size      int
+		maxWrite  int
+		minCost   time.Duration
+	}{
+		{name: "unthrottled burst", bandwidth: 1 << 30, size: 100 << 10, maxWrite: maxBurst},
+		{name: "burst smaller than the write", bandwidth: 16 << 10, size: 24 << 10, maxWrite: 16 << 10, minCost: 400 * time.Millisecond},
+		{name: "throttled", bandwidth: 1 << 20, size: 288 << 10, maxWrite: maxBurst, minCost: 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(&config.FileServerConfig{Seed: testSeed}).(*fileServer)
+			recorder := &recordingWriter{ResponseRecorder: httptest.NewRecorder()}
+			w := f.shape(context.Background(), recorder, &backend.Origin{Bandwidth: tt.bandwidth})
+
+			data := readContent(t, testSeed, int64(tt.size), 0, int64(tt.size))
+			start := time.Now()
+			n, err := w.Write(data)
+			if err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			if cost := time.Since(start); cost < tt.minCost {
+				t.Errorf("Write took %v, want at least %v", cost, tt.minCost)
+			}
+
+			if n != tt.size || !bytes.Equal(recorder.Body.Bytes(), data) {
+				t.Errorf("Write = %d bytes, recorded %d bytes, want %d bytes", n, recorder.Body.Len(), tt.size)
+			}
+
+			for _, write := range recorder.writes {
+				if write > tt.maxWrite {
+					t.Errorf("write of %d bytes exceeds %d bytes", write, tt.maxWrite)
+				}
+			}
+		})
+	}
+}
+
+func TestShapedWriterCanceled(t *testing.T) {
+	f := New(&config.FileServerConfig{Seed: testSeed}).(*fileServer)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	recorder := httptest.NewRecorder()
+	w := f.shape(ctx, recorder, &backend.Origin{Bandwidth: 1 << 10})
+	n, err := w.Write(make([]byte, 1<<20))
+	if err == nil {
+		t.Fatal("Write of the canceled request succeeded")
+	}
+
+	if n != recorder.Body.Len() || n >= 1<<20 {
+		t.Errorf("Write = %d bytes, recorded %d bytes, want the recorded bytes of a partial write", n, recorder.Body.Len())
+	}
+}
+
+func TestServeFileShaped(t *testing.T) {
+	server := newTestServer(t)
+	start := time.Now()
+	resp, body := get(t, server, "/size/65536?bandwidth=262144&ttfb=50ms", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// The first burst is sent at once, and the rest at the bandwidth after the first byte.
+	if cost := time.Since(start); cost < 150*time.Millisecond {
+		t.Errorf("download took %v, want at least %v", cost, 150*time.Millisecond)
+	}
+
+	if !bytes.Equal(body, readContent(t, testSeed, 65536, 0, 65536)) {
+		t.Error("shaped body mismatches the content")
+	}
+}
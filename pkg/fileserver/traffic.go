@@ -0,0 +1,147 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/sirupsen/logrus"
+)
+
+// taskKey is the key of the traffic of a task.
+type taskKey struct {
+	// tag is the tag query parameter of the file URL.
+	tag string
+
+	// uuid is the uuid query parameter of the file URL.
+	uuid string
+}
+
+// task is the traffic served for the paths of a task, the paths of a task are the byte ranges of the
+// same file in the sequential range mode, or the files of an image.
+type task struct {
+	// paths is the traffic served by path.
+	paths sync.Map
+}
+
+// taskTraffic is the traffic served for a path of a task, it is safe for concurrent use.
+type taskTraffic struct {
+	// requests is the number of the requests.
+	requests atomic.Uint64
+
+	// bytes is the bytes of the bodies sent.
+	bytes atomic.Uint64
+}
+
+// count returns the response writer counting the bytes of the body sent for the task of the request,
+// the requests without the tag or uuid query parameters do not belong to a task and are not counted.
+func (f *fileServer) count(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	query := r.URL.Query()
+	key := taskKey{tag: query.Get("tag"), uuid: query.Get("uuid")}
+	if key.tag == "" || key.uuid == "" {
+		return w
+	}
+
+	value, _ := f.traffic.LoadOrStore(key, &task{})
+	traffic, _ := value.(*task).paths.LoadOrStore(r.URL.Path, &taskTraffic{})
+	t := traffic.(*taskTraffic)
+	t.requests.Add(1)
+	return &countingWriter{ResponseWriter: w, traffic: t}
+}
+
+// serveStats serves the traffic of the tasks in JSON, filtered by the tag query parameter if specified,
+// and only the tasks of the uuid query parameters are looked up if specified, e.g.
+// /stats?tag=dfget&uuid=<uuid>&uuid=<uuid>. The tasks served are removed by the DELETE method, so the
+// traffic of the tasks read is not kept by the file server, and the next request of a task removed
+// counts from zero.
+func (f *fileServer) serveStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tag, uuids := query.Get("tag"), query["uuid"]
+
+	// The tasks of the tag and uuids are looked up directly, so the cost does not grow with the tasks kept.
+	var keys []taskKey
+	if tag != "" && len(uuids) > 0 {
+		for _, uuid := range uuids {
+			keys = append(keys, taskKey{tag: tag, uuid: uuid})
+		}
+	} else {
+		f.traffic.Range(func(key, _ any) bool {
+			k := key.(taskKey)
+			if (tag == "" || k.tag == tag) && (len(uuids) == 0 || slices.Contains(uuids, k.uuid)) {
+				keys = append(keys, k)
+			}
+
+			return true
+		})
+	}
+
+	tasks := []*backend.TaskTraffic{}
+	for _, key := range keys {
+		var value any
+		var ok bool
+		if r.Method == http.MethodDelete {
+			value, ok = f.traffic.LoadAndDelete(key)
+		} else {
+			value, ok = f.traffic.Load(key)
+		}
+
+		if !ok {
+			continue
+		}
+
+		value.(*task).paths.Range(func(path, traffic any) bool {
+			t := traffic.(*taskTraffic)
+			tasks = append(tasks, &backend.TaskTraffic{Path: path.(string), Tag: key.tag, UUID: key.uuid, Requests: t.requests.Load(), Bytes: t.bytes.Load()})
+			return true
+		})
+	}
+
+	slices.SortFunc(tasks, func(a, b *backend.TaskTraffic) int {
+		return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(a.UUID, b.UUID), cmp.Compare(a.Path, b.Path))
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+		logrus.Errorf("failed to write stats: %v", err)
+	}
+}
+
+// countingWriter is the response writer counting the bytes of the body sent.
+type countingWriter struct {
+	http.ResponseWriter
+
+	// traffic is the traffic of the task.
+	traffic *taskTraffic
+}
+
+// Write writes the body and counts the bytes written.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.traffic.bytes.Add(uint64(n))
+	return n, err
+}
+
+// Unwrap returns the underlying response writer for the response controller.
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
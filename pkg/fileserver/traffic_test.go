@@ -0,0 +1,159 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fileserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+// readStats sends the request of the method to the stats with the query and returns the traffic of the tasks.
+func readStats(t *testing.T, server *httptest.Server, method, query string) []*backend.TaskTraffic {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+"/"+backend.StatsPath+"?"+query, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to %s stats: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var tasks []*backend.TaskTraffic
+	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+
+	return tasks
+}
+
+func TestServeStats(t *testing.T) {
+	server := newTestServer(t)
+	for _, path := range []string{
+		"/nano?tag=dfget&uuid=1",
+		"/nano?tag=dfget&uuid=1",
+		"/micro?tag=dfget&uuid=1",
+		"/nano?tag=dfget&uuid=3",
+		"/size/100?tag=proxy&uuid=2",
+		"/nano",
+		"/nano?tag=dfget",
+	} {
+		if resp, _ := get(t, server, path, nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status of %s = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	// The ranges are counted by the bytes sent.
+	if resp, _ := get(t, server, "/size/100?tag=proxy&uuid=2", map[string]string{"Range": "bytes=0-9"}); resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("status of range = %d, want %d", resp.StatusCode, http.StatusPartialContent)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []*backend.TaskTraffic
+	}{
+		{
+			name: "all tasks",
+			expected: []*backend.TaskTraffic{
+				{Path: "/micro", Tag: "dfget", UUID: "1", Requests: 1, Bytes: backend.FileSizeLevelMicro.Bytes()},
+				{Path: "/nano", Tag: "dfget", UUID: "1", Requests: 2, Bytes: 2},
+				{Path: "/nano", Tag: "dfget", UUID: "3", Requests: 1, Bytes: 1},
+				{Path: "/size/100", Tag: "proxy", UUID: "2", Requests: 2, Bytes: 110},
+			},
+		},
+		{
+			name:  "tasks of the tag",
+			query: "tag=proxy",
+			expected: []*backend.TaskTraffic{
+				{Path: "/size/100", Tag: "proxy", UUID: "2", Requests: 2, Bytes: 110},
+			},
+		},
+		{
+			name:  "tasks of the tag and uuids",
+			query: "tag=dfget&uuid=3&uuid=2&uuid=4",
+			expected: []*backend.TaskTraffic{
+				{Path: "/nano", Tag: "dfget", UUID: "3", Requests: 1, Bytes: 1},
+			},
+		},
+		{
+			name:  "tasks of the uuids in all tags",
+			query: "uuid=2&uuid=3",
+			expected: []*backend.TaskTraffic{
+				{Path: "/nano", Tag: "dfget", UUID: "3", Requests: 1, Bytes: 1},
+				{Path: "/size/100", Tag: "proxy", UUID: "2", Requests: 2, Bytes: 110},
+			},
+		},
+		{
+			name:     "no task of the tag",
+			query:    "tag=dfcache",
+			expected: []*backend.TaskTraffic{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tasks := readStats(t, server, http.MethodGet, tt.query); !reflect.DeepEqual(tasks, tt.expected) {
+				t.Errorf("stats = %v, want %v", tasks, tt.expected)
+			}
+		})
+	}
+}
+
+func TestServeStatsDelete(t *testing.T) {
+	server := newTestServer(t)
+	for _, path := range []string{"/nano?tag=dfget&uuid=1", "/micro?tag=dfget&uuid=1", "/nano?tag=dfget&uuid=2"} {
+		if resp, _ := get(t, server, path, nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("status of %s = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	expected := []*backend.TaskTraffic{
+		{Path: "/micro", Tag: "dfget", UUID: "1", Requests: 1, Bytes: backend.FileSizeLevelMicro.Bytes()},
+		{Path: "/nano", Tag: "dfget", UUID: "1", Requests: 1, Bytes: 1},
+	}
+	if tasks := readStats(t, server, http.MethodDelete, "tag=dfget&uuid=1"); !reflect.DeepEqual(tasks, expected) {
+		t.Fatalf("deleted stats = %v, want %v", tasks, expected)
+	}
+
+	// The task deleted is not kept, and counts from zero at the next request.
+	expected = []*backend.TaskTraffic{{Path: "/nano", Tag: "dfget", UUID: "2", Requests: 1, Bytes: 1}}
+	if tasks := readStats(t, server, http.MethodGet, "tag=dfget"); !reflect.DeepEqual(tasks, expected) {
+		t.Fatalf("stats after delete = %v, want %v", tasks, expected)
+	}
+
+	if resp, _ := get(t, server, "/nano?tag=dfget&uuid=1", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	expected = []*backend.TaskTraffic{{Path: "/nano", Tag: "dfget", UUID: "1", Requests: 1, Bytes: 1}}
+	if tasks := readStats(t, server, http.MethodGet, "tag=dfget&uuid=1"); !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("stats of the task counted again = %v, want %v", tasks, expected)
+	}
+}
@@ -0,0 +1,512 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/manager"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// containerdNamespace is the containerd namespace of the images used by the kubelet.
+	containerdNamespace = "k8s.io"
+
+	// pullPrefix is the prefix of the timing line written by the pull script.
+	pullPrefix = "dfbench_pull"
+
+	// pullScript pulls the image by the pull command and prints its output, the timestamps are in nanoseconds.
+	pullScript = `set -e
+start=$(date +%%s%%N)
+%s 2>&1
+end=$(date +%%s%%N)
+echo "%s $start $end"
+`
+
+	// manifestMediaTypes is the media types of the manifests accepted from the local registry.
+	manifestMediaTypes = "application/vnd.oci.image.index.v1+json, application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.docker.distribution.manifest.v2+json"
+)
+
+var (
+	// escapeRegexp matches the terminal escape sequences of the progress output of ctr.
+	escapeRegexp = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// layerRegexp matches the status of a layer in the progress output of ctr, e.g.
+	// "layer-sha256:<hex>: done" by the local pull and "layer (<short hex>) complete" by the transfer service.
+	layerRegexp = regexp.MustCompile(`layer(?:-(sha256:[0-9a-f]+):| \(([0-9a-f]+)\))\s+(\w+)`)
+
+	// elapsedRegexp matches the elapsed time at the end of every frame of the progress output of ctr.
+	elapsedRegexp = regexp.MustCompile(`elapsed:\s*([0-9.]+)\s*s`)
+)
+
+// Image represents a benchmark runner for the image pulls through the dfdaemon registry mirror.
+type Image interface {
+	// Run runs the benchmarks of all images.
+	Run(context.Context) error
+
+	// RunByImage runs the benchmark of the image.
+	RunByImage(context.Context, string) error
+
+	// Cleanup cleans up the images.
+	Cleanup(context.Context) error
+}
+
+// image implements the Image interface.
+type image struct {
+	// config is the configuration of the benchmark.
+	config *config.ImageConfig
+
+	// executor is the executor to run commands in the client pods.
+	executor executor.Executor
+
+	// stats is the statistics of the benchmark.
+	stats stats.Stats
+
+	// manager is the client of the manager to preheat the images.
+	manager manager.Manager
+}
+
+// New creates a new benchmark runner for the image pulls, the commands are executed on the nodes by
+// entering the host namespaces from the privileged client pods, and containerd on the nodes must be
+// configured to use the dfdaemon as the registry mirror.
+func New(config *config.ImageConfig, executor executor.Executor, stats stats.Stats, manager manager.Manager) Image {
+	return &image{config, executor, stats, manager}
+}
+
+// Run runs the benchmarks of all images.
+func (i *image) Run(ctx context.Context) error {
+	for _, reference := range i.config.References() {
+		if err := i.RunByImage(ctx, reference); err != nil {
+			logrus.Errorf("failed to run image benchmark of %s: %v", reference, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunByImage runs the benchmark of the image, every iteration preheats the image if enabled, pulls it on all
+// nodes at the same time, collects the dfdaemon traffic of the pulls and removes the image. The image of the
+// local registry is copied to a new repository, so its layers are new tasks of the dfdaemon, for every iteration
+// in the cold cache mode and for the first iteration in the hot cache mode, and the pulls of the same repository
+// after the first iteration are reported as warm as they are served by the tasks of the previous iterations.
+func (i *image) RunByImage(ctx context.Context, reference string) error {
+	pods, err := i.getClientPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The layers of the image pulled before the benchmark are removed to pull them through the dfdaemon.
+	if err := i.removeImage(ctx, pods, reference); err != nil {
+		return err
+	}
+
+	local := i.inRegistry(reference)
+	if !local && i.config.Cache == config.CacheCold && i.config.Number > 1 {
+		logrus.Warnf("image %s is not in the local registry to be copied for every iteration, the pulls after the first iteration are warm", reference)
+	}
+
+	pullReference := reference
+	for n := uint32(1); n <= i.config.Number; n++ {
+		warm := n > 1
+		if local && (n == 1 || i.config.Cache == config.CacheCold) {
+			if pullReference, err = i.copyImage(ctx, pods[0], reference); err != nil {
+				return err
+			}
+
+			warm = false
+		}
+
+		logrus.Debugf("pulling image %s %d/%d", pullReference, n, i.config.Number)
+		var preheat time.Duration
+		if i.config.Preheat {
+			if preheat, err = i.preheat(ctx, pods[0], pullReference); err != nil {
+				return err
+			}
+		}
+
+		if err := i.stats.ResetClientMetrics(ctx); err != nil {
+			logrus.Errorf("failed to reset client metrics: %v", err)
+			return err
+		}
+
+		var eg errgroup.Group
+		for _, pod := range pods {
+			eg.Go(func(pod string) func() error {
+				return func() error {
+					pull, err := i.pullImage(ctx, pod, pullReference)
+					if err != nil {
+						return err
+					}
+
+					pull.Image = reference
+					pull.Warm = warm
+					pull.Preheat = preheat
+					i.stats.AddImagePull(pull)
+					return nil
+				}
+			}(pod))
+		}
+
+		if err := eg.Wait(); err != nil {
+			logrus.Errorf("error processing pods: %v", err)
+			return err
+		}
+
+		if err := i.stats.CollectImageMetrics(ctx, reference, warm); err != nil {
+			logrus.Errorf("failed to collect client metrics: %v", err)
+			return err
+		}
+
+		if err := i.removeImage(ctx, pods, pullReference); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inRegistry returns true if the image is in the local registry.
+func (i *image) inRegistry(reference string) bool {
+	return i.config.Registry != "" && strings.HasPrefix(reference, strings.TrimSuffix(i.config.Registry, "/")+"/")
+}
+
+// copyImage copies the image of the local registry to a new repository by mounting its blobs from the
+// repository of the image, and returns the reference of the copy. The registry is requested on the node
+// of the pod, as it is exposed on the nodes.
+func (i *image) copyImage(ctx context.Context, pod string, reference string) (string, error) {
+	registry := strings.TrimSuffix(i.config.Registry, "/")
+	repository, tag := splitReference(strings.TrimPrefix(reference, registry+"/"))
+	copied := fmt.Sprintf("%s-dfbench-%s", repository, uuid.New().String()[:8])
+	if err := i.copyManifest(ctx, pod, registry, repository, copied, tag); err != nil {
+		logrus.Errorf("failed to copy image %s: %v", reference, err)
+		return "", err
+	}
+
+	if strings.Contains(tag, ":") {
+		return fmt.Sprintf("%s/%s@%s", registry, copied, tag), nil
+	}
+
+	return fmt.Sprintf("%s/%s:%s", registry, copied, tag), nil
+}
+
+// descriptor represents the descriptor of a blob or a manifest referenced by a manifest.
+type descriptor struct {
+	// Digest is the digest of the content.
+	Digest string `json:"digest"`
+}
+
+// manifest represents the image manifest or the image index in the local registry.
+type manifest struct {
+	// MediaType is the media type of the manifest, it may be omitted by the OCI manifests.
+	MediaType string `json:"mediaType"`
+
+	// Config is the image config of the image manifest.
+	Config *descriptor `json:"config"`
+
+	// Layers is the layers of the image manifest.
+	Layers []descriptor `json:"layers"`
+
+	// Manifests is the manifests of the image index.
+	Manifests []descriptor `json:"manifests"`
+}
+
+// copyManifest copies the manifest of the reference with the manifests and the blobs it references
+// from the repository to the other repository of the registry, the manifest is copied byte for byte
+// so its digest is kept.
+func (i *image) copyManifest(ctx context.Context, pod string, registry string, from string, to string, reference string) error {
+	body, err := i.hostExec(ctx, pod, fmt.Sprintf("curl -sSf -H %s %s", shellQuote("Accept: "+manifestMediaTypes), shellQuote(fmt.Sprintf("http://%s/v2/%s/manifests/%s", registry, from, reference))))
+	if err != nil {
+		return fmt.Errorf("failed to get manifest %s of %s: %w: %s", reference, from, err, string(body))
+	}
+
+	m := &manifest{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return fmt.Errorf("failed to decode manifest %s of %s: %w", reference, from, err)
+	}
+
+	for _, child := range m.Manifests {
+		if err := i.copyManifest(ctx, pod, registry, from, to, child.Digest); err != nil {
+			return err
+		}
+	}
+
+	blobs := m.Layers
+	if m.Config != nil {
+		blobs = append(blobs, *m.Config)
+	}
+
+	for _, blob := range blobs {
+		// The registry responds 201 if the blob is mounted, and 202 to start an upload if it is not found.
+		output, err := i.hostExec(ctx, pod, fmt.Sprintf("curl -sS -o /dev/null -w '%%{http_code}' -X POST %s", shellQuote(fmt.Sprintf("http://%s/v2/%s/blobs/uploads/?mount=%s&from=%s", registry, to, blob.Digest, from))))
+		if err != nil || strings.TrimSpace(string(output)) != "201" {
+			return fmt.Errorf("failed to mount blob %s from %s: %v: %s", blob.Digest, from, err, string(output))
+		}
+	}
+
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = "application/vnd.oci.image.manifest.v1+json"
+		if len(m.Manifests) > 0 {
+			mediaType = "application/vnd.oci.image.index.v1+json"
+		}
+	}
+
+	if output, err := i.hostExec(ctx, pod, fmt.Sprintf("curl -sSf -X PUT -H %s --data-binary %s %s", shellQuote("Content-Type: "+mediaType), shellQuote(string(body)), shellQuote(fmt.Sprintf("http://%s/v2/%s/manifests/%s", registry, to, reference)))); err != nil {
+		return fmt.Errorf("failed to put manifest %s of %s: %w: %s", reference, to, err, string(output))
+	}
+
+	return nil
+}
+
+// splitReference splits the image reference without the registry host into the repository and the tag
+// or the digest, the tag is latest if omitted.
+func splitReference(reference string) (string, string) {
+	if i := strings.Index(reference, "@"); i >= 0 {
+		return reference[:i], reference[i+1:]
+	}
+
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		return reference[:i], reference[i+1:]
+	}
+
+	return reference, "latest"
+}
+
+// shellQuote quotes the string in single quotes for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// preheat preheats the image by the manager in the pod and returns the time of the preheat job.
+func (i *image) preheat(ctx context.Context, pod string, reference string) (time.Duration, error) {
+	manifestURL, err := manifestURL(reference)
+	if err != nil {
+		logrus.Errorf("failed to get manifest URL of %s: %v", reference, err)
+		return 0, err
+	}
+
+	start := time.Now()
+	job, err := i.manager.Preheat(ctx, pod, &manager.PreheatArgs{Type: manager.PreheatTypeImage, URL: manifestURL})
+	if err != nil {
+		logrus.Errorf("failed to preheat image %s: %v", reference, err)
+		return 0, err
+	}
+
+	logrus.Debugf("preheat job %d of %s is finished", job.ID, reference)
+	return time.Since(start), nil
+}
+
+// manifestURL returns the manifest URL of the image reference for the preheat job, the images without
+// a registry host are on Docker Hub, and the registries addressed by IP or localhost are served by HTTP.
+func manifestURL(reference string) (string, error) {
+	name, tag := splitReference(reference)
+
+	host, repository, ok := strings.Cut(name, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, repository = "docker.io", name
+	}
+
+	if host == "docker.io" {
+		host = "index.docker.io"
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+
+	if repository == "" || tag == "" {
+		return "", fmt.Errorf("invalid image reference %q", reference)
+	}
+
+	scheme := "https"
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	if hostname == "localhost" || net.ParseIP(hostname) != nil {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, repository, tag), nil
+}
+
+// pullImage pulls the image on the node of the pod and returns the pull timing.
+func (i *image) pullImage(ctx context.Context, pod string, reference string) (*stats.ImagePull, error) {
+	command := fmt.Sprintf("ctr -n %s images pull --hosts-dir '%s' '%s'", containerdNamespace, i.config.HostsDir, reference)
+	if i.config.Puller == config.PullerCrictl {
+		command = fmt.Sprintf("crictl pull '%s' > /dev/null", reference)
+	}
+
+	output, err := i.hostExec(ctx, pod, fmt.Sprintf(pullScript, command, pullPrefix))
+	if err != nil {
+		logrus.Errorf("failed to pull image %s: %v \nmessage: %s", reference, err, string(output))
+		return nil, err
+	}
+
+	pull := &stats.ImagePull{PodName: pod, Image: reference}
+	if err := parsePull(output, pull); err != nil {
+		logrus.Errorf("failed to parse pull timing: %v", err)
+		return nil, err
+	}
+
+	return pull, nil
+}
+
+// parsePull parses the timestamps written by the pull script and the layer timings in the progress
+// output of ctr into the pull, a layer is pulled at the elapsed time of the first frame it is done in.
+func parsePull(output []byte, pull *stats.ImagePull) error {
+	var (
+		found   bool
+		done    = make(map[string]bool)
+		pending []string
+	)
+	lines := strings.FieldsFunc(escapeRegexp.ReplaceAllString(string(output), ""), func(r rune) bool { return r == '\n' || r == '\r' })
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == pullPrefix {
+			start, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			end, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			pull.Total = time.Duration(end - start)
+			found = true
+			continue
+		}
+
+		if match := layerRegexp.FindStringSubmatch(line); match != nil {
+			digest := match[1]
+			if digest == "" {
+				digest = "sha256:" + match[2]
+			}
+
+			switch match[3] {
+			case "done", "complete", "exists":
+				if !done[digest] {
+					done[digest] = true
+					pending = append(pending, digest)
+				}
+			}
+		}
+
+		if match := elapsedRegexp.FindStringSubmatch(line); match != nil && len(pending) > 0 {
+			elapsed, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return err
+			}
+
+			for _, digest := range pending {
+				pull.Layers = append(pull.Layers, &stats.LayerPull{Digest: digest, Done: time.Duration(elapsed * float64(time.Second))})
+			}
+			pending = nil
+		}
+	}
+
+	if !found {
+		return errors.New("pull timing not found")
+	}
+
+	// The layers done in the last frame without the elapsed time are pulled at the end of the pull.
+	for _, digest := range pending {
+		pull.Layers = append(pull.Layers, &stats.LayerPull{Digest: digest, Done: pull.Total})
+	}
+
+	return nil
+}
+
+// removeImage removes the image on the nodes of the pods, and its layers by the synchronous garbage
+// collection of containerd, so the next pull fetches them through the dfdaemon again.
+func (i *image) removeImage(ctx context.Context, pods []string, reference string) error {
+	command := fmt.Sprintf("ctr -n %s images rm --sync '%s'", containerdNamespace, reference)
+	if i.config.Puller == config.PullerCrictl {
+		command = fmt.Sprintf("crictl rmi '%s'", reference)
+	}
+
+	var eg errgroup.Group
+	for _, pod := range pods {
+		eg.Go(func(pod string) func() error {
+			return func() error {
+				if output, err := i.hostExec(ctx, pod, command); err != nil {
+					logrus.Debugf("failed to remove image %s: %v \nmessage: %s", reference, err, string(output))
+				}
+
+				return nil
+			}
+		}(pod))
+	}
+
+	return eg.Wait()
+}
+
+// Cleanup cleans up the images on all nodes.
+func (i *image) Cleanup(ctx context.Context) error {
+	pods, err := i.getClientPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, reference := range i.config.References() {
+		if err := i.removeImage(ctx, pods, reference); err != nil {
+			logrus.Errorf("failed to remove image %s: %v", reference, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hostExec executes the shell script on the node of the pod by entering the host namespaces.
+func (i *image) hostExec(ctx context.Context, pod string, script string) ([]byte, error) {
+	return i.executor.Exec(ctx, pod, "nsenter", "-t", "1", "-m", "-u", "-i", "-n", "-p", "--", "sh", "-c", script)
+}
+
+// getClientPods returns the client pods.
+func (i *image) getClientPods(ctx context.Context) ([]string, error) {
+	pods, err := i.executor.GetPods(ctx, "component=client")
+	if err != nil {
+		logrus.Errorf("failed to get pods: %v", err)
+		return nil, err
+	}
+
+	if len(pods) == 0 {
+		logrus.Errorf("no client pod found")
+		return nil, errors.New("no client pod found")
+	}
+
+	return pods, nil
+}
@@ -0,0 +1,465 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package image
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/manager"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+)
+
+// testIndex is the image index of library/nginx:latest in the local registry referencing a manifest.
+const testIndex = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"sha256:m1"}]}`
+
+// testManifest is the image manifest sha256:m1 of library/nginx in the local registry without the media type.
+const testManifest = `{"schemaVersion":2,"config":{"digest":"sha256:c1"},"layers":[{"digest":"sha256:l1"},{"digest":"sha256:l2"}],"annotations":{"it's":"quoted"}}`
+
+// fakeExecutor fakes the nodes of the client pods, it records the scripts and replies the manifests
+// of the local registry, the mounts of the blobs and the pulls.
+type fakeExecutor struct {
+	executor.Executor
+
+	mu        sync.Mutex
+	scripts   []string
+	manifests map[string]string
+	mountCode string
+}
+
+// Exec records the script executed on the node and returns the output of the script.
+func (f *fakeExecutor) Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	script := cmd[len(cmd)-1]
+	f.scripts = append(f.scripts, script)
+	switch {
+	case strings.HasPrefix(script, "curl -sSf -H 'Accept:"):
+		for url, manifest := range f.manifests {
+			if strings.HasSuffix(script, "'"+url+"'") {
+				return []byte(manifest), nil
+			}
+		}
+
+		return []byte("curl: (22) The requested URL returned error: 404"), errors.New("exit status 22")
+	case strings.Contains(script, "blobs/uploads/?mount="):
+		return []byte(f.mountCode), nil
+	case strings.HasPrefix(script, pullScript[:6]):
+		return []byte("layer-sha256:l1: done\nelapsed: 0.5 s\ndfbench_pull 1000000000 3000000000\n"), nil
+	default:
+		return nil, nil
+	}
+}
+
+// matching returns the scripts matching the regular expression with the submatches replaced by the template.
+func (f *fakeExecutor) matching(re *regexp.Regexp, template string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matches []string
+	for _, script := range f.scripts {
+		if match := re.FindStringSubmatchIndex(script); match != nil {
+			matches = append(matches, string(re.ExpandString(nil, template, script, match)))
+		}
+	}
+
+	return matches
+}
+
+// newFakeExecutor returns the executor faking two nodes with the local registry serving library/nginx:latest.
+func newFakeExecutor() *fakeExecutor {
+	return &fakeExecutor{
+		Executor: executor.NewLocal(executor.DefaultEndpoint(), executor.DefaultEndpoint()),
+		manifests: map[string]string{
+			"http://127.0.0.1:30500/v2/library/nginx/manifests/latest":    testIndex,
+			"http://127.0.0.1:30500/v2/library/nginx/manifests/sha256:m1": testManifest,
+		},
+		mountCode: "201",
+	}
+}
+
+func TestParsePull(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		total   time.Duration
+		layers  []stats.LayerPull
+		wantErr bool
+	}{
+		{
+			name: "local pull",
+			output: "layer-sha256:aaa: downloading |--| 0.0 B/1.0 KiB\nelapsed: 0.1 s\n" +
+				"layer-sha256:aaa: done\nlayer-sha256:bbb: exists\nelapsed: 0.5 s\n" +
+				"dfbench_pull 1000000000 1800000000\n",
+			total:  800 * time.Millisecond,
+			layers: []stats.LayerPull{{Digest: "sha256:aaa", Done: 500 * time.Millisecond}, {Digest: "sha256:bbb", Done: 500 * time.Millisecond}},
+		},
+		{
+			name:   "transfer service with escape sequences",
+			output: "\x1b[2Klayer (0123456789ab) complete\r\x1b[1Aelapsed: 1.5 s\ndfbench_pull 0 2000000000\n",
+			total:  2 * time.Second,
+			layers: []stats.LayerPull{{Digest: "sha256:0123456789ab", Done: 1500 * time.Millisecond}},
+		},
+		{
+			name:   "layer done in the last frame",
+			output: "layer-sha256:aaa: done\ndfbench_pull 0 3000000000\n",
+			total:  3 * time.Second,
+			layers: []stats.LayerPull{{Digest: "sha256:aaa", Done: 3 * time.Second}},
+		},
+		{
+			name:   "layer done in several frames",
+			output: "layer-sha256:aaa: done\nelapsed: 0.2 s\nlayer-sha256:aaa: done\nelapsed: 0.4 s\ndfbench_pull 0 1000000000\n",
+			total:  time.Second,
+			layers: []stats.LayerPull{{Digest: "sha256:aaa", Done: 200 * time.Millisecond}},
+		},
+		{
+			name:   "crictl without layers",
+			output: "dfbench_pull 5 10\n",
+			total:  5,
+		},
+		{
+			name:    "timing not found",
+			output:  "ctr: failed to resolve reference\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timing",
+			output:  "dfbench_pull abc 10\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pull := &stats.ImagePull{}
+			err := parsePull([]byte(tt.output), pull)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePull(%q) = %+v, want error", tt.output, pull)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsePull(%q) failed: %v", tt.output, err)
+			}
+
+			if pull.Total != tt.total {
+				t.Errorf("total = %v, want %v", pull.Total, tt.total)
+			}
+
+			var layers []stats.LayerPull
+			for _, layer := range pull.Layers {
+				layers = append(layers, *layer)
+			}
+
+			if !slices.Equal(layers, tt.layers) {
+				t.Errorf("layers = %+v, want %+v", layers, tt.layers)
+			}
+		})
+	}
+}
+
+func TestSplitReference(t *testing.T) {
+	tests := []struct {
+		reference  string
+		repository string
+		tag        string
+	}{
+		{reference: "library/nginx:1.25", repository: "library/nginx", tag: "1.25"},
+		{reference: "library/nginx", repository: "library/nginx", tag: "latest"},
+		{reference: "library/nginx@sha256:abc", repository: "library/nginx", tag: "sha256:abc"},
+		{reference: "nginx:1.25@sha256:abc", repository: "nginx:1.25", tag: "sha256:abc"},
+		{reference: "team.v2/nginx", repository: "team.v2/nginx", tag: "latest"},
+	}
+
+	for _, tt := range tests {
+		repository, tag := splitReference(tt.reference)
+		if repository != tt.repository || tag != tt.tag {
+			t.Errorf("splitReference(%q) = %q, %q, want %q, %q", tt.reference, repository, tag, tt.repository, tt.tag)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":              "''",
+		"plain":         "'plain'",
+		"it's":          `'it'\''s'`,
+		"$(rm -rf /) *": "'$(rm -rf /) *'",
+	}
+
+	for s, expected := range tests {
+		if quoted := shellQuote(s); quoted != expected {
+			t.Errorf("shellQuote(%q) = %s, want %s", s, quoted, expected)
+		}
+	}
+}
+
+func TestCopyImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		mountCode string
+		suffix    string
+		puts      []string
+		wantErr   bool
+	}{
+		{
+			name:      "index by tag",
+			reference: "127.0.0.1:30500/library/nginx:latest",
+			mountCode: "201",
+			suffix:    ":latest",
+			puts:      []string{"application/vnd.oci.image.manifest.v1+json sha256:m1", "application/vnd.oci.image.index.v1+json latest"},
+		},
+		{
+			name:      "manifest by digest",
+			reference: "127.0.0.1:30500/library/nginx@sha256:m1",
+			mountCode: "201",
+			suffix:    "@sha256:m1",
+			puts:      []string{"application/vnd.oci.image.manifest.v1+json sha256:m1"},
+		},
+		{
+			name:      "blob not mounted",
+			reference: "127.0.0.1:30500/library/nginx:latest",
+			mountCode: "202",
+			wantErr:   true,
+		},
+		{
+			name:      "manifest not found",
+			reference: "127.0.0.1:30500/library/redis:latest",
+			mountCode: "201",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor()
+			fake.mountCode = tt.mountCode
+			i := &image{config: &config.ImageConfig{Registry: "127.0.0.1:30500/"}, executor: fake}
+			copied, err := i.copyImage(context.Background(), "local-0", tt.reference)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("copyImage(%q) = %q, want error", tt.reference, copied)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("copyImage(%q) failed: %v", tt.reference, err)
+			}
+
+			match := regexp.MustCompile(`^127\.0\.0\.1:30500/library/nginx-dfbench-([0-9a-f]{8})` + regexp.QuoteMeta(tt.suffix) + `$`).FindStringSubmatch(copied)
+			if match == nil {
+				t.Fatalf("copyImage(%q) = %q, want a new repository of library/nginx", tt.reference, copied)
+			}
+			repository := "library/nginx-dfbench-" + match[1]
+
+			// The blobs of the manifest are mounted from the repository of the image.
+			mounts := fake.matching(regexp.MustCompile(`/v2/(\S+)/blobs/uploads/\?mount=(\S+)&from=library/nginx'`), "$1 $2")
+			if expected := []string{repository + " sha256:l1", repository + " sha256:l2", repository + " sha256:c1"}; !slices.Equal(mounts, expected) {
+				t.Errorf("mounts = %q, want %q", mounts, expected)
+			}
+
+			// The manifests are put byte for byte after the manifests and the blobs they reference.
+			puts := fake.matching(regexp.MustCompile(`-X PUT -H 'Content-Type: (\S+)' --data-binary '.*' 'http://127\.0\.0\.1:30500/v2/`+regexp.QuoteMeta(repository)+`/manifests/(\S+)'$`), "$1 $2")
+			if !slices.Equal(puts, tt.puts) {
+				t.Errorf("puts = %q, want %q", puts, tt.puts)
+			}
+
+			if manifests := fake.matching(regexp.MustCompile(`--data-binary (.*) 'http://.*/manifests/sha256:m1'$`), "$1"); len(manifests) != 1 || manifests[0] != shellQuote(testManifest) {
+				t.Errorf("put manifest = %q, want %q", manifests, shellQuote(testManifest))
+			}
+		})
+	}
+}
+
+func TestRunByImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		cache     string
+		copies    int
+		warm      []bool
+	}{
+		{
+			name:      "cold cache copies the image of the local registry in every iteration",
+			reference: "127.0.0.1:30500/library/nginx:latest",
+			cache:     config.CacheCold,
+			copies:    3,
+			warm:      []bool{false, false, false},
+		},
+		{
+			name:      "hot cache copies the image of the local registry in the first iteration",
+			reference: "127.0.0.1:30500/library/nginx:latest",
+			cache:     config.CacheHot,
+			copies:    1,
+			warm:      []bool{false, true, true},
+		},
+		{
+			name:      "image out of the local registry is warm after the first iteration",
+			reference: "docker.io/library/nginx:latest",
+			cache:     config.CacheCold,
+			warm:      []bool{false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeExecutor()
+			cfg := config.New()
+			cfg.Image.Number = uint32(len(tt.warm))
+			cfg.Image.Registry = "127.0.0.1:30500"
+			cfg.Image.Images = []string{tt.reference}
+			cfg.Image.Cache = tt.cache
+			s := stats.New(cfg, fake)
+			if err := New(&cfg.Image, fake, s, nil).RunByImage(context.Background(), tt.reference); err != nil {
+				t.Fatalf("RunByImage failed: %v", err)
+			}
+
+			pulled := fake.matching(regexp.MustCompile(`images pull --hosts-dir '\S+' '(\S+)'`), "$1")
+			removed := fake.matching(regexp.MustCompile(`images rm --sync '(\S+)'`), "$1")
+			pods := 2
+			if len(pulled) != pods*len(tt.warm) {
+				t.Fatalf("got %d pulls, want %d", len(pulled), pods*len(tt.warm))
+			}
+
+			// The image is removed before the benchmark and the image of every iteration after it.
+			var references []string
+			for n := range tt.warm {
+				references = append(references, pulled[n*pods])
+			}
+
+			if expected := slices.Compact(append([]string{tt.reference}, references...)); !slices.Equal(slices.Compact(removed), expected) {
+				t.Errorf("removed %q, want %q", removed, expected)
+			}
+
+			if copies := len(slices.Compact(slices.Clone(references))); tt.copies > 0 && copies != tt.copies {
+				t.Errorf("pulled %d copies %q, want %d", copies, references, tt.copies)
+			}
+
+			if tt.copies == 0 && slices.ContainsFunc(pulled, func(r string) bool { return r != tt.reference }) {
+				t.Errorf("pulled %q, want %s only", pulled, tt.reference)
+			}
+
+			// The pulls are reported by the image and marked warm by the iteration.
+			var warm []bool
+			pulls := s.GetImagePulls()
+			for _, pull := range pulls {
+				if pull.Image != tt.reference {
+					t.Errorf("pull of %s is reported as %s", tt.reference, pull.Image)
+				}
+
+				warm = append(warm, pull.Warm)
+			}
+
+			var expected []bool
+			for _, w := range tt.warm {
+				for range pods {
+					expected = append(expected, w)
+				}
+			}
+
+			slices.SortFunc(warm, func(a, b bool) int { return strings.Compare(strconv.FormatBool(a), strconv.FormatBool(b)) })
+			slices.SortFunc(expected, func(a, b bool) int { return strings.Compare(strconv.FormatBool(a), strconv.FormatBool(b)) })
+			if !slices.Equal(warm, expected) {
+				t.Errorf("warm = %v, want %v", warm, expected)
+			}
+		})
+	}
+}
+
+func TestManifestURL(t *testing.T) {
+	tests := []struct {
+		reference string
+		url       string
+		wantErr   bool
+	}{
+		{reference: "nginx", url: "https://index.docker.io/v2/library/nginx/manifests/latest"},
+		{reference: "library/nginx:1.25", url: "https://index.docker.io/v2/library/nginx/manifests/1.25"},
+		{reference: "docker.io/nginx:1.25", url: "https://index.docker.io/v2/library/nginx/manifests/1.25"},
+		{reference: "docker.io/team/app@sha256:abc", url: "https://index.docker.io/v2/team/app/manifests/sha256:abc"},
+		{reference: "ghcr.io/dragonflyoss/client:v1", url: "https://ghcr.io/v2/dragonflyoss/client/manifests/v1"},
+		{reference: "registry.example.com:5000/app", url: "https://registry.example.com:5000/v2/app/manifests/latest"},
+		{reference: "127.0.0.1:30500/library/nginx:latest", url: "http://127.0.0.1:30500/v2/library/nginx/manifests/latest"},
+		{reference: "localhost/app:v1", url: "http://localhost/v2/app/manifests/v1"},
+		{reference: "[::1]:5000/app", url: "http://[::1]:5000/v2/app/manifests/latest"},
+		{reference: "nginx@", wantErr: true},
+		{reference: "ghcr.io/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		url, err := manifestURL(tt.reference)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("manifestURL(%q) = %q, want error", tt.reference, url)
+			}
+			continue
+		}
+
+		if err != nil || url != tt.url {
+			t.Errorf("manifestURL(%q) = %q, %v, want %q", tt.reference, url, err, tt.url)
+		}
+	}
+}
+
+// fakeManager fakes the manager to record the URLs of the preheat jobs.
+type fakeManager struct {
+	urls []string
+}
+
+// Preheat records the URL of the preheat job and returns the succeeded job.
+func (f *fakeManager) Preheat(ctx context.Context, pod string, args *manager.PreheatArgs) (*manager.Job, error) {
+	f.urls = append(f.urls, args.URL)
+	return &manager.Job{ID: uint64(len(f.urls)), State: manager.JobStateSuccess}, nil
+}
+
+func TestRunByImagePreheat(t *testing.T) {
+	fake := newFakeExecutor()
+	cfg := config.New()
+	cfg.Image.Number = 2
+	cfg.Image.Registry = "127.0.0.1:30500"
+	cfg.Image.Preheat = true
+	m := &fakeManager{}
+	s := stats.New(cfg, fake)
+	if err := New(&cfg.Image, fake, s, m).RunByImage(context.Background(), "127.0.0.1:30500/library/nginx:latest"); err != nil {
+		t.Fatalf("RunByImage failed: %v", err)
+	}
+
+	// The copy of every iteration is preheated before it is pulled.
+	pulled := fake.matching(regexp.MustCompile(`images pull --hosts-dir '\S+' '127\.0\.0\.1:30500/(\S+):latest'`), "http://127.0.0.1:30500/v2/$1/manifests/latest")
+	if expected := slices.Compact(pulled); len(expected) != 2 || !slices.Equal(m.urls, expected) {
+		t.Errorf("preheated %q, want the copies %q", m.urls, expected)
+	}
+
+	for _, pull := range s.GetImagePulls() {
+		if pull.Preheat <= 0 {
+			t.Errorf("pull of %s in %s has no preheat time", pull.Image, pull.PodName)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PreheatTypeFile preheats the files by the URLs.
+	PreheatTypeFile = "file"
+
+	// PreheatTypeImage preheats the layers of the image by the manifest URL.
+	PreheatTypeImage = "image"
+)
+
+const (
+	// JobStateSuccess is the state of the succeeded job.
+	JobStateSuccess = "SUCCESS"
+
+	// JobStateFailure is the state of the failed job.
+	JobStateFailure = "FAILURE"
+)
+
+// pollInterval is the interval to poll the state of the job.
+const pollInterval = time.Second
+
+// Manager represents a client of the open API of the Dragonfly manager.
+type Manager interface {
+	// Preheat creates a preheat job and waits until it is finished.
+	Preheat(ctx context.Context, pod string, args *PreheatArgs) (*Job, error)
+}
+
+// PreheatArgs represents the arguments of the preheat job.
+type PreheatArgs struct {
+	// Type is the type of the preheat [file, image].
+	Type string `json:"type"`
+
+	// URL is the manifest URL of the image to preheat.
+	URL string `json:"url,omitempty"`
+
+	// URLs is the URLs of the files to preheat.
+	URLs []string `json:"urls,omitempty"`
+}
+
+// createPreheatJobRequest represents the request to create a preheat job.
+type createPreheatJobRequest struct {
+	// Type is the type of the job.
+	Type string `json:"type"`
+
+	// Args is the arguments of the preheat job with the scope.
+	Args struct {
+		*PreheatArgs
+
+		// Scope is the scope of the peers to preheat.
+		Scope string `json:"scope,omitempty"`
+	} `json:"args"`
+}
+
+// Job represents the job of the manager.
+type Job struct {
+	// ID is the id of the job.
+	ID uint64 `json:"id"`
+
+	// State is the state of the job [PENDING, SUCCESS, FAILURE].
+	State string `json:"state"`
+
+	// Result is the result of the job reported by the schedulers.
+	Result map[string]any `json:"result,omitempty"`
+}
+
+// manager implements the Manager interface.
+type manager struct {
+	// url is the base URL of the REST API of the manager.
+	url string
+
+	// token is the personal access token to access the open API.
+	token string
+
+	// scope is the scope of the peers to preheat [single_seed_peer, all_seed_peers, all_peers],
+	// empty to use the default of the manager.
+	scope string
+
+	// executor is the executor to run the requests in the client pods.
+	executor executor.Executor
+}
+
+// New creates a new client of the open API of the Dragonfly manager, the requests are sent by curl
+// in the client pods, so the manager is reachable by the service address in the cluster.
+func New(url, token, scope string, executor executor.Executor) Manager {
+	return &manager{strings.TrimSuffix(url, "/"), token, scope, executor}
+}
+
+// Preheat creates a preheat job and polls the state of the job until it is finished,
+// an error is returned if the job is failed.
+func (m *manager) Preheat(ctx context.Context, pod string, args *PreheatArgs) (*Job, error) {
+	req := &createPreheatJobRequest{Type: "preheat"}
+	req.Args.PreheatArgs = args
+	req.Args.Scope = m.scope
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := m.request(ctx, pod, "POST", "/oapi/v1/jobs", body)
+	if err != nil {
+		logrus.Errorf("failed to create preheat job: %v", err)
+		return nil, err
+	}
+
+	logrus.Debugf("created preheat job %d", job.ID)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		switch job.State {
+		case JobStateSuccess:
+			return job, nil
+		case JobStateFailure:
+			return nil, fmt.Errorf("preheat job %d failed: %v", job.ID, job.Result)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if job, err = m.request(ctx, pod, "GET", fmt.Sprintf("/oapi/v1/jobs/%d", job.ID), nil); err != nil {
+			logrus.Errorf("failed to get preheat job: %v", err)
+			return nil, err
+		}
+	}
+}
+
+// request sends the request to the open API in the pod and decodes the job in the response.
+func (m *manager) request(ctx context.Context, pod string, method string, path string, body []byte) (*Job, error) {
+	cmd := fmt.Sprintf("curl -sS -X %s -H %s -w '\\n%%{http_code}'", method, shellQuote("Authorization: Bearer "+m.token))
+	if body != nil {
+		cmd += fmt.Sprintf(" -H 'Content-Type: application/json' --data %s", shellQuote(string(body)))
+	}
+	cmd += " " + shellQuote(m.url+path)
+
+	output, err := m.executor.Exec(ctx, pod, "sh", "-c", cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, string(output))
+	}
+
+	// The status code is written in the last line by curl.
+	response := strings.TrimSpace(string(output))
+	i := strings.LastIndex(response, "\n")
+	code, err := strconv.Atoi(response[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid response %q", response)
+	}
+
+	if code < 200 || code >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d: %s", code, response[:max(i, 0)])
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal([]byte(response[:max(i, 0)]), job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+
+	return job, nil
+}
+
+// shellQuote quotes the string in single quotes for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,150 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+)
+
+// fakeManager fakes the open API of the manager, the job is pending until it is polled by the number of times.
+type fakeManager struct {
+	mu       sync.Mutex
+	polls    int
+	state    string
+	created  *createPreheatJobRequest
+	requests []string
+}
+
+// ServeHTTP serves the requests to create and get the preheat job.
+func (f *fakeManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer it's $TOKEN" {
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/oapi/v1/jobs":
+		f.created = &createPreheatJobRequest{}
+		if err := json.NewDecoder(r.Body).Decode(f.created); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, `{"id":7,"state":"PENDING"}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/oapi/v1/jobs/7":
+		if f.polls--; f.polls > 0 {
+			fmt.Fprint(w, `{"id":7,"state":"PENDING"}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"id":7,"state":%q,"result":{"reason":"done"}}`, f.state)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPreheat(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		state    string
+		polls    int
+		requests int
+		wantErr  string
+	}{
+		{
+			name:     "succeeded after polling",
+			token:    "it's $TOKEN",
+			state:    JobStateSuccess,
+			polls:    2,
+			requests: 3,
+		},
+		{
+			name:     "failed",
+			token:    "it's $TOKEN",
+			state:    JobStateFailure,
+			polls:    1,
+			requests: 2,
+			wantErr:  "preheat job 7 failed",
+		},
+		{
+			name:     "unauthorized",
+			token:    "it's",
+			requests: 1,
+			wantErr:  "unexpected status code 401",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeManager{polls: tt.polls, state: tt.state}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			m := New(server.URL+"/", tt.token, "all_seed_peers", executor.NewLocal())
+			job, err := m.Preheat(context.Background(), "local-0", &PreheatArgs{Type: PreheatTypeImage, URL: "http://127.0.0.1:30500/v2/library/nginx/manifests/latest"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Preheat = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Preheat failed: %v", err)
+			} else if job.ID != 7 || job.State != JobStateSuccess {
+				t.Errorf("Preheat = %+v, want job 7 succeeded", job)
+			}
+
+			if len(fake.requests) != tt.requests {
+				t.Errorf("got requests %q, want %d", fake.requests, tt.requests)
+			}
+
+			if tt.state == "" {
+				return
+			}
+
+			if fake.created.Type != "preheat" || fake.created.Args.Scope != "all_seed_peers" || fake.created.Args.PreheatArgs.Type != PreheatTypeImage || fake.created.Args.URL == "" {
+				t.Errorf("created job %+v with args %+v, want an image preheat of all seed peers", fake.created, fake.created.Args.PreheatArgs)
+			}
+		})
+	}
+}
+
+func TestPreheatCanceled(t *testing.T) {
+	fake := &fakeManager{polls: 100, state: JobStateSuccess}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := New(server.URL, "it's $TOKEN", "", executor.NewLocal()).Preheat(ctx, "local-0", &PreheatArgs{Type: PreheatTypeFile, URLs: []string{"http://file-server/nano"}}); err != context.DeadlineExceeded {
+		t.Errorf("Preheat = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
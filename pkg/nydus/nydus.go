@@ -15,3 +15,306 @@
  */
 
 package nydus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// SandboxNamespace is the namespace of the pod sandboxes started by the benchmark on the nodes,
+	// all of them are removed by Cleanup.
+	SandboxNamespace = "dfbench"
+
+	// apiSocketDir is the directory of the API sockets of the nydusd instances on the nodes.
+	apiSocketDir = "/run/containerd-nydus"
+
+	// startupPrefix is the prefix of the timing line written by the startup script.
+	startupPrefix = "dfbench_startup"
+
+	// startupScript pulls the image and starts a container from it by the CRI, the pod sandbox
+	// uses the host network to exclude the CNI setup, the timestamps are in nanoseconds.
+	startupScript = `set -e
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+cat > "$dir/pod.json" <<EOF
+{"metadata": {"name": "%[1]s", "namespace": "%[2]s", "uid": "%[1]s", "attempt": 1}, "linux": {"security_context": {"namespace_options": {"network": 2}}}}
+EOF
+cat > "$dir/container.json" <<EOF
+{"metadata": {"name": "%[1]s"}, "image": {"image": "%[3]s"}, "linux": {}}
+EOF
+start=$(date +%%s%%N)
+crictl pull '%[3]s' > /dev/null
+pulled=$(date +%%s%%N)
+sandbox=$(crictl runp "$dir/pod.json")
+container=$(crictl create "$sandbox" "$dir/container.json" "$dir/pod.json")
+crictl start "$container" > /dev/null
+ready=$(date +%%s%%N)
+echo "%[4]s $start $pulled $ready"
+`
+
+	// fetchedScript prints the backend metrics of all nydusd instances, one JSON object per line.
+	fetchedScript = `for socket in $(find %s -name api.sock 2>/dev/null); do curl -s --unix-socket "$socket" http://localhost/api/v1/metrics/backend; echo; done`
+
+	// removeSandboxesScript stops and removes the pod sandboxes of the benchmark.
+	removeSandboxesScript = `for sandbox in $(crictl pods --namespace %s -q); do crictl stopp "$sandbox" > /dev/null; crictl rmp "$sandbox" > /dev/null; done`
+)
+
+// Nydus represents a benchmark runner for Nydus.
+type Nydus interface {
+	// Run runs the benchmarks of all images.
+	Run(context.Context) error
+
+	// RunByImage runs the benchmark of the image.
+	RunByImage(context.Context, string) error
+
+	// Cleanup cleans up the containers and the images.
+	Cleanup(context.Context) error
+}
+
+// nydus implements the Nydus interface.
+type nydus struct {
+	// config is the configuration of the benchmark.
+	config *config.NydusConfig
+
+	// executor is the executor to run commands in the nydus-snapshotter pods.
+	executor executor.Executor
+
+	// stats is the statistics of the benchmark.
+	stats stats.Stats
+}
+
+// New creates a new benchmark runner for Nydus, the commands are executed on the nodes by entering
+// the host namespaces from the privileged nydus-snapshotter pods, so crictl and curl must be
+// installed on the nodes.
+func New(config *config.NydusConfig, executor executor.Executor, stats stats.Stats) Nydus {
+	return &nydus{config, executor, stats}
+}
+
+// Run runs the benchmarks of all images.
+func (n *nydus) Run(ctx context.Context) error {
+	for _, image := range n.config.Images {
+		if err := n.RunByImage(ctx, image); err != nil {
+			logrus.Errorf("failed to run nydus benchmark of %s: %v", image, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunByImage runs the benchmark of the image, every iteration starts a container from the
+// image on all nodes at the same time and removes the container and the image afterwards.
+func (n *nydus) RunByImage(ctx context.Context, image string) error {
+	pods, err := n.getSnapshotterPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(1); i <= n.config.Number; i++ {
+		logrus.Debugf("starting container from %s %d/%d", image, i, n.config.Number)
+		var eg errgroup.Group
+		for _, pod := range pods {
+			eg.Go(func(pod string) func() error {
+				return func() error {
+					startup, err := n.startContainer(ctx, pod, image)
+					if err != nil {
+						return err
+					}
+
+					n.stats.AddStartup(startup)
+					return nil
+				}
+			}(pod))
+		}
+
+		if err := eg.Wait(); err != nil {
+			logrus.Errorf("error processing pods: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startContainer pulls the image and starts a container from it on the node of the pod,
+// and returns the startup timing and the data fetched on demand.
+func (n *nydus) startContainer(ctx context.Context, pod string, image string) (startup *stats.Startup, err error) {
+	// Remove the image to start from a cold cache, it is not found in the first iteration.
+	if output, err := n.hostExec(ctx, pod, fmt.Sprintf("crictl rmi '%s'", image)); err != nil {
+		logrus.Debugf("failed to remove image %s: %v \nmessage: %s", image, err, string(output))
+	}
+
+	before, err := n.getFetched(ctx, pod)
+	if err != nil {
+		logrus.Errorf("failed to get fetched data: %v", err)
+		return nil, err
+	}
+
+	// The pod sandbox is removed even if the startup fails halfway, so it neither keeps running
+	// on the node nor skews the fetched data of the next image.
+	defer func() {
+		if removeErr := n.removeContainers(ctx, pod); removeErr != nil && err == nil {
+			startup, err = nil, removeErr
+		}
+	}()
+
+	name := fmt.Sprintf("dfbench-%s", uuid.New().String())
+	output, err := n.hostExec(ctx, pod, fmt.Sprintf(startupScript, name, SandboxNamespace, image, startupPrefix))
+	if err != nil {
+		logrus.Errorf("failed to start container: %v \nmessage: %s", err, string(output))
+		return nil, err
+	}
+
+	logrus.Debugf("startup output: %s", string(output))
+	startup = &stats.Startup{PodName: pod, Image: image}
+	if err := parseStartup(output, startup); err != nil {
+		logrus.Errorf("failed to parse startup timing: %v", err)
+		return nil, err
+	}
+
+	after, err := n.getFetched(ctx, pod)
+	if err != nil {
+		logrus.Errorf("failed to get fetched data: %v", err)
+		return nil, err
+	}
+
+	// The nydusd instances of the removed images exit, so the fetched data may decrease.
+	if after > before {
+		startup.Fetched = after - before
+	}
+
+	return startup, nil
+}
+
+// parseStartup parses the timestamps written by the startup script into the startup.
+func parseStartup(output []byte, startup *stats.Startup) error {
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != startupPrefix {
+			continue
+		}
+
+		var timestamps [3]int64
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			timestamps[i] = v
+		}
+
+		startup.Pull = time.Duration(timestamps[1] - timestamps[0])
+		startup.Ready = time.Duration(timestamps[2] - timestamps[0])
+		return nil
+	}
+
+	return errors.New("startup timing not found")
+}
+
+// getFetched returns the total data fetched from the backend by all nydusd instances on the node of the pod.
+func (n *nydus) getFetched(ctx context.Context, pod string) (uint64, error) {
+	output, err := n.hostExec(ctx, pod, fmt.Sprintf(fetchedScript, apiSocketDir))
+	if err != nil {
+		logrus.Errorf("failed to get backend metrics: %v \nmessage: %s", err, string(output))
+		return 0, err
+	}
+
+	var fetched uint64
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var metrics struct {
+			ReadAmountTotal uint64 `json:"read_amount_total"`
+		}
+		if err := json.Unmarshal([]byte(line), &metrics); err != nil {
+			logrus.Debugf("skip invalid backend metrics %q: %v", line, err)
+			continue
+		}
+
+		fetched += metrics.ReadAmountTotal
+	}
+
+	return fetched, nil
+}
+
+// removeContainers removes the pod sandboxes of the benchmark and their containers on the node of the pod.
+func (n *nydus) removeContainers(ctx context.Context, pod string) error {
+	output, err := n.hostExec(ctx, pod, fmt.Sprintf(removeSandboxesScript, SandboxNamespace))
+	if err != nil {
+		logrus.Errorf("failed to remove containers: %v \nmessage: %s", err, string(output))
+		return err
+	}
+
+	return nil
+}
+
+// Cleanup cleans up the containers and the images on all nodes.
+func (n *nydus) Cleanup(ctx context.Context) error {
+	pods, err := n.getSnapshotterPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	var eg errgroup.Group
+	for _, pod := range pods {
+		eg.Go(func(pod string) func() error {
+			return func() error {
+				if err := n.removeContainers(ctx, pod); err != nil {
+					return err
+				}
+
+				for _, image := range n.config.Images {
+					if output, err := n.hostExec(ctx, pod, fmt.Sprintf("crictl rmi '%s'", image)); err != nil {
+						logrus.Debugf("failed to remove image %s: %v \nmessage: %s", image, err, string(output))
+					}
+				}
+
+				return nil
+			}
+		}(pod))
+	}
+
+	if err := eg.Wait(); err != nil {
+		logrus.Errorf("error processing pods: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// hostExec executes the shell script on the node of the pod by entering the host namespaces.
+func (n *nydus) hostExec(ctx context.Context, pod string, script string) ([]byte, error) {
+	return n.executor.Exec(ctx, pod, "nsenter", "-t", "1", "-m", "-u", "-i", "-n", "-p", "--", "sh", "-c", script)
+}
+
+// getSnapshotterPods returns the nydus-snapshotter pods.
+func (n *nydus) getSnapshotterPods(ctx context.Context) ([]string, error) {
+	pods, err := n.executor.GetPods(ctx, n.config.LabelSelector)
+	if err != nil {
+		logrus.Errorf("failed to get pods: %v", err)
+		return nil, err
+	}
+
+	if len(pods) == 0 {
+		logrus.Errorf("no nydus-snapshotter pod found")
+		return nil, errors.New("no nydus-snapshotter pod found")
+	}
+
+	return pods, nil
+}
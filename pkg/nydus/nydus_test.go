@@ -0,0 +1,234 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nydus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+)
+
+// fakeExecutor fakes the node of the snapshotter pod, it records the scripts and replies the startup
+// script by startup and the metrics script by the next of fetched.
+type fakeExecutor struct {
+	executor.Executor
+
+	mu       sync.Mutex
+	startup  string
+	fetched  []string
+	scripts  []string
+	startErr error
+}
+
+// Exec records the script executed on the node and returns the output of the script.
+func (f *fakeExecutor) Exec(ctx context.Context, pod string, cmd ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	script := cmd[len(cmd)-1]
+	f.scripts = append(f.scripts, script)
+	switch {
+	case strings.Contains(script, "crictl runp"):
+		return []byte(f.startup), f.startErr
+	case strings.Contains(script, "metrics/backend"):
+		if len(f.fetched) == 0 {
+			return nil, errors.New("nydusd is not running")
+		}
+
+		output := f.fetched[0]
+		f.fetched = f.fetched[1:]
+		return []byte(output), nil
+	default:
+		return nil, nil
+	}
+}
+
+// removed returns true if the pod sandboxes of the benchmark are removed.
+func (f *fakeExecutor) removed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.scripts) > 0 && strings.Contains(f.scripts[len(f.scripts)-1], "crictl rmp")
+}
+
+func TestParseStartup(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		pull    time.Duration
+		ready   time.Duration
+		wantErr bool
+	}{
+		{
+			name:   "timing after the crictl output",
+			output: "Image is up to date\ndfbench_startup 1000000000 1250000000 1750000000\n",
+			pull:   250 * time.Millisecond,
+			ready:  750 * time.Millisecond,
+		},
+		{
+			name:    "timing not found",
+			output:  "FATA[0000] pulling image: not found\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			output:  "dfbench_startup 1000 %N 3000\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startup := &stats.Startup{}
+			err := parseStartup([]byte(tt.output), startup)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseStartup(%q) = %+v, want error", tt.output, startup)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseStartup(%q) failed: %v", tt.output, err)
+			}
+
+			if startup.Pull != tt.pull || startup.Ready != tt.ready {
+				t.Errorf("parseStartup(%q) = pull %v and ready %v, want %v and %v", tt.output, startup.Pull, startup.Ready, tt.pull, tt.ready)
+			}
+		})
+	}
+}
+
+func TestGetFetched(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected uint64
+	}{
+		{
+			name:     "no nydusd",
+			output:   "",
+			expected: 0,
+		},
+		{
+			name:     "sum of the nydusd instances",
+			output:   "{\"read_amount_total\": 1024, \"read_count\": 2}\n\n{\"read_amount_total\": 4096}\n",
+			expected: 5120,
+		},
+		{
+			name:     "invalid metrics are skipped",
+			output:   "{\"read_amount_total\": 1024}\ncurl: (7) Couldn't connect to server\n{\"read_amount_total\": 1}\n",
+			expected: 1025,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &nydus{executor: &fakeExecutor{fetched: []string{tt.output}}}
+			fetched, err := n.getFetched(context.Background(), "nydus-snapshotter-0")
+			if err != nil {
+				t.Fatalf("getFetched failed: %v", err)
+			}
+
+			if fetched != tt.expected {
+				t.Errorf("getFetched = %d, want %d", fetched, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStartContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor *fakeExecutor
+		fetched  uint64
+		wantErr  bool
+	}{
+		{
+			name: "fetched data of the startup",
+			executor: &fakeExecutor{
+				startup: "dfbench_startup 0 100 200\n",
+				fetched: []string{`{"read_amount_total": 1024}`, `{"read_amount_total": 3072}`},
+			},
+			fetched: 2048,
+		},
+		{
+			name: "decreased fetched data of the exited nydusd",
+			executor: &fakeExecutor{
+				startup: "dfbench_startup 0 100 200\n",
+				fetched: []string{`{"read_amount_total": 3072}`, `{"read_amount_total": 1024}`},
+			},
+		},
+		{
+			name: "startup timing not found",
+			executor: &fakeExecutor{
+				startup: "sandbox started\n",
+				fetched: []string{`{"read_amount_total": 0}`},
+			},
+			wantErr: true,
+		},
+		{
+			name: "fetched data not found after the startup",
+			executor: &fakeExecutor{
+				startup: "dfbench_startup 0 100 200\n",
+				fetched: []string{`{"read_amount_total": 0}`},
+			},
+			wantErr: true,
+		},
+		{
+			name: "startup failed halfway",
+			executor: &fakeExecutor{
+				startup:  "FATA[0000] creating container: failed\n",
+				startErr: errors.New("exit status 1"),
+				fetched:  []string{`{"read_amount_total": 0}`},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &nydus{config: &config.NydusConfig{}, executor: tt.executor}
+			startup, err := n.startContainer(context.Background(), "nydus-snapshotter-0", "registry/image:nydus")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("startContainer = %+v, want error", startup)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("startContainer failed: %v", err)
+				}
+
+				if startup.Pull != 100 || startup.Ready != 200 || startup.Fetched != tt.fetched {
+					t.Errorf("startContainer = %+v, want pull 100ns, ready 200ns and fetched %d", startup, tt.fetched)
+				}
+			}
+
+			// The pod sandbox is removed after the startup whether it succeeds or not.
+			if !tt.executor.removed() {
+				t.Errorf("pod sandbox is not removed, scripts are %q", tt.executor.scripts)
+			}
+		})
+	}
+}
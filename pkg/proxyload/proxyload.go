@@ -0,0 +1,325 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+)
+
+// ProxyLoad represents an open-loop load generator sending the requests through the dfdaemon proxy.
+type ProxyLoad interface {
+	// Run sends the requests for the configured duration and adds the load to the statistics.
+	Run(context.Context) error
+}
+
+// proxyLoad implements the ProxyLoad interface.
+type proxyLoad struct {
+	// config is the configuration of the load.
+	config *config.ProxyLoadConfig
+
+	// targetURL is the URL of the file to request.
+	targetURL string
+
+	// stats is the statistics of the benchmark.
+	stats stats.Stats
+
+	// client is the HTTP client sending the requests through the proxy.
+	client *http.Client
+}
+
+// New creates a new load generator sending the requests for the target URL through the dfdaemon proxy.
+func New(config *config.ProxyLoadConfig, targetURL string, stats stats.Stats) (ProxyLoad, error) {
+	proxyURL, err := url.Parse(config.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", config.Proxy, err)
+	}
+
+	client := &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyURL(proxyURL),
+			MaxIdleConns:        int(config.MaxWorkers),
+			MaxIdleConnsPerHost: int(config.MaxWorkers),
+			DisableCompression:  true,
+		},
+	}
+
+	return &proxyLoad{config, targetURL, stats, client}, nil
+}
+
+// Run sends the requests for the configured duration and adds the load to the statistics. The requests
+// are sent at the configured rate regardless of the responses, by at most the max workers in flight, the
+// requests scheduled when all workers are busy are dropped and counted, or the requests are sent as fast
+// as possible by the max workers if the rate is 0. The dfdaemon metrics are collected if they are reachable.
+func (p *proxyLoad) Run(ctx context.Context) error {
+	targeter, err := newTargeter(p.config, p.targetURL)
+	if err != nil {
+		logrus.Errorf("failed to create targeter: %v", err)
+		return err
+	}
+
+	if err := p.stats.ResetClientMetrics(ctx); err != nil {
+		logrus.Warnf("failed to reset dfdaemon metrics, the traffic is not reported: %v", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		requests []*stats.Request
+		wg       sync.WaitGroup
+	)
+	record := func(request *stats.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request)
+	}
+
+	start := time.Now()
+	deadline := start.Add(p.config.Duration)
+	sent := deadline
+	var dropped uint64
+	if p.config.Rate == 0 {
+		for i := uint32(0); i < p.config.MaxWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) && ctx.Err() == nil {
+					record(p.send(ctx, targeter.next()))
+				}
+			}()
+		}
+	} else {
+		// The request i is scheduled at start + i / rate, a request is dropped instead of waiting for
+		// a free worker if all workers are busy, so the schedule does not depend on the responses.
+		workers := semaphore.NewWeighted(int64(p.config.MaxWorkers))
+		for i := 0; ; i++ {
+			scheduled := start.Add(time.Duration(float64(i) * float64(time.Second) / float64(p.config.Rate)))
+			if !scheduled.Before(deadline) {
+				break
+			}
+
+			timer := time.NewTimer(time.Until(scheduled))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			if !workers.TryAcquire(1) {
+				dropped++
+				continue
+			}
+
+			wg.Add(1)
+			go func(target *target) {
+				defer wg.Done()
+				defer workers.Release(1)
+				record(p.send(ctx, target))
+			}(targeter.next())
+		}
+
+		sent = time.Now()
+	}
+
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		logrus.Errorf("failed to send requests: %v", err)
+		return err
+	}
+
+	p.stats.AddLoad(&stats.Load{
+		Mode:     p.config.Mode,
+		Rate:     p.config.Rate,
+		Duration: sent.Sub(start),
+		Wait:     time.Since(sent),
+		Dropped:  dropped,
+		Requests: requests,
+	})
+
+	if err := p.stats.CollectLoadMetrics(ctx, p.config.Mode); err != nil {
+		logrus.Warnf("failed to collect dfdaemon metrics, the traffic is not reported: %v", err)
+	}
+
+	return nil
+}
+
+// send sends the request of the target through the proxy and reads the response body.
+func (p *proxyLoad) send(ctx context.Context, target *target) *stats.Request {
+	request := &stats.Request{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+	if err != nil {
+		request.Error = err.Error()
+		return request
+	}
+
+	if target.byteRange != "" {
+		req.Header.Set("Range", "bytes="+target.byteRange)
+	}
+
+	start := time.Now()
+	defer func() {
+		request.Latency = time.Since(start)
+	}()
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		request.Error = requestError(err)
+		return request
+	}
+	defer resp.Body.Close()
+
+	request.Code = resp.StatusCode
+	n, err := io.Copy(io.Discard, resp.Body)
+	request.BytesIn = uint64(n)
+	if err != nil {
+		request.Error = requestError(err)
+	}
+
+	return request
+}
+
+// requestError returns the error of the request without the URL, so the errors of the requests of the
+// different tasks are counted together.
+func requestError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+
+	return err.Error()
+}
+
+// target represents a request to send.
+type target struct {
+	// url is the URL of the request.
+	url string
+
+	// byteRange is the byte range of the request in the format of <start>-[end], empty for the whole file.
+	byteRange string
+}
+
+// stream represents a pass reading a task of the pool from head to tail in the sequential mode.
+type stream struct {
+	// task is the index of the task in the pool.
+	task int
+
+	// offset is the offset of the next chunk.
+	offset uint64
+}
+
+// targeter generates the targets of the mode, it is safe for concurrent use.
+type targeter struct {
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// config is the configuration of the load.
+	config *config.ProxyLoadConfig
+
+	// targetURL is the URL of the file to request.
+	targetURL *url.URL
+
+	// runID is the unique ID of the run in the query strings of the tasks.
+	runID string
+
+	// count is the number of the generated targets.
+	count uint64
+
+	// pool is the URLs of the tasks read repeatedly in the sequential mode.
+	pool []string
+
+	// streams is the passes read in turn in the sequential mode.
+	streams []*stream
+}
+
+// newTargeter creates a targeter of the mode for the target URL.
+func newTargeter(cfg *config.ProxyLoadConfig, targetURL string) (*targeter, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target url %q: %w", targetURL, err)
+	}
+
+	// The query strings must be unique across the runs on the different hosts.
+	t := &targeter{config: cfg, targetURL: u, runID: uuid.New().String()}
+	if cfg.Mode != config.LoadModeSequential {
+		return t, nil
+	}
+
+	// All streams start together at offset 0, the stream s starts on the task s % URLCount.
+	for i := uint32(0); i < cfg.URLCount; i++ {
+		t.pool = append(t.pool, t.uniqueURL(uint64(i)))
+	}
+
+	for s := uint32(0); s < cfg.Streams; s++ {
+		t.streams = append(t.streams, &stream{task: int(s % cfg.URLCount)})
+	}
+
+	return t, nil
+}
+
+// next returns the next target of the mode.
+func (t *targeter) next() *target {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.count
+	t.count++
+
+	switch t.config.Mode {
+	case config.LoadModeRandom:
+		return &target{url: t.uniqueURL(i), byteRange: strings.TrimPrefix(t.config.Range, "bytes=")}
+	case config.LoadModeSequential:
+		// The streams take turns by one chunk, a stream moves to the next task of the pool after a complete pass.
+		s := t.streams[i%uint64(len(t.streams))]
+		if s.offset >= t.config.FileSize {
+			s.task = (s.task + 1) % len(t.pool)
+			s.offset = 0
+		}
+
+		end := min(s.offset+t.config.ChunkSize, t.config.FileSize) - 1
+		target := &target{url: t.pool[s.task], byteRange: fmt.Sprintf("%d-%d", s.offset, end)}
+		s.offset += t.config.ChunkSize
+		return target
+	default:
+		return &target{url: t.targetURL.String(), byteRange: strings.TrimPrefix(t.config.Range, "bytes=")}
+	}
+}
+
+// uniqueURL returns the target URL with the unique query string of the index, so it is a new task.
+func (t *targeter) uniqueURL(i uint64) string {
+	u := *t.targetURL
+	query := u.Query()
+	query.Set("r", fmt.Sprintf("%s-%d", t.runID, i))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
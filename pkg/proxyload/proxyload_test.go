@@ -0,0 +1,227 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyload
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
+	"github.com/dragonflyoss/perf-tests/pkg/stats"
+)
+
+func TestTargeterNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     config.ProxyLoadConfig
+		count      int
+		tasks      []int
+		ranges     []string
+		uniqueURLs int
+	}{
+		{
+			name:       "repeat the same task",
+			config:     config.ProxyLoadConfig{Mode: config.LoadModeRepeat, Range: "bytes=0-1023"},
+			count:      3,
+			tasks:      []int{-1, -1, -1},
+			ranges:     []string{"0-1023", "0-1023", "0-1023"},
+			uniqueURLs: 1,
+		},
+		{
+			name:       "random new tasks",
+			config:     config.ProxyLoadConfig{Mode: config.LoadModeRandom},
+			count:      3,
+			tasks:      []int{-1, -1, -1},
+			ranges:     []string{"", "", ""},
+			uniqueURLs: 3,
+		},
+		{
+			name:   "sequential streams in turn",
+			config: config.ProxyLoadConfig{Mode: config.LoadModeSequential, FileSize: 2048, ChunkSize: 1024, Streams: 2, URLCount: 3},
+			count:  8,
+			// The streams start on the tasks 0 and 1, and move to the next tasks after a complete pass.
+			tasks:      []int{0, 1, 0, 1, 1, 2, 1, 2},
+			ranges:     []string{"0-1023", "0-1023", "1024-2047", "1024-2047", "0-1023", "0-1023", "1024-2047", "1024-2047"},
+			uniqueURLs: 3,
+		},
+		{
+			name:   "sequential last chunk ends at the file size",
+			config: config.ProxyLoadConfig{Mode: config.LoadModeSequential, FileSize: 2500, ChunkSize: 1024, Streams: 1, URLCount: 1},
+			count:  4,
+			// A single task is read again from the head after a complete pass.
+			tasks:      []int{0, 0, 0, 0},
+			ranges:     []string{"0-1023", "1024-2047", "2048-2499", "0-1023"},
+			uniqueURLs: 1,
+		},
+		{
+			name:       "sequential streams more than the tasks",
+			config:     config.ProxyLoadConfig{Mode: config.LoadModeSequential, FileSize: 1024, ChunkSize: 1024, Streams: 3, URLCount: 2},
+			count:      6,
+			tasks:      []int{0, 1, 0, 1, 0, 1},
+			ranges:     []string{"0-1023", "0-1023", "0-1023", "0-1023", "0-1023", "0-1023"},
+			uniqueURLs: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targeter, err := newTargeter(&tt.config, "http://file-server/small?tag=a")
+			if err != nil {
+				t.Fatalf("newTargeter failed: %v", err)
+			}
+
+			var (
+				urls   []string
+				tasks  []int
+				ranges []string
+			)
+			for range tt.count {
+				target := targeter.next()
+				urls = append(urls, target.url)
+				tasks = append(tasks, slices.Index(targeter.pool, target.url))
+				ranges = append(ranges, target.byteRange)
+
+				u, err := url.Parse(target.url)
+				if err != nil || u.Path != "/small" || u.Query().Get("tag") != "a" {
+					t.Errorf("target url %q is not the target file with the query string", target.url)
+				}
+			}
+
+			if !slices.Equal(tasks, tt.tasks) {
+				t.Errorf("tasks = %v, want %v", tasks, tt.tasks)
+			}
+
+			if !slices.Equal(ranges, tt.ranges) {
+				t.Errorf("ranges = %q, want %q", ranges, tt.ranges)
+			}
+
+			slices.Sort(urls)
+			if unique := len(slices.Compact(urls)); unique != tt.uniqueURLs {
+				t.Errorf("got %d unique urls, want %d", unique, tt.uniqueURLs)
+			}
+		})
+	}
+}
+
+func TestTargeterUniqueAcrossRuns(t *testing.T) {
+	cfg := &config.ProxyLoadConfig{Mode: config.LoadModeRandom}
+	a, _ := newTargeter(cfg, "http://file-server/small")
+	b, _ := newTargeter(cfg, "http://file-server/small")
+	if a.next().url == b.next().url {
+		t.Error("the tasks of the different runs are the same")
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    uint32
+		workers uint32
+		delay   time.Duration
+		dropped bool
+	}{
+		{
+			name:    "open-loop under the capacity",
+			rate:    100,
+			workers: 10,
+		},
+		{
+			name:    "open-loop over the capacity drops the requests",
+			rate:    100,
+			workers: 2,
+			delay:   200 * time.Millisecond,
+			dropped: true,
+		},
+		{
+			name:    "as fast as possible",
+			rate:    0,
+			workers: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proxied atomic.Int64
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The metrics of the dfdaemon are empty.
+				if !r.URL.IsAbs() {
+					return
+				}
+
+				proxied.Add(1)
+				time.Sleep(tt.delay)
+				if r.Header.Get("Range") != "bytes=0-3" {
+					http.Error(w, "invalid range", http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte("data"))
+			}))
+			defer proxy.Close()
+
+			cfg := config.New()
+			cfg.ProxyLoad.Proxy = proxy.URL
+			cfg.ProxyLoad.Rate = tt.rate
+			cfg.ProxyLoad.Duration = 300 * time.Millisecond
+			cfg.ProxyLoad.MaxWorkers = tt.workers
+			cfg.ProxyLoad.Range = "0-3"
+			s := stats.New(cfg, executor.NewLocal(executor.Endpoint{MetricsAddr: strings.TrimPrefix(proxy.URL, "http://")}))
+			p, err := New(&cfg.ProxyLoad, "http://file-server/small", s)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			loads := s.GetLoads()
+			if len(loads) != 1 {
+				t.Fatalf("got %d loads, want 1", len(loads))
+			}
+
+			load := loads[0]
+			if int64(len(load.Requests)) != proxied.Load() || len(load.Requests) == 0 {
+				t.Fatalf("got %d requests, want the %d proxied requests", len(load.Requests), proxied.Load())
+			}
+
+			for _, request := range load.Requests {
+				if request.Code != http.StatusPartialContent || request.BytesIn != 4 || request.Error != "" {
+					t.Fatalf("request = %+v, want 206 with 4 bytes", request)
+				}
+			}
+
+			// The 30 requests scheduled in 300ms are sent by the workers, or dropped if the workers are busy.
+			if tt.rate > 0 && load.Dropped+uint64(len(load.Requests)) != 30 {
+				t.Errorf("got %d requests and %d dropped, want 30 scheduled", len(load.Requests), load.Dropped)
+			}
+
+			if (load.Dropped > 0) != tt.dropped {
+				t.Errorf("got %d dropped, want dropped %t", load.Dropped, tt.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/sirupsen/logrus"
+)
+
+// significanceZ is the z-score of the two-sided 95% confidence level.
+const significanceZ = 1.96
+
+// Comparison represents the comparison of a metric between the baseline and the current report.
+type Comparison struct {
+	// Downloader is the downloader used to download the file.
+	Downloader string
+
+	// FileSizeLevel is the file size level of the file.
+	FileSizeLevel backend.FileSizeLevel
+
+	// Metric is the name of the compared metric.
+	Metric string
+
+	// Baseline is the formatted value of the baseline.
+	Baseline string
+
+	// Current is the formatted value of the current.
+	Current string
+
+	// Delta is the change of the metric, in percent for the costs and in percentage points for the rates.
+	Delta float64
+
+	// Significant is true if the change of the average cost is statistically significant.
+	Significant bool
+
+	// Missing is true if the result of the baseline is missing in the current report.
+	Missing bool
+
+	// Regressed is true if the change exceeds the threshold, the change of the average cost must be
+	// statistically significant as well if both reports have enough downloads to test, and a missing
+	// result is always regressed.
+	Regressed bool
+}
+
+// ReadReport reads the report in JSON format from the file.
+func ReadReport(path string) (*Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report := &Report{}
+	if err := json.NewDecoder(file).Decode(report); err != nil {
+		return nil, fmt.Errorf("failed to decode report %s: %w", path, err)
+	}
+
+	return report, nil
+}
+
+// Compare compares the results of the current report with the baseline report by downloader and file size level,
+// the results of the baseline missing in the current report are compared as regressed.
+func Compare(baseline, current *Report, thresholds *config.CompareConfig) []*Comparison {
+	baselineResults := make(map[string]*Result, len(baseline.Results))
+	for _, result := range baseline.Results {
+		baselineResults[resultKey(result)] = result
+	}
+
+	currentResults := make(map[string]*Result, len(current.Results))
+	for _, result := range current.Results {
+		currentResults[resultKey(result)] = result
+	}
+
+	var comparisons []*Comparison
+	for _, cur := range current.Results {
+		base, ok := baselineResults[resultKey(cur)]
+		if !ok {
+			logrus.Warnf("no baseline found for %s by %s", cur.FileSizeLevel, cur.Downloader)
+			continue
+		}
+
+		costs := []struct {
+			metric        string
+			base, current time.Duration
+		}{
+			{"avg_cost", base.AvgCost, cur.AvgCost},
+			{"p50_cost", base.P50Cost, cur.P50Cost},
+			{"p90_cost", base.P90Cost, cur.P90Cost},
+			{"p99_cost", base.P99Cost, cur.P99Cost},
+		}
+		// Only the average cost is tested for significance, so the percentiles are gated by the threshold alone,
+		// and so is the average cost if the downloads are too few to test.
+		testable := significanceTestable(base, cur)
+		if !testable {
+			logrus.Warnf("too few downloads of %s by %s to test the significance, the average cost is gated by the threshold alone", cur.FileSizeLevel, cur.Downloader)
+		}
+
+		for _, cost := range costs {
+			delta := relativeDelta(float64(cost.base), float64(cost.current))
+			gated := cost.metric == "avg_cost" && testable
+			significantChange := gated && significant(base, cur)
+			comparisons = append(comparisons, &Comparison{
+				Downloader:    cur.Downloader,
+				FileSizeLevel: cur.FileSizeLevel,
+				Metric:        cost.metric,
+				Baseline:      formatDuration(cost.base),
+				Current:       formatDuration(cost.current),
+				Delta:         delta,
+				Significant:   significantChange,
+				Regressed:     delta > thresholds.LatencyThreshold && (!gated || significantChange),
+			})
+		}
+
+		delta := cur.BackToSourceRate - base.BackToSourceRate
+		comparisons = append(comparisons, &Comparison{
+			Downloader:    cur.Downloader,
+			FileSizeLevel: cur.FileSizeLevel,
+			Metric:        "back_to_source_rate",
+			Baseline:      fmt.Sprintf("%.2f%%", base.BackToSourceRate),
+			Current:       fmt.Sprintf("%.2f%%", cur.BackToSourceRate),
+			Delta:         delta,
+			Regressed:     delta > thresholds.BackToSourceRateThreshold,
+		})
+	}
+
+	for _, base := range baseline.Results {
+		if _, ok := currentResults[resultKey(base)]; ok {
+			continue
+		}
+
+		logrus.Warnf("no result found for %s by %s in the current report", base.FileSizeLevel, base.Downloader)
+		comparisons = append(comparisons, &Comparison{
+			Downloader:    base.Downloader,
+			FileSizeLevel: base.FileSizeLevel,
+			Metric:        "count",
+			Baseline:      strconv.FormatUint(base.Count, 10),
+			Current:       "missing",
+			Missing:       true,
+			Regressed:     true,
+		})
+	}
+
+	return comparisons
+}
+
+// resultKey returns the key of the result to match the results of the reports.
+func resultKey(result *Result) string {
+	return result.Downloader + "/" + string(result.FileSizeLevel)
+}
+
+// Regressed returns true if any comparison exceeds the threshold.
+func Regressed(comparisons []*Comparison) bool {
+	for _, comparison := range comparisons {
+		if comparison.Regressed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrintComparisons prints the comparisons in a table format, the significant changes are marked
+// with "*" and the changes exceeding the threshold are marked with "!".
+func PrintComparisons(w io.Writer, comparisons []*Comparison) error {
+	table := tablewriter.NewTable(w, tablewriter.WithHeaderAutoFormat(tw.Off))
+	table.Header([]string{"DOWNLOADER", "FILE SIZE LEVEL", "METRIC", "BASELINE", "CURRENT", "DELTA", "MARK"})
+	for _, comparison := range comparisons {
+		delta := fmt.Sprintf("%+.2f%%", comparison.Delta)
+		switch {
+		case comparison.Missing:
+			delta = "-"
+		case comparison.Metric == "back_to_source_rate":
+			delta = fmt.Sprintf("%+.2fpp", comparison.Delta)
+		}
+
+		var marks []string
+		if comparison.Significant {
+			marks = append(marks, "*")
+		}
+
+		if comparison.Regressed {
+			marks = append(marks, "!")
+		}
+
+		if err := table.Append([]string{
+			strings.ToUpper(comparison.Downloader),
+			comparison.FileSizeLevel.String(),
+			comparison.Metric,
+			comparison.Baseline,
+			comparison.Current,
+			delta,
+			strings.Join(marks, " "),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// relativeDelta returns the relative change from the baseline to the current in percent.
+func relativeDelta(baseline, current float64) float64 {
+	if baseline == 0 {
+		if current == 0 {
+			return 0
+		}
+
+		return math.Inf(1)
+	}
+
+	return (current - baseline) / baseline * 100
+}
+
+// significanceTestable returns true if both results have enough downloads to test the significance.
+func significanceTestable(baseline, current *Result) bool {
+	return baseline.Count >= 2 && current.Count >= 2
+}
+
+// significant returns true if the difference of the average costs is statistically significant
+// by the Welch's test with the normal approximation.
+func significant(baseline, current *Result) bool {
+	if !significanceTestable(baseline, current) {
+		return false
+	}
+
+	baselineStddev, currentStddev := float64(baseline.Stddev), float64(current.Stddev)
+	standardError := math.Sqrt(baselineStddev*baselineStddev/float64(baseline.Count) + currentStddev*currentStddev/float64(current.Count))
+	if standardError == 0 {
+		return baseline.AvgCost != current.AvgCost
+	}
+
+	return math.Abs(float64(current.AvgCost-baseline.AvgCost))/standardError > significanceZ
+}
@@ -0,0 +1,187 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// newResult returns the result of the downloads of the small file by dfget.
+func newResult(avgCost, stddev time.Duration, count uint64) *Result {
+	return &Result{
+		Downloader:    config.DownloaderDfget,
+		FileSizeLevel: backend.FileSizeLevelSmall,
+		Count:         count,
+		AvgCost:       avgCost,
+		P50Cost:       avgCost,
+		P90Cost:       avgCost,
+		P99Cost:       avgCost,
+		Stddev:        stddev,
+	}
+}
+
+func TestSignificant(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline *Result
+		current  *Result
+		expected bool
+	}{
+		{
+			name:     "too few samples",
+			baseline: newResult(100*time.Millisecond, time.Millisecond, 1),
+			current:  newResult(200*time.Millisecond, time.Millisecond, 100),
+		},
+		{
+			name:     "small change in large noise",
+			baseline: newResult(100*time.Millisecond, 50*time.Millisecond, 10),
+			current:  newResult(110*time.Millisecond, 50*time.Millisecond, 10),
+		},
+		{
+			name:     "large change in small noise",
+			baseline: newResult(100*time.Millisecond, 5*time.Millisecond, 10),
+			current:  newResult(110*time.Millisecond, 5*time.Millisecond, 10),
+			expected: true,
+		},
+		{
+			name:     "no noise and no change",
+			baseline: newResult(100*time.Millisecond, 0, 10),
+			current:  newResult(100*time.Millisecond, 0, 10),
+		},
+		{
+			name:     "no noise and a change",
+			baseline: newResult(100*time.Millisecond, 0, 10),
+			current:  newResult(101*time.Millisecond, 0, 10),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := significant(tt.baseline, tt.current); got != tt.expected {
+				t.Errorf("significant = %t, want %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRelativeDelta(t *testing.T) {
+	tests := []struct {
+		baseline, current, expected float64
+	}{
+		{baseline: 100, current: 110, expected: 10},
+		{baseline: 100, current: 50, expected: -50},
+		{baseline: 0, current: 0, expected: 0},
+		{baseline: 0, current: 1, expected: math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		if got := relativeDelta(tt.baseline, tt.current); got != tt.expected {
+			t.Errorf("relativeDelta(%v, %v) = %v, want %v", tt.baseline, tt.current, got, tt.expected)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	thresholds := &config.CompareConfig{LatencyThreshold: 10, BackToSourceRateThreshold: 5}
+	tests := []struct {
+		name      string
+		baseline  []*Result
+		current   []*Result
+		regressed []string
+		missing   bool
+	}{
+		{
+			name:     "unchanged",
+			baseline: []*Result{newResult(100*time.Millisecond, 5*time.Millisecond, 10)},
+			current:  []*Result{newResult(100*time.Millisecond, 5*time.Millisecond, 10)},
+		},
+		{
+			name:      "significant regression of the average cost",
+			baseline:  []*Result{newResult(100*time.Millisecond, 5*time.Millisecond, 10)},
+			current:   []*Result{newResult(150*time.Millisecond, 5*time.Millisecond, 10)},
+			regressed: []string{"avg_cost", "p50_cost", "p90_cost", "p99_cost"},
+		},
+		{
+			name:      "insignificant regression of the average cost",
+			baseline:  []*Result{newResult(100*time.Millisecond, 500*time.Millisecond, 10)},
+			current:   []*Result{newResult(150*time.Millisecond, 500*time.Millisecond, 10)},
+			regressed: []string{"p50_cost", "p90_cost", "p99_cost"},
+		},
+		{
+			name:      "regression of a single download",
+			baseline:  []*Result{newResult(100*time.Millisecond, 0, 1)},
+			current:   []*Result{newResult(150*time.Millisecond, 0, 1)},
+			regressed: []string{"avg_cost", "p50_cost", "p90_cost", "p99_cost"},
+		},
+		{
+			name:     "change of a single download within the threshold",
+			baseline: []*Result{newResult(100*time.Millisecond, 0, 1)},
+			current:  []*Result{newResult(105*time.Millisecond, 5*time.Millisecond, 10)},
+		},
+		{
+			name:      "back-to-source rate increase",
+			baseline:  []*Result{{Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelSmall, BackToSourceRate: 10}},
+			current:   []*Result{{Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelSmall, BackToSourceRate: 20}},
+			regressed: []string{"back_to_source_rate"},
+		},
+		{
+			name:      "missing result of the baseline",
+			baseline:  []*Result{newResult(100*time.Millisecond, 5*time.Millisecond, 10)},
+			regressed: []string{"count"},
+			missing:   true,
+		},
+		{
+			name:    "new result without baseline",
+			current: []*Result{newResult(100*time.Millisecond, 5*time.Millisecond, 10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := Compare(&Report{Results: tt.baseline}, &Report{Results: tt.current}, thresholds)
+
+			var regressed []string
+			var missing bool
+			for _, comparison := range comparisons {
+				if comparison.Regressed {
+					regressed = append(regressed, comparison.Metric)
+				}
+
+				missing = missing || comparison.Missing
+			}
+
+			if !slices.Equal(regressed, tt.regressed) {
+				t.Errorf("regressed metrics = %v, want %v", regressed, tt.regressed)
+			}
+
+			if missing != tt.missing {
+				t.Errorf("missing = %t, want %t", missing, tt.missing)
+			}
+
+			if Regressed(comparisons) != (len(tt.regressed) > 0) {
+				t.Errorf("Regressed = %t, want %t", Regressed(comparisons), len(tt.regressed) > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+// FaultResult represents the aggregated statistics of the downloads of the faulty tasks of a file size
+// level by a downloader against the downloads of the tasks without faults.
+type FaultResult struct {
+	// Downloader is the downloader used to download the file.
+	Downloader string `json:"downloader"`
+
+	// FileSizeLevel is the file size level of the file.
+	FileSizeLevel backend.FileSizeLevel `json:"file_size_level"`
+
+	// Fault is the fault profile injected into the responses of the faulty tasks.
+	Fault string `json:"fault"`
+
+	// Count is the number of the downloads of the faulty tasks.
+	Count uint64 `json:"count"`
+
+	// Recovered is the number of the downloads of the faulty tasks finished with the right content.
+	Recovered uint64 `json:"recovered"`
+
+	// Failed is the number of the downloads of the faulty tasks failed or corrupted.
+	Failed uint64 `json:"failed"`
+
+	// AvgCost is the average client side cost of the cold downloads of the tasks without faults, zero if
+	// there is no such download.
+	AvgCost time.Duration `json:"avg_cost,omitempty"`
+
+	// AvgRecoveredCost is the average client side cost of the recovered downloads, zero if none is recovered.
+	AvgRecoveredCost time.Duration `json:"avg_recovered_cost,omitempty"`
+
+	// Penalty is the average client side cost of the recovered downloads minus the average client side
+	// cost of the downloads without faults, zero if either is not recorded.
+	Penalty time.Duration `json:"penalty,omitempty"`
+}
+
+// recoveryRate returns the percentage of the downloads of the faulty tasks recovered.
+func (r *FaultResult) recoveryRate() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return float64(r.Recovered) / float64(r.Count) * 100
+}
+
+// faultResults aggregates the client side timings of the faulty tasks by downloader and file size level,
+// the penalty is against the cold downloads of the tasks without faults, so the warm and preheated
+// downloads are not compared.
+func (s *stats) faultResults() []*FaultResult {
+	timings := make(map[string]map[backend.FileSizeLevel][]*Timing)
+	for _, timing := range s.GetTimings() {
+		if timings[timing.Downloader] == nil {
+			timings[timing.Downloader] = make(map[backend.FileSizeLevel][]*Timing)
+		}
+
+		timings[timing.Downloader][timing.FileSizeLevel] = append(timings[timing.Downloader][timing.FileSizeLevel], timing)
+	}
+
+	var results []*FaultResult
+	for _, downloader := range downloaders {
+		for _, fileSizeLevel := range sortFileSizeLevels(slices.Collect(maps.Keys(timings[downloader]))) {
+			result := &FaultResult{Downloader: downloader, FileSizeLevel: fileSizeLevel, Fault: s.config.Dragonfly.Origin.Fault}
+			var costs, recoveredCosts []time.Duration
+			for _, timing := range timings[downloader][fileSizeLevel] {
+				switch {
+				case timing.Upload || timing.Preheat:
+				case timing.Faulty:
+					result.Count++
+					if timing.Failed || timing.Corrupted {
+						result.Failed++
+						continue
+					}
+
+					result.Recovered++
+					recoveredCosts = append(recoveredCosts, timing.Cost())
+				case !timing.Warm && !timing.Preheated && !timing.Corrupted:
+					costs = append(costs, timing.Cost())
+				}
+			}
+
+			if result.Count == 0 {
+				continue
+			}
+
+			avgCost, ok := average(costs)
+			avgRecoveredCost, recovered := average(recoveredCosts)
+			result.AvgCost = avgCost
+			result.AvgRecoveredCost = avgRecoveredCost
+			if ok && recovered {
+				result.Penalty = avgRecoveredCost - avgCost
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// printFaultTable prints the statistics of the downloads of the faulty tasks in a table format.
+func printFaultTable(w io.Writer, results []*FaultResult, markdown bool) error {
+	table := newTable(w, markdown)
+	table.Header([]string{"FILE SIZE LEVEL", "FAULT", "TIMES", "RECOVERED", "FAILED", "RECOVERY RATE", "AVG COST", "AVG RECOVERED COST", "PENALTY"})
+	for _, result := range results {
+		if err := table.Append([]string{
+			result.FileSizeLevel.String(),
+			result.Fault,
+			strconv.FormatUint(result.Count, 10),
+			strconv.FormatUint(result.Recovered, 10),
+			strconv.FormatUint(result.Failed, 10),
+			fmt.Sprintf("%.2f%%", result.recoveryRate()),
+			formatOptionalDuration(result.AvgCost),
+			formatOptionalDuration(result.AvgRecoveredCost),
+			formatOptionalDuration(result.Penalty),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// formatOptionalDuration formats the duration in milliseconds, "-" for zero as it is not recorded.
+func formatOptionalDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+
+	return formatDuration(d)
+}
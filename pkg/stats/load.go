@@ -0,0 +1,445 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+)
+
+// latencyBuckets are the lower bounds of the buckets of the latency histogram, the same as
+// the histogram reported by tools/proxy-bench/proxy-bench.sh.
+var latencyBuckets = []time.Duration{
+	0,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// Load represents the requests sent by a run of the load generator through the dfdaemon proxy.
+type Load struct {
+	// Mode is the mode of the load.
+	Mode string `json:"mode"`
+
+	// Rate is the target number of requests per second, 0 if the requests are sent as fast as possible.
+	Rate uint32 `json:"rate"`
+
+	// Duration is the time from the first request is sent to the last request is sent.
+	Duration time.Duration `json:"duration"`
+
+	// Wait is the time from the last request is sent to all responses are received.
+	Wait time.Duration `json:"wait"`
+
+	// Dropped is the number of the requests not sent as all workers are busy at the scheduled time.
+	Dropped uint64 `json:"dropped"`
+
+	// Requests are the results of all requests.
+	Requests []*Request `json:"-"`
+}
+
+// Request represents the result of a request sent by the load generator.
+type Request struct {
+	// Latency is the time from the request is sent to the response body is read.
+	Latency time.Duration
+
+	// BytesIn is the size of the response body in bytes.
+	BytesIn uint64
+
+	// Code is the status code of the response, 0 if no response is received.
+	Code int
+
+	// Error is the error of the request, empty if the request succeeds.
+	Error string
+}
+
+// LoadResult represents the aggregated statistics of a load.
+type LoadResult struct {
+	// Mode is the mode of the load.
+	Mode string `json:"mode"`
+
+	// Requests is the number of the requests.
+	Requests uint64 `json:"requests"`
+
+	// Dropped is the number of the scheduled requests dropped as all workers are busy.
+	Dropped uint64 `json:"dropped"`
+
+	// TargetRate is the target number of requests per second, 0 if the requests are sent as fast as possible.
+	TargetRate uint32 `json:"target_rate"`
+
+	// Rate is the actual number of requests sent per second.
+	Rate float64 `json:"rate"`
+
+	// Throughput is the number of successful requests per second including the wait for the responses.
+	Throughput float64 `json:"throughput"`
+
+	// Success is the percentage of the requests with a 2xx status code.
+	Success float64 `json:"success"`
+
+	// MinLatency is the minimum latency of the requests.
+	MinLatency time.Duration `json:"min_latency"`
+
+	// MeanLatency is the mean latency of the requests.
+	MeanLatency time.Duration `json:"mean_latency"`
+
+	// P50Latency is the 50th percentile latency of the requests.
+	P50Latency time.Duration `json:"p50_latency"`
+
+	// P90Latency is the 90th percentile latency of the requests.
+	P90Latency time.Duration `json:"p90_latency"`
+
+	// P95Latency is the 95th percentile latency of the requests.
+	P95Latency time.Duration `json:"p95_latency"`
+
+	// P99Latency is the 99th percentile latency of the requests.
+	P99Latency time.Duration `json:"p99_latency"`
+
+	// MaxLatency is the maximum latency of the requests.
+	MaxLatency time.Duration `json:"max_latency"`
+
+	// BytesIn is the total size of the response bodies in bytes.
+	BytesIn uint64 `json:"bytes_in"`
+
+	// StatusCodes is the number of the requests by status code, 0 is the requests without a response.
+	StatusCodes map[int]uint64 `json:"status_codes"`
+
+	// Errors is the number of the requests by error.
+	Errors map[string]uint64 `json:"errors,omitempty"`
+
+	// Histogram is the latency histogram of the requests.
+	Histogram []*Bucket `json:"histogram"`
+
+	// BackToSourceTraffic is the traffic downloaded from the source in bytes.
+	BackToSourceTraffic float64 `json:"back_to_source_traffic"`
+
+	// RemotePeerTraffic is the traffic downloaded from the remote peers in bytes.
+	RemotePeerTraffic float64 `json:"remote_peer_traffic"`
+
+	// LocalPeerTraffic is the traffic downloaded from the local peer in bytes.
+	LocalPeerTraffic float64 `json:"local_peer_traffic"`
+
+	// BackToSourceRate is the percentage of the traffic downloaded from the source.
+	BackToSourceRate float64 `json:"back_to_source_rate"`
+}
+
+// Bucket represents a bucket of the latency histogram.
+type Bucket struct {
+	// Lower is the inclusive lower bound of the bucket.
+	Lower time.Duration `json:"lower"`
+
+	// Upper is the exclusive upper bound of the bucket, 0 if the bucket is unbounded.
+	Upper time.Duration `json:"upper,omitempty"`
+
+	// Count is the number of the requests in the bucket.
+	Count uint64 `json:"count"`
+
+	// Ratio is the percentage of the requests in the bucket.
+	Ratio float64 `json:"ratio"`
+}
+
+// String returns the bounds of the bucket, e.g. [10ms, 25ms).
+func (b *Bucket) String() string {
+	if b.Upper == 0 {
+		return fmt.Sprintf("[%s, +Inf)", b.Lower)
+	}
+
+	return fmt.Sprintf("[%s, %s)", b.Lower, b.Upper)
+}
+
+// GetLoads returns the loads sent through the dfdaemon proxy.
+func (s *stats) GetLoads() []*Load {
+	loads := []*Load{}
+	s.loads.Range(func(key, value interface{}) bool {
+		loads = append(loads, value.(*Load))
+		return true
+	})
+
+	return loads
+}
+
+// AddLoad adds a load sent through the dfdaemon proxy.
+func (s *stats) AddLoad(load *Load) {
+	s.loads.Store(uuid.New().String(), load)
+}
+
+// CollectLoadMetrics collects the client metrics of the load and resets the metrics.
+func (s *stats) CollectLoadMetrics(ctx context.Context, mode string) error {
+	return s.collectClientMetrics(ctx, func(pod string) *Download {
+		return &Download{podName: pod, load: mode}
+	})
+}
+
+// loadResults aggregates the requests and the dfdaemon traffic of the loads by mode.
+func (s *stats) loadResults() ([]*LoadResult, error) {
+	traffic := make(map[string]*PodMetrics)
+	for _, download := range s.GetDownloads() {
+		if download.load == "" {
+			continue
+		}
+
+		pm, err := download.podMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		if traffic[download.load] == nil {
+			traffic[download.load] = &PodMetrics{}
+		}
+
+		traffic[download.load].BackToSourceTraffic += pm.BackToSourceTraffic
+		traffic[download.load].RemotePeerTraffic += pm.RemotePeerTraffic
+		traffic[download.load].LocalPeerTraffic += pm.LocalPeerTraffic
+	}
+
+	var results []*LoadResult
+	for _, load := range s.GetLoads() {
+		if len(load.Requests) == 0 {
+			continue
+		}
+
+		result := &LoadResult{
+			Mode:        load.Mode,
+			Requests:    uint64(len(load.Requests)),
+			Dropped:     load.Dropped,
+			TargetRate:  load.Rate,
+			StatusCodes: make(map[int]uint64),
+		}
+
+		var (
+			latencies []time.Duration
+			successes uint64
+		)
+		for _, request := range load.Requests {
+			latencies = append(latencies, request.Latency)
+			result.BytesIn += request.BytesIn
+			result.StatusCodes[request.Code]++
+			if request.Error != "" {
+				if result.Errors == nil {
+					result.Errors = make(map[string]uint64)
+				}
+
+				result.Errors[request.Error]++
+			}
+
+			if request.Error == "" && request.Code >= 200 && request.Code < 300 {
+				successes++
+			}
+		}
+		slices.Sort(latencies)
+
+		if load.Duration > 0 {
+			result.Rate = float64(result.Requests) / load.Duration.Seconds()
+		}
+
+		if total := load.Duration + load.Wait; total > 0 {
+			result.Throughput = float64(successes) / total.Seconds()
+		}
+
+		result.Success = float64(successes) / float64(result.Requests) * 100
+		result.MinLatency = latencies[0]
+		result.MeanLatency, _ = average(latencies)
+		result.P50Latency = percentile(latencies, 50)
+		result.P90Latency = percentile(latencies, 90)
+		result.P95Latency = percentile(latencies, 95)
+		result.P99Latency = percentile(latencies, 99)
+		result.MaxLatency = latencies[len(latencies)-1]
+		result.Histogram = histogram(latencies)
+
+		if pm := traffic[load.Mode]; pm != nil {
+			result.BackToSourceTraffic = pm.BackToSourceTraffic
+			result.RemotePeerTraffic = pm.RemotePeerTraffic
+			result.LocalPeerTraffic = pm.LocalPeerTraffic
+			if total := pm.BackToSourceTraffic + pm.RemotePeerTraffic + pm.LocalPeerTraffic; total > 0 {
+				result.BackToSourceRate = pm.BackToSourceTraffic / total * 100
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	slices.SortFunc(results, func(a, b *LoadResult) int {
+		return strings.Compare(a.Mode, b.Mode)
+	})
+
+	return results, nil
+}
+
+// histogram counts the sorted latencies into the latency buckets.
+func histogram(sorted []time.Duration) []*Bucket {
+	buckets := make([]*Bucket, 0, len(latencyBuckets))
+	for i, lower := range latencyBuckets {
+		bucket := &Bucket{Lower: lower}
+		if i+1 < len(latencyBuckets) {
+			bucket.Upper = latencyBuckets[i+1]
+		}
+
+		start, _ := slices.BinarySearch(sorted, bucket.Lower)
+		end := len(sorted)
+		if bucket.Upper > 0 {
+			end, _ = slices.BinarySearch(sorted, bucket.Upper)
+		}
+
+		bucket.Count = uint64(end - start)
+		bucket.Ratio = float64(bucket.Count) / float64(len(sorted)) * 100
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets
+}
+
+// printLoadTable prints the load statistics in a table format.
+func printLoadTable(w io.Writer, results []*LoadResult, markdown bool) error {
+	table := newTable(w, markdown)
+	table.Header([]string{
+		"MODE", "REQUESTS", "DROPPED", "RATE", "THROUGHPUT", "SUCCESS", "STATUS CODES", "MIN", "MEAN", "P50", "P90", "P95", "P99", "MAX",
+		"BYTES IN", "BACK TO SOURCE TRAFFIC", "REMOTE PEER TRAFFIC", "LOCAL PEER TRAFFIC", "BACK TO SOURCE RATE",
+	})
+	for _, result := range results {
+		if err := table.Append([]string{
+			strings.ToUpper(result.Mode),
+			strconv.FormatUint(result.Requests, 10),
+			strconv.FormatUint(result.Dropped, 10),
+			fmt.Sprintf("%.2f/s", result.Rate),
+			fmt.Sprintf("%.2f/s", result.Throughput),
+			strconv.FormatFloat(result.Success, 'f', 2, 64) + "%",
+			formatStatusCodes(result.StatusCodes),
+			formatDuration(result.MinLatency),
+			formatDuration(result.MeanLatency),
+			formatDuration(result.P50Latency),
+			formatDuration(result.P90Latency),
+			formatDuration(result.P95Latency),
+			formatDuration(result.P99Latency),
+			formatDuration(result.MaxLatency),
+			humanize.Bytes(result.BytesIn),
+			humanize.Bytes(uint64(result.BackToSourceTraffic)),
+			humanize.Bytes(uint64(result.RemotePeerTraffic)),
+			humanize.Bytes(uint64(result.LocalPeerTraffic)),
+			strconv.FormatFloat(result.BackToSourceRate, 'f', 2, 64) + "%",
+		}); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// printHistogramTable prints the latency histograms of the loads in a table format.
+func printHistogramTable(w io.Writer, results []*LoadResult, markdown bool) error {
+	table := newTable(w, markdown)
+	table.Header([]string{"MODE", "BUCKET", "COUNT", "RATIO", "HISTOGRAM"})
+	for _, result := range results {
+		for _, bucket := range result.Histogram {
+			if err := table.Append([]string{
+				strings.ToUpper(result.Mode),
+				bucket.String(),
+				strconv.FormatUint(bucket.Count, 10),
+				strconv.FormatFloat(bucket.Ratio, 'f', 2, 64) + "%",
+				strings.Repeat("#", int(bucket.Ratio/2)),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return table.Render()
+}
+
+// formatStatusCodes formats the number of the requests by status code in the order of the codes, e.g. 200:98 0:2.
+func formatStatusCodes(statusCodes map[int]uint64) string {
+	codes := make([]int, 0, len(statusCodes))
+	for code := range statusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	formatted := make([]string, 0, len(codes))
+	for _, code := range codes {
+		formatted = append(formatted, fmt.Sprintf("%d:%d", code, statusCodes[code]))
+	}
+
+	return strings.Join(formatted, " ")
+}
+
+// writeLoadCSV writes the load results of the report in CSV format, a row per histogram bucket follows
+// the row of the load with the bounds and the ratio of the bucket, the times are in milliseconds.
+func writeLoadCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"mode", "bucket", "requests", "ratio", "dropped", "rate", "throughput", "success", "min_ms", "mean_ms", "p50_ms", "p90_ms", "p95_ms", "p99_ms", "max_ms",
+		"bytes_in", "back_to_source_traffic_bytes", "remote_peer_traffic_bytes", "local_peer_traffic_bytes", "back_to_source_rate",
+	}); err != nil {
+		return err
+	}
+
+	for _, result := range report.LoadResults {
+		if err := writer.Write([]string{
+			result.Mode,
+			"",
+			strconv.FormatUint(result.Requests, 10),
+			"",
+			strconv.FormatUint(result.Dropped, 10),
+			strconv.FormatFloat(result.Rate, 'f', 2, 64),
+			strconv.FormatFloat(result.Throughput, 'f', 2, 64),
+			strconv.FormatFloat(result.Success, 'f', 2, 64),
+			formatMilliseconds(result.MinLatency),
+			formatMilliseconds(result.MeanLatency),
+			formatMilliseconds(result.P50Latency),
+			formatMilliseconds(result.P90Latency),
+			formatMilliseconds(result.P95Latency),
+			formatMilliseconds(result.P99Latency),
+			formatMilliseconds(result.MaxLatency),
+			strconv.FormatUint(result.BytesIn, 10),
+			strconv.FormatFloat(result.BackToSourceTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.RemotePeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.LocalPeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.BackToSourceRate, 'f', 2, 64),
+		}); err != nil {
+			return err
+		}
+
+		// Only the number of the requests and their percentage are reported for the buckets.
+		for _, bucket := range result.Histogram {
+			if err := writer.Write([]string{
+				result.Mode,
+				bucket.String(),
+				strconv.FormatUint(bucket.Count, 10),
+				strconv.FormatFloat(bucket.Ratio, 'f', 2, 64),
+				"", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
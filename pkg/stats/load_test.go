@@ -0,0 +1,128 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+func TestHistogram(t *testing.T) {
+	latencies := []time.Duration{
+		0,
+		9 * time.Millisecond,
+		10 * time.Millisecond,
+		24 * time.Millisecond,
+		25 * time.Millisecond,
+		999 * time.Millisecond,
+		time.Second,
+		5 * time.Second,
+		time.Minute,
+	}
+
+	buckets := histogram(latencies)
+	if len(buckets) != len(latencyBuckets) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(latencyBuckets))
+	}
+
+	// The lower bounds are inclusive and the upper bounds are exclusive, the last bucket is unbounded.
+	expected := map[string]uint64{
+		"[0s, 10ms)":    2,
+		"[10ms, 25ms)":  2,
+		"[25ms, 50ms)":  1,
+		"[500ms, 1s)":   1,
+		"[1s, 2s)":      1,
+		"[5s, +Inf)":    2,
+		"[50ms, 100ms)": 0,
+	}
+
+	var total uint64
+	for _, bucket := range buckets {
+		total += bucket.Count
+		if count, ok := expected[bucket.String()]; ok && bucket.Count != count {
+			t.Errorf("bucket %s has %d latencies, want %d", bucket, bucket.Count, count)
+		}
+
+		if math.Abs(bucket.Ratio-float64(bucket.Count)/float64(len(latencies))*100) > 1e-9 {
+			t.Errorf("bucket %s has ratio %f of %d latencies", bucket, bucket.Ratio, bucket.Count)
+		}
+	}
+
+	if total != uint64(len(latencies)) {
+		t.Errorf("buckets have %d latencies, want %d", total, len(latencies))
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	s := New(config.New(), nil).(*stats)
+	s.AddLoad(&Load{
+		Mode:     config.LoadModeRepeat,
+		Rate:     10,
+		Duration: 2 * time.Second,
+		Wait:     2 * time.Second,
+		Dropped:  3,
+		Requests: []*Request{
+			{Latency: 10 * time.Millisecond, BytesIn: 100, Code: 200},
+			{Latency: 20 * time.Millisecond, BytesIn: 100, Code: 206},
+			{Latency: 30 * time.Millisecond, BytesIn: 50, Code: 200, Error: "unexpected EOF"},
+			{Latency: 40 * time.Millisecond, Code: 502},
+			{Latency: 50 * time.Millisecond, Error: "context deadline exceeded"},
+			{Latency: 60 * time.Millisecond, BytesIn: 100, Code: 200},
+		},
+	})
+	s.AddLoad(&Load{Mode: config.LoadModeRandom, Duration: time.Second})
+
+	results, err := s.loadResults()
+	if err != nil {
+		t.Fatalf("loadResults failed: %v", err)
+	}
+
+	// The load without requests is not reported.
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	result := results[0]
+	if result.Requests != 6 || result.Dropped != 3 || result.TargetRate != 10 || result.BytesIn != 350 {
+		t.Errorf("result = %+v, want 6 requests, 3 dropped, target rate 10 and 350 bytes", result)
+	}
+
+	// The rate is the requests sent in the duration, the throughput is the successful requests in the
+	// duration and the wait for the responses.
+	if result.Rate != 3 || result.Throughput != 0.75 || result.Success != 50 {
+		t.Errorf("rate = %f, throughput = %f, success = %f, want 3, 0.75 and 50", result.Rate, result.Throughput, result.Success)
+	}
+
+	if result.MinLatency != 10*time.Millisecond || result.MaxLatency != 60*time.Millisecond || result.MeanLatency != 35*time.Millisecond {
+		t.Errorf("latencies = %v, %v, %v, want 10ms, 35ms and 60ms", result.MinLatency, result.MeanLatency, result.MaxLatency)
+	}
+
+	if result.StatusCodes[200] != 3 || result.StatusCodes[206] != 1 || result.StatusCodes[502] != 1 || result.StatusCodes[0] != 1 {
+		t.Errorf("status codes = %v", result.StatusCodes)
+	}
+
+	if result.Errors["unexpected EOF"] != 1 || result.Errors["context deadline exceeded"] != 1 || len(result.Errors) != 2 {
+		t.Errorf("errors = %v", result.Errors)
+	}
+
+	if formatted := formatStatusCodes(result.StatusCodes); formatted != "0:1 200:3 206:1 502:1" {
+		t.Errorf("formatStatusCodes = %q", formatted)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"fmt"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// OriginTraffic represents the traffic served by the file server for the tasks of a download step.
+type OriginTraffic struct {
+	// Downloader is the downloader used to download the files.
+	Downloader string `json:"downloader"`
+
+	// FileSizeLevel is the file size level of the files.
+	FileSizeLevel backend.FileSizeLevel `json:"file_size_level"`
+
+	// Warm is true if the downloads reuse the tasks of the previous iteration.
+	Warm bool `json:"warm"`
+
+	// Preheated is true if the tasks are preheated before the downloads.
+	Preheated bool `json:"preheated,omitempty"`
+
+	// Tasks is the number of the tasks downloaded.
+	Tasks uint64 `json:"tasks"`
+
+	// Requests is the number of the requests of the tasks served by the file server.
+	Requests uint64 `json:"requests"`
+
+	// Bytes is the bytes of the bodies of the tasks sent by the file server.
+	Bytes uint64 `json:"bytes"`
+}
+
+// GetOriginTraffic returns the traffic served by the file server for the download steps.
+func (s *stats) GetOriginTraffic() []*OriginTraffic {
+	traffic := []*OriginTraffic{}
+	s.originTraffic.Range(func(key, value interface{}) bool {
+		traffic = append(traffic, value.(*OriginTraffic))
+		return true
+	})
+
+	return traffic
+}
+
+// AddOriginTraffic adds the traffic served by the file server for a download step.
+func (s *stats) AddOriginTraffic(traffic *OriginTraffic) {
+	s.originTraffic.Store(uuid.New().String(), traffic)
+}
+
+// addOriginTraffic aggregates the traffic served by the file server into the summary.
+func (s *summary) addOriginTraffic(traffic []*OriginTraffic) {
+	for _, t := range traffic {
+		s.originTasks += t.Tasks
+		s.originRequests += t.Requests
+		s.originTraffic += float64(t.Bytes)
+	}
+}
+
+// originFetches returns the traffic served by the file server per task in the file size, 1 if every
+// task is fetched from the file server once, false if no traffic is counted.
+func (s *summary) originFetches() (float64, bool) {
+	if s.originTasks == 0 || s.fileSizeLevel.Bytes() == 0 {
+		return 0, false
+	}
+
+	return s.originTraffic / float64(s.fileSizeLevel.Bytes()*s.originTasks), true
+}
+
+// reconcileOriginTraffic warns if the traffic served by the file server mismatches the back-to-source
+// traffic reported by the dfdaemon, or the tasks are fetched from the file server more than once. The
+// direct downloads fetch the file in every pod, so only the traffic is reconciled.
+func reconcileOriginTraffic(downloader string, s *summary) {
+	if s.originTasks == 0 {
+		return
+	}
+
+	// The dfdaemon of the client pods fetches from the file server at most the traffic served, the rest
+	// is fetched by the seed peers.
+	if s.backToSourceTraffic > s.originTraffic {
+		logrus.Warnf("the back-to-source traffic %s of %s by %s reported by the dfdaemon exceeds the traffic %s served by the file server",
+			humanize.Bytes(uint64(s.backToSourceTraffic)), s.fileSizeLevel, downloader, humanize.Bytes(uint64(s.originTraffic)))
+	}
+
+	if fetches, ok := s.originFetches(); ok && fetches > 1 && downloader != config.DownloaderDirect {
+		logrus.Warnf("the file server served %.2fx of the file size per task of %s by %s, the tasks are fetched from the origin more than once",
+			fetches, s.fileSizeLevel, downloader)
+	}
+}
+
+// formatOriginDiff formats the traffic served by the file server minus the back-to-source traffic
+// reported by the dfdaemon with the sign.
+func formatOriginDiff(s *summary) string {
+	if s.originTasks == 0 {
+		return "-"
+	}
+
+	diff := s.originTraffic - s.backToSourceTraffic
+	if diff < 0 {
+		return fmt.Sprintf("-%s", humanize.Bytes(uint64(-diff)))
+	}
+
+	return fmt.Sprintf("+%s", humanize.Bytes(uint64(diff)))
+}
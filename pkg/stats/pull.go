@@ -0,0 +1,417 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/renderer"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+// ImagePull represents the pull timing of an image on a node.
+type ImagePull struct {
+	// PodName is the name of the client pod on the node.
+	PodName string `json:"pod_name"`
+
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Total is the time to pull the image.
+	Total time.Duration `json:"total"`
+
+	// Layers is the pull timing of every layer, empty if not reported by the puller.
+	Layers []*LayerPull `json:"layers,omitempty"`
+
+	// Warm is true if the layers are served by the dfdaemon tasks of the previous pulls.
+	Warm bool `json:"warm"`
+
+	// Preheat is the time of the preheat job of the image before the pull, zero if not preheated.
+	Preheat time.Duration `json:"preheat,omitempty"`
+}
+
+// LayerPull represents the pull timing of a layer of an image.
+type LayerPull struct {
+	// Digest is the digest of the layer.
+	Digest string `json:"digest"`
+
+	// Done is the time from the start of the image pull to the layer is pulled.
+	Done time.Duration `json:"done"`
+}
+
+// PullResult represents the aggregated pull statistics of an image.
+type PullResult struct {
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Cache is the cache state of the image pulls [cold, warm].
+	Cache string `json:"cache"`
+
+	// Count is the number of the image pulls.
+	Count uint64 `json:"count"`
+
+	// MinPull is the minimum time to pull the image.
+	MinPull time.Duration `json:"min_pull"`
+
+	// MaxPull is the maximum time to pull the image.
+	MaxPull time.Duration `json:"max_pull"`
+
+	// AvgPull is the average time to pull the image.
+	AvgPull time.Duration `json:"avg_pull"`
+
+	// P50Pull is the 50th percentile time to pull the image.
+	P50Pull time.Duration `json:"p50_pull"`
+
+	// P90Pull is the 90th percentile time to pull the image.
+	P90Pull time.Duration `json:"p90_pull"`
+
+	// P99Pull is the 99th percentile time to pull the image.
+	P99Pull time.Duration `json:"p99_pull"`
+
+	// AvgPreheat is the average time of the preheat jobs of the image, zero if not preheated.
+	AvgPreheat time.Duration `json:"avg_preheat,omitempty"`
+
+	// Layers is the aggregated pull timing of every layer in the order of the average done time.
+	Layers []*LayerResult `json:"layers,omitempty"`
+
+	// BackToSourceTraffic is the traffic downloaded from the source in bytes.
+	BackToSourceTraffic float64 `json:"back_to_source_traffic"`
+
+	// RemotePeerTraffic is the traffic downloaded from the remote peers in bytes.
+	RemotePeerTraffic float64 `json:"remote_peer_traffic"`
+
+	// LocalPeerTraffic is the traffic downloaded from the local peer in bytes.
+	LocalPeerTraffic float64 `json:"local_peer_traffic"`
+
+	// BackToSourceRate is the percentage of the traffic downloaded from the source.
+	BackToSourceRate float64 `json:"back_to_source_rate"`
+}
+
+// LayerResult represents the aggregated pull timing of a layer.
+type LayerResult struct {
+	// Digest is the digest of the layer.
+	Digest string `json:"digest"`
+
+	// Count is the number of the layer pulls.
+	Count uint64 `json:"count"`
+
+	// AvgDone is the average time from the start of the image pull to the layer is pulled.
+	AvgDone time.Duration `json:"avg_done"`
+
+	// MaxDone is the maximum time from the start of the image pull to the layer is pulled.
+	MaxDone time.Duration `json:"max_done"`
+}
+
+// GetImagePulls returns the pull timings of the images.
+func (s *stats) GetImagePulls() []*ImagePull {
+	pulls := []*ImagePull{}
+	s.imagePulls.Range(func(key, value interface{}) bool {
+		pulls = append(pulls, value.(*ImagePull))
+		return true
+	})
+
+	return pulls
+}
+
+// AddImagePull adds the pull timing of an image.
+func (s *stats) AddImagePull(pull *ImagePull) {
+	s.imagePulls.Store(uuid.New().String(), pull)
+}
+
+// pullResults aggregates the image pulls and the dfdaemon traffic by image and cache state in the order
+// of the images, the cold pulls are followed by the warm ones.
+func (s *stats) pullResults() ([]*PullResult, error) {
+	byImage := make(map[string]map[bool][]*ImagePull)
+	for _, pull := range s.GetImagePulls() {
+		if byImage[pull.Image] == nil {
+			byImage[pull.Image] = make(map[bool][]*ImagePull)
+		}
+
+		byImage[pull.Image][pull.Warm] = append(byImage[pull.Image][pull.Warm], pull)
+	}
+
+	traffic := make(map[string]map[bool]*PodMetrics)
+	for _, download := range s.GetDownloads() {
+		if download.image == "" {
+			continue
+		}
+
+		pm, err := download.podMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		if traffic[download.image] == nil {
+			traffic[download.image] = make(map[bool]*PodMetrics)
+		}
+
+		if traffic[download.image][download.warm] == nil {
+			traffic[download.image][download.warm] = &PodMetrics{}
+		}
+
+		traffic[download.image][download.warm].BackToSourceTraffic += pm.BackToSourceTraffic
+		traffic[download.image][download.warm].RemotePeerTraffic += pm.RemotePeerTraffic
+		traffic[download.image][download.warm].LocalPeerTraffic += pm.LocalPeerTraffic
+	}
+
+	var results []*PullResult
+	for _, image := range s.config.Image.References() {
+		for _, warm := range []bool{false, true} {
+			if pulls := byImage[image][warm]; len(pulls) > 0 {
+				results = append(results, newPullResult(image, warm, pulls, traffic[image][warm]))
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// newPullResult aggregates the image pulls of the cache state and the dfdaemon traffic of the pulls.
+func newPullResult(image string, warm bool, pulls []*ImagePull, pm *PodMetrics) *PullResult {
+	var totals, preheats []time.Duration
+	layers := make(map[string][]time.Duration)
+	for _, pull := range pulls {
+		totals = append(totals, pull.Total)
+		if pull.Preheat > 0 {
+			preheats = append(preheats, pull.Preheat)
+		}
+
+		for _, layer := range pull.Layers {
+			layers[layer.Digest] = append(layers[layer.Digest], layer.Done)
+		}
+	}
+	slices.Sort(totals)
+
+	avgPull, _ := average(totals)
+	avgPreheat, _ := average(preheats)
+	result := &PullResult{
+		Image:      image,
+		Cache:      cacheState(warm, false),
+		Count:      uint64(len(totals)),
+		MinPull:    totals[0],
+		MaxPull:    totals[len(totals)-1],
+		AvgPull:    avgPull,
+		P50Pull:    percentile(totals, 50),
+		P90Pull:    percentile(totals, 90),
+		P99Pull:    percentile(totals, 99),
+		AvgPreheat: avgPreheat,
+	}
+
+	for digest, dones := range layers {
+		avgDone, _ := average(dones)
+		result.Layers = append(result.Layers, &LayerResult{
+			Digest:  digest,
+			Count:   uint64(len(dones)),
+			AvgDone: avgDone,
+			MaxDone: slices.Max(dones),
+		})
+	}
+
+	slices.SortFunc(result.Layers, func(a, b *LayerResult) int {
+		if c := cmp.Compare(a.AvgDone, b.AvgDone); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Digest, b.Digest)
+	})
+
+	if pm != nil {
+		result.BackToSourceTraffic = pm.BackToSourceTraffic
+		result.RemotePeerTraffic = pm.RemotePeerTraffic
+		result.LocalPeerTraffic = pm.LocalPeerTraffic
+		if total := pm.BackToSourceTraffic + pm.RemotePeerTraffic + pm.LocalPeerTraffic; total > 0 {
+			result.BackToSourceRate = pm.BackToSourceTraffic / total * 100
+		}
+	}
+
+	return result
+}
+
+// newTable creates a table writer in the table or markdown format.
+func newTable(w io.Writer, markdown bool) *tablewriter.Table {
+	options := []tablewriter.Option{tablewriter.WithHeaderAutoFormat(tw.Off)}
+	if markdown {
+		options = append(options, tablewriter.WithRenderer(renderer.NewMarkdown()))
+	}
+
+	return tablewriter.NewTable(w, options...)
+}
+
+// printPullTable prints the image pull statistics in a table format, the average preheat time
+// is printed if the images are preheated.
+func printPullTable(w io.Writer, results []*PullResult, markdown bool, preheat bool) error {
+	header := []string{
+		"IMAGE", "CACHE", "TIMES", "MIN PULL", "MAX PULL", "AVG PULL", "P50 PULL", "P90 PULL", "P99 PULL", "LAYERS",
+		"BACK TO SOURCE TRAFFIC", "REMOTE PEER TRAFFIC", "LOCAL PEER TRAFFIC", "BACK TO SOURCE RATE",
+	}
+	if preheat {
+		header = append(header, "AVG PREHEAT")
+	}
+
+	table := newTable(w, markdown)
+	table.Header(header)
+	for _, result := range results {
+		row := []string{
+			result.Image,
+			result.Cache,
+			strconv.FormatUint(result.Count, 10),
+			formatDuration(result.MinPull),
+			formatDuration(result.MaxPull),
+			formatDuration(result.AvgPull),
+			formatDuration(result.P50Pull),
+			formatDuration(result.P90Pull),
+			formatDuration(result.P99Pull),
+			strconv.Itoa(len(result.Layers)),
+			humanize.Bytes(uint64(result.BackToSourceTraffic)),
+			humanize.Bytes(uint64(result.RemotePeerTraffic)),
+			humanize.Bytes(uint64(result.LocalPeerTraffic)),
+			strconv.FormatFloat(result.BackToSourceRate, 'f', 2, 64) + "%",
+		}
+		if preheat {
+			row = append(row, formatDuration(result.AvgPreheat))
+		}
+
+		if err := table.Append(row); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// printLayerTable prints the layer pull statistics of the images in a table format, nothing is
+// printed if no layer timing is reported.
+func printLayerTable(w io.Writer, results []*PullResult, markdown bool) error {
+	table := newTable(w, markdown)
+	table.Header([]string{"IMAGE", "CACHE", "LAYER", "TIMES", "AVG DONE", "MAX DONE"})
+
+	var rows int
+	for _, result := range results {
+		for _, layer := range result.Layers {
+			if err := table.Append([]string{
+				result.Image,
+				result.Cache,
+				shortDigest(layer.Digest),
+				strconv.FormatUint(layer.Count, 10),
+				formatDuration(layer.AvgDone),
+				formatDuration(layer.MaxDone),
+			}); err != nil {
+				return err
+			}
+
+			rows++
+		}
+	}
+
+	if rows == 0 {
+		return nil
+	}
+
+	return table.Render()
+}
+
+// shortDigest returns the digest truncated to 12 hex characters, e.g. sha256:0123456789ab.
+func shortDigest(digest string) string {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || len(hex) <= 12 {
+		return digest
+	}
+
+	return algorithm + ":" + hex[:12]
+}
+
+// writePullCSV writes the pull results of the report in CSV format, a row per layer follows the row
+// of the image with the layer digest, the times are in milliseconds.
+func writePullCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"image", "cache", "layer", "count", "min_pull_ms", "max_pull_ms", "avg_pull_ms", "p50_pull_ms", "p90_pull_ms", "p99_pull_ms",
+		"back_to_source_traffic_bytes", "remote_peer_traffic_bytes", "local_peer_traffic_bytes", "back_to_source_rate",
+		"avg_preheat_ms",
+	}); err != nil {
+		return err
+	}
+
+	for _, result := range report.PullResults {
+		if err := writer.Write([]string{
+			result.Image,
+			result.Cache,
+			"",
+			strconv.FormatUint(result.Count, 10),
+			formatMilliseconds(result.MinPull),
+			formatMilliseconds(result.MaxPull),
+			formatMilliseconds(result.AvgPull),
+			formatMilliseconds(result.P50Pull),
+			formatMilliseconds(result.P90Pull),
+			formatMilliseconds(result.P99Pull),
+			strconv.FormatFloat(result.BackToSourceTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.RemotePeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.LocalPeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.BackToSourceRate, 'f', 2, 64),
+			formatMilliseconds(result.AvgPreheat),
+		}); err != nil {
+			return err
+		}
+
+		// The layers are pulled concurrently, so only the average and the maximum done time are reported.
+		for _, layer := range result.Layers {
+			if err := writer.Write([]string{
+				result.Image,
+				result.Cache,
+				layer.Digest,
+				strconv.FormatUint(layer.Count, 10),
+				"",
+				formatMilliseconds(layer.MaxDone),
+				formatMilliseconds(layer.AvgDone),
+				"", "", "", "", "", "", "", "",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// sortImagePulls sorts the image pulls by image, cache state and pod name.
+func sortImagePulls(pulls []*ImagePull) {
+	slices.SortStableFunc(pulls, func(a, b *ImagePull) int {
+		if c := strings.Compare(a.Image, b.Image); c != 0 {
+			return c
+		}
+
+		if a.Warm != b.Warm {
+			if a.Warm {
+				return 1
+			}
+
+			return -1
+		}
+
+		return strings.Compare(a.PodName, b.PodName)
+	})
+}
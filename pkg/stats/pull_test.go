@@ -0,0 +1,123 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+func TestPullResults(t *testing.T) {
+	cfg := config.New()
+	cfg.Image.Registry = "127.0.0.1:30500"
+	cfg.Image.Images = []string{"library/nginx:latest", "library/redis:latest", "library/busybox:latest"}
+	s := New(cfg, nil).(*stats)
+
+	nginx := "127.0.0.1:30500/library/nginx:latest"
+	redis := "127.0.0.1:30500/library/redis:latest"
+	pulls := []*ImagePull{
+		{PodName: "a", Image: redis, Total: 4 * time.Second, Warm: true},
+		{PodName: "a", Image: nginx, Total: 3 * time.Second, Layers: []*LayerPull{{Digest: "sha256:l1", Done: time.Second}, {Digest: "sha256:l2", Done: 2 * time.Second}}},
+		{PodName: "b", Image: nginx, Total: time.Second, Layers: []*LayerPull{{Digest: "sha256:l1", Done: 500 * time.Millisecond}, {Digest: "sha256:l2", Done: 500 * time.Millisecond}}},
+		{PodName: "a", Image: nginx, Total: 200 * time.Millisecond, Warm: true},
+		{PodName: "b", Image: nginx, Total: 400 * time.Millisecond, Warm: true},
+		{PodName: "a", Image: redis, Total: 5 * time.Second},
+	}
+	for _, pull := range pulls {
+		s.AddImagePull(pull)
+	}
+
+	results, err := s.pullResults()
+	if err != nil {
+		t.Fatalf("pullResults failed: %v", err)
+	}
+
+	type result struct {
+		image string
+		cache string
+		count uint64
+		min   time.Duration
+		max   time.Duration
+		avg   time.Duration
+	}
+
+	// The results are in the order of the images without the image of no pulls, the cold ones first.
+	expected := []result{
+		{image: nginx, cache: "cold", count: 2, min: time.Second, max: 3 * time.Second, avg: 2 * time.Second},
+		{image: nginx, cache: "warm", count: 2, min: 200 * time.Millisecond, max: 400 * time.Millisecond, avg: 300 * time.Millisecond},
+		{image: redis, cache: "cold", count: 1, min: 5 * time.Second, max: 5 * time.Second, avg: 5 * time.Second},
+		{image: redis, cache: "warm", count: 1, min: 4 * time.Second, max: 4 * time.Second, avg: 4 * time.Second},
+	}
+
+	var got []result
+	for _, r := range results {
+		got = append(got, result{image: r.Image, cache: r.Cache, count: r.Count, min: r.MinPull, max: r.MaxPull, avg: r.AvgPull})
+	}
+
+	if !slices.Equal(got, expected) {
+		t.Fatalf("pullResults() = %+v, want %+v", got, expected)
+	}
+
+	// The layers are ordered by the average done time.
+	var layers []LayerResult
+	for _, layer := range results[0].Layers {
+		layers = append(layers, *layer)
+	}
+
+	if expected := []LayerResult{
+		{Digest: "sha256:l1", Count: 2, AvgDone: 750 * time.Millisecond, MaxDone: time.Second},
+		{Digest: "sha256:l2", Count: 2, AvgDone: 1250 * time.Millisecond, MaxDone: 2 * time.Second},
+	}; !slices.Equal(layers, expected) {
+		t.Errorf("layers = %+v, want %+v", layers, expected)
+	}
+
+	if len(results[1].Layers) != 0 {
+		t.Errorf("warm layers = %+v, want none", results[1].Layers)
+	}
+}
+
+func TestSortImagePulls(t *testing.T) {
+	pulls := []*ImagePull{
+		{PodName: "b", Image: "nginx", Warm: true},
+		{PodName: "b", Image: "nginx"},
+		{PodName: "a", Image: "redis"},
+		{PodName: "a", Image: "nginx", Warm: true},
+		{PodName: "a", Image: "nginx"},
+	}
+	sortImagePulls(pulls)
+
+	var got []ImagePull
+	for _, pull := range pulls {
+		got = append(got, ImagePull{PodName: pull.PodName, Image: pull.Image, Warm: pull.Warm})
+	}
+
+	expected := []ImagePull{
+		{PodName: "a", Image: "nginx"},
+		{PodName: "b", Image: "nginx"},
+		{PodName: "a", Image: "nginx", Warm: true},
+		{PodName: "b", Image: "nginx", Warm: true},
+		{PodName: "a", Image: "redis"},
+	}
+	for i := range expected {
+		if got[i].PodName != expected[i].PodName || got[i].Image != expected[i].Image || got[i].Warm != expected[i].Warm {
+			t.Errorf("pulls[%d] = %+v, want %+v", i, got[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,348 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// downloaders is the downloaders in the order of the report.
+var downloaders = []string{config.DownloaderDfget, config.DownloaderProxy, config.DownloaderDfcache, config.DownloaderDfstore, config.DownloaderDirect}
+
+// Report represents the serializable report of the benchmark, the durations are in nanoseconds.
+type Report struct {
+	// StartedAt is the time when the benchmark is started.
+	StartedAt time.Time `json:"started_at"`
+
+	// FinishedAt is the time when the report is generated.
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Config is the configuration of the benchmark.
+	Config *config.Config `json:"config"`
+
+	// Results is the aggregated statistics by downloader and file size level.
+	Results []*Result `json:"results"`
+
+	// CacheResults is the aggregated statistics by downloader, file size level and cache state,
+	// empty if no download reuses the tasks of the previous iteration.
+	CacheResults []*CacheResult `json:"cache_results,omitempty"`
+
+	// WaveResults is the aggregated statistics by downloader, file size level and wave,
+	// empty if the pods are started in a single wave.
+	WaveResults []*WaveResult `json:"wave_results,omitempty"`
+
+	// FaultResults is the aggregated statistics of the downloads of the faulty tasks by downloader and
+	// file size level, empty if no fault is injected.
+	FaultResults []*FaultResult `json:"fault_results,omitempty"`
+
+	// PodMetrics is the dfdaemon metrics collected from every pod after every download step.
+	PodMetrics []*PodMetrics `json:"pod_metrics"`
+
+	// Timings is the client side timing of every download.
+	Timings []*Timing `json:"timings"`
+
+	// OriginTraffic is the traffic served by the file server for every download step, empty if not counted.
+	OriginTraffic []*OriginTraffic `json:"origin_traffic,omitempty"`
+
+	// ImageResults is the aggregated startup statistics by image.
+	ImageResults []*ImageResult `json:"image_results,omitempty"`
+
+	// Startups is the startup timing of every container.
+	Startups []*Startup `json:"startups,omitempty"`
+
+	// PullResults is the aggregated pull statistics by image.
+	PullResults []*PullResult `json:"pull_results,omitempty"`
+
+	// ImagePulls is the pull timing of every image on every node.
+	ImagePulls []*ImagePull `json:"image_pulls,omitempty"`
+
+	// LoadResults is the aggregated statistics of the loads sent through the dfdaemon proxy.
+	LoadResults []*LoadResult `json:"load_results,omitempty"`
+}
+
+// Result represents the aggregated statistics of the downloads of a file size level by a downloader.
+type Result struct {
+	// Downloader is the downloader used to download the file.
+	Downloader string `json:"downloader"`
+
+	// FileSizeLevel is the file size level of the file.
+	FileSizeLevel backend.FileSizeLevel `json:"file_size_level"`
+
+	// Count is the number of downloads reported by the dfdaemon.
+	Count uint64 `json:"count"`
+
+	// MinCost is the minimum cost of the downloads.
+	MinCost time.Duration `json:"min_cost"`
+
+	// MaxCost is the maximum cost of the downloads.
+	MaxCost time.Duration `json:"max_cost"`
+
+	// AvgCost is the average cost of the downloads.
+	AvgCost time.Duration `json:"avg_cost"`
+
+	// P50Cost is the 50th percentile cost of the downloads.
+	P50Cost time.Duration `json:"p50_cost"`
+
+	// P90Cost is the 90th percentile cost of the downloads.
+	P90Cost time.Duration `json:"p90_cost"`
+
+	// P95Cost is the 95th percentile cost of the downloads.
+	P95Cost time.Duration `json:"p95_cost"`
+
+	// P99Cost is the 99th percentile cost of the downloads.
+	P99Cost time.Duration `json:"p99_cost"`
+
+	// Stddev is the standard deviation of the cost of the downloads.
+	Stddev time.Duration `json:"stddev"`
+
+	// Throughput is the effective throughput of the downloads in bytes per second.
+	Throughput float64 `json:"throughput"`
+
+	// AvgClientCost is the average client side cost of the downloads, zero if not recorded.
+	AvgClientCost time.Duration `json:"avg_client_cost,omitempty"`
+
+	// AvgTTFB is the average time to the first byte of the downloads, zero if not reported.
+	AvgTTFB time.Duration `json:"avg_ttfb,omitempty"`
+
+	// BackToSourceTraffic is the traffic downloaded from the source in bytes.
+	BackToSourceTraffic float64 `json:"back_to_source_traffic"`
+
+	// RemotePeerTraffic is the traffic downloaded from the remote peers in bytes.
+	RemotePeerTraffic float64 `json:"remote_peer_traffic"`
+
+	// LocalPeerTraffic is the traffic downloaded from the local peer in bytes.
+	LocalPeerTraffic float64 `json:"local_peer_traffic"`
+
+	// BackToSourceRate is the percentage of the traffic downloaded from the source.
+	BackToSourceRate float64 `json:"back_to_source_rate"`
+
+	// AvgUploadCost is the average client side cost of importing or putting the file by dfcache or dfstore,
+	// zero if not recorded.
+	AvgUploadCost time.Duration `json:"avg_upload_cost,omitempty"`
+
+	// AvgPreheatCost is the average cost of the preheat jobs before the downloads, zero if not preheated.
+	AvgPreheatCost time.Duration `json:"avg_preheat_cost,omitempty"`
+
+	// OriginTraffic is the traffic served by the file server in bytes, zero if not counted.
+	OriginTraffic float64 `json:"origin_traffic,omitempty"`
+
+	// OriginFetches is the traffic served by the file server per task in the file size, zero if not counted.
+	OriginFetches float64 `json:"origin_fetches,omitempty"`
+
+	// Corrupted is the number of the downloads mismatching the size or the digest of the file server,
+	// zero if not verified.
+	Corrupted uint64 `json:"corrupted,omitempty"`
+
+	// SpeedUp is the ratio of the wall-clock time of the direct downloads to the wall-clock time
+	// of the downloads, zero if there is no direct download.
+	SpeedUp float64 `json:"speed_up,omitempty"`
+}
+
+// CacheResult represents the aggregated statistics of the downloads of a cache state.
+type CacheResult struct {
+	// Cache is the cache state of the downloads [cold, warm, preheated].
+	Cache string `json:"cache"`
+
+	// Result is the aggregated statistics of the downloads of the cache state.
+	*Result
+}
+
+// WaveResult represents the aggregated statistics of the downloads of a wave.
+type WaveResult struct {
+	// Wave is the index of the wave, the first wave is 0.
+	Wave int `json:"wave"`
+
+	// Result is the aggregated statistics of the downloads of the wave.
+	*Result
+}
+
+// Report returns the serializable report of the statistics.
+func (s *stats) Report() (*Report, error) {
+	summaries, err := s.summarize()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		StartedAt:     s.startedAt,
+		FinishedAt:    time.Now(),
+		Config:        s.config,
+		Results:       []*Result{},
+		PodMetrics:    []*PodMetrics{},
+		Timings:       s.GetTimings(),
+		OriginTraffic: s.GetOriginTraffic(),
+		Startups:      s.GetStartups(),
+		ImagePulls:    s.GetImagePulls(),
+	}
+
+	for _, downloader := range downloaders {
+		for _, summary := range summaries[downloader] {
+			report.Results = append(report.Results, summary.result(downloader))
+		}
+
+		for _, summary := range s.summarizeCache(downloader) {
+			report.CacheResults = append(report.CacheResults, &CacheResult{Cache: summary.cache, Result: summary.result(downloader)})
+		}
+
+		for _, summary := range s.summarizeWaves(downloader) {
+			report.WaveResults = append(report.WaveResults, &WaveResult{Wave: summary.wave, Result: summary.result(downloader)})
+		}
+	}
+
+	report.FaultResults = s.faultResults()
+	for _, download := range s.GetDownloads() {
+		pm, err := download.podMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		report.PodMetrics = append(report.PodMetrics, pm)
+	}
+
+	slices.SortFunc(report.PodMetrics, func(a, b *PodMetrics) int {
+		return a.CollectedAt.Compare(b.CollectedAt)
+	})
+
+	slices.SortFunc(report.Timings, func(a, b *Timing) int {
+		return strings.Compare(a.PodName, b.PodName)
+	})
+
+	slices.SortStableFunc(report.OriginTraffic, func(a, b *OriginTraffic) int {
+		if c := strings.Compare(a.Downloader, b.Downloader); c != 0 {
+			return c
+		}
+
+		return strings.Compare(string(a.FileSizeLevel), string(b.FileSizeLevel))
+	})
+
+	report.ImageResults = imageResults(s.config.Nydus.Images, report.Startups)
+	sortStartups(report.Startups)
+
+	if report.PullResults, err = s.pullResults(); err != nil {
+		return nil, err
+	}
+	sortImagePulls(report.ImagePulls)
+
+	if report.LoadResults, err = s.loadResults(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// result returns the serializable result of the summary.
+func (s *summary) result(downloader string) *Result {
+	avgClientCost, _ := s.avgClientCost()
+	avgTTFB, _ := s.avgTTFB()
+	avgUploadCost, _ := average(s.uploadCosts)
+	avgPreheatCost, _ := average(s.preheatCosts)
+	originFetches, _ := s.originFetches()
+	return &Result{
+		Downloader:          downloader,
+		FileSizeLevel:       s.fileSizeLevel,
+		Count:               s.count,
+		MinCost:             s.minCost(),
+		MaxCost:             s.maxCost(),
+		AvgCost:             s.avgCost(),
+		P50Cost:             s.percentile(50),
+		P90Cost:             s.percentile(90),
+		P95Cost:             s.percentile(95),
+		P99Cost:             s.percentile(99),
+		Stddev:              s.stddev(),
+		Throughput:          s.throughput(),
+		AvgClientCost:       avgClientCost,
+		AvgTTFB:             avgTTFB,
+		BackToSourceTraffic: s.backToSourceTraffic,
+		RemotePeerTraffic:   s.remotePeerTraffic,
+		LocalPeerTraffic:    s.localPeerTraffic,
+		BackToSourceRate:    s.backToSourceRate(),
+		AvgUploadCost:       avgUploadCost,
+		AvgPreheatCost:      avgPreheatCost,
+		OriginTraffic:       s.originTraffic,
+		OriginFetches:       originFetches,
+		Corrupted:           s.corrupted,
+		SpeedUp:             s.speedUp,
+	}
+}
+
+// writeJSON writes the report in JSON format.
+func writeJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeCSV writes the results of the report in CSV format, the costs are in milliseconds.
+func writeCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"downloader", "file_size_level", "count", "min_cost_ms", "max_cost_ms", "avg_cost_ms",
+		"p50_cost_ms", "p90_cost_ms", "p95_cost_ms", "p99_cost_ms", "stddev_ms", "throughput_bytes_per_second",
+		"avg_client_cost_ms", "avg_ttfb_ms", "back_to_source_traffic_bytes", "remote_peer_traffic_bytes",
+		"local_peer_traffic_bytes", "back_to_source_rate", "avg_upload_cost_ms", "avg_preheat_cost_ms", "origin_traffic_bytes", "origin_fetches", "corrupted", "speed_up",
+	}); err != nil {
+		return err
+	}
+
+	for _, result := range report.Results {
+		if err := writer.Write([]string{
+			result.Downloader,
+			string(result.FileSizeLevel),
+			strconv.FormatUint(result.Count, 10),
+			formatMilliseconds(result.MinCost),
+			formatMilliseconds(result.MaxCost),
+			formatMilliseconds(result.AvgCost),
+			formatMilliseconds(result.P50Cost),
+			formatMilliseconds(result.P90Cost),
+			formatMilliseconds(result.P95Cost),
+			formatMilliseconds(result.P99Cost),
+			formatMilliseconds(result.Stddev),
+			strconv.FormatFloat(result.Throughput, 'f', 2, 64),
+			formatMilliseconds(result.AvgClientCost),
+			formatMilliseconds(result.AvgTTFB),
+			strconv.FormatFloat(result.BackToSourceTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.RemotePeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.LocalPeerTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.BackToSourceRate, 'f', 2, 64),
+			formatMilliseconds(result.AvgUploadCost),
+			formatMilliseconds(result.AvgPreheatCost),
+			strconv.FormatFloat(result.OriginTraffic, 'f', 0, 64),
+			strconv.FormatFloat(result.OriginFetches, 'f', 2, 64),
+			strconv.FormatUint(result.Corrupted, 10),
+			strconv.FormatFloat(result.SpeedUp, 'f', 2, 64),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatMilliseconds formats the duration in milliseconds without the unit.
+func formatMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+}
@@ -0,0 +1,177 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+)
+
+// testMetrics is the dfdaemon metrics of two downloads of the task size level 1.
+const testMetrics = `# TYPE dragonfly_client_download_task_duration_milliseconds histogram
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="10"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="100"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="+Inf"} 2
+dragonfly_client_download_task_duration_milliseconds_sum{task_size_level="1"} 60
+dragonfly_client_download_task_duration_milliseconds_count{task_size_level="1"} 2
+# TYPE dragonfly_client_download_traffic counter
+dragonfly_client_download_traffic{type="BACK_TO_SOURCE",task_type="STANDARD"} 10240
+dragonfly_client_download_traffic{type="REMOTE_PEER",task_type="STANDARD"} 10240
+`
+
+// newTestStats returns the statistics of two dfget downloads of the micro file collected from a pod.
+func newTestStats(t *testing.T, cfg *config.Config) *stats {
+	t.Helper()
+
+	s := New(cfg, nil).(*stats)
+	s.downloads.Store("download", &Download{
+		podName:        "client-0",
+		downloader:     config.DownloaderDfget,
+		fileSizeLevel:  backend.FileSizeLevelMicro,
+		collectedAt:    time.Now(),
+		metricFamilies: parseMetricFamilies(t, testMetrics),
+	})
+
+	for _, elapsed := range []time.Duration{20 * time.Millisecond, 80 * time.Millisecond} {
+		s.AddTiming(&Timing{PodName: "client-0", Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: elapsed})
+	}
+
+	return s
+}
+
+func TestPrettyPrint(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		columns []string
+		check   func(t *testing.T, output string)
+		wantErr bool
+	}{
+		{
+			name:   "table",
+			format: config.OutputFormatTable,
+			check: func(t *testing.T, output string) {
+				for _, s := range []string{"TIMES", "P99 COST", backend.FileSizeLevelMicro.String(), "50.00%"} {
+					if !strings.Contains(output, s) {
+						t.Errorf("table does not contain %q:\n%s", s, output)
+					}
+				}
+			},
+		},
+		{
+			name:    "markdown with selected columns",
+			format:  config.OutputFormatMarkdown,
+			columns: []string{"times", "avg"},
+			check: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				if lines[0] != "### DFGET" {
+					t.Errorf("markdown title = %q, want %q", lines[0], "### DFGET")
+				}
+
+				if !strings.Contains(output, " 2 ") || !strings.Contains(output, "30.00ms") || strings.Contains(output, "P99") {
+					t.Errorf("markdown is not the times and the average cost of 30ms:\n%s", output)
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: config.OutputFormatJSON,
+			check: func(t *testing.T, output string) {
+				var report Report
+				if err := json.Unmarshal([]byte(output), &report); err != nil {
+					t.Fatalf("failed to decode report: %v", err)
+				}
+
+				if len(report.Results) != 1 || len(report.PodMetrics) != 1 || len(report.Timings) != 2 {
+					t.Fatalf("report has %d results, %d pod metrics and %d timings, want 1, 1 and 2",
+						len(report.Results), len(report.PodMetrics), len(report.Timings))
+				}
+
+				result := report.Results[0]
+				if result.Downloader != config.DownloaderDfget || result.Count != 2 || result.AvgCost != 30*time.Millisecond ||
+					result.AvgClientCost != 50*time.Millisecond || result.BackToSourceRate != 50 {
+					t.Errorf("result = %+v, want 2 dfget downloads of 30ms, client cost of 50ms and back-to-source rate of 50%%", result)
+				}
+
+				if report.Config.Report.Format != config.OutputFormatJSON {
+					t.Errorf("report config format = %q, want %q", report.Config.Report.Format, config.OutputFormatJSON)
+				}
+			},
+		},
+		{
+			name:   "csv",
+			format: config.OutputFormatCSV,
+			check: func(t *testing.T, output string) {
+				records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+				if err != nil {
+					t.Fatalf("failed to read csv: %v", err)
+				}
+
+				if len(records) != 2 {
+					t.Fatalf("got %d records, want the header and a result", len(records))
+				}
+
+				for i, expected := range map[int]string{0: "dfget", 1: "micro", 2: "2", 5: "30.00", 12: "50.00", 17: "50.00"} {
+					if records[1][i] != expected {
+						t.Errorf("%s = %q, want %q", records[0][i], records[1][i], expected)
+					}
+				}
+			},
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Report.Format = tt.format
+			cfg.Report.Columns = tt.columns
+			cfg.Report.File = filepath.Join(t.TempDir(), "report")
+
+			err := newTestStats(t, cfg).PrettyPrint()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PrettyPrint succeeded, want error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("PrettyPrint failed: %v", err)
+			}
+
+			output, err := os.ReadFile(cfg.Report.File)
+			if err != nil {
+				t.Fatalf("failed to read report: %v", err)
+			}
+
+			tt.check(t, string(output))
+		})
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+)
+
+// Startup represents the startup timing of a container from a lazily loaded image on a node.
+type Startup struct {
+	// PodName is the name of the pod running the container on the node.
+	PodName string `json:"pod_name"`
+
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Pull is the time to pull the image.
+	Pull time.Duration `json:"pull"`
+
+	// Ready is the time from the start of the pull to the container is started.
+	Ready time.Duration `json:"ready"`
+
+	// Fetched is the data fetched on demand from the backend until the container is started in bytes.
+	Fetched uint64 `json:"fetched"`
+}
+
+// ImageResult represents the aggregated startup statistics of an image.
+type ImageResult struct {
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Count is the number of the container startups.
+	Count uint64 `json:"count"`
+
+	// AvgPull is the average time to pull the image.
+	AvgPull time.Duration `json:"avg_pull"`
+
+	// MinReady is the minimum time from the pull to the container is started.
+	MinReady time.Duration `json:"min_ready"`
+
+	// MaxReady is the maximum time from the pull to the container is started.
+	MaxReady time.Duration `json:"max_ready"`
+
+	// AvgReady is the average time from the pull to the container is started.
+	AvgReady time.Duration `json:"avg_ready"`
+
+	// P50Ready is the 50th percentile time from the pull to the container is started.
+	P50Ready time.Duration `json:"p50_ready"`
+
+	// P90Ready is the 90th percentile time from the pull to the container is started.
+	P90Ready time.Duration `json:"p90_ready"`
+
+	// P99Ready is the 99th percentile time from the pull to the container is started.
+	P99Ready time.Duration `json:"p99_ready"`
+
+	// AvgFetched is the average data fetched on demand until the container is started in bytes.
+	AvgFetched uint64 `json:"avg_fetched"`
+}
+
+// GetStartups returns the startup timings of the containers.
+func (s *stats) GetStartups() []*Startup {
+	startups := []*Startup{}
+	s.startups.Range(func(key, value interface{}) bool {
+		startups = append(startups, value.(*Startup))
+		return true
+	})
+
+	return startups
+}
+
+// AddStartup adds the startup timing of a container.
+func (s *stats) AddStartup(startup *Startup) {
+	s.startups.Store(uuid.New().String(), startup)
+}
+
+// imageResults aggregates the startups by image in the order of the images.
+func imageResults(images []string, startups []*Startup) []*ImageResult {
+	byImage := make(map[string][]*Startup)
+	for _, startup := range startups {
+		byImage[startup.Image] = append(byImage[startup.Image], startup)
+	}
+
+	results := make([]*ImageResult, 0, len(images))
+	for _, image := range images {
+		if len(byImage[image]) == 0 {
+			continue
+		}
+
+		var (
+			pulls, readies []time.Duration
+			fetched        uint64
+		)
+		for _, startup := range byImage[image] {
+			pulls = append(pulls, startup.Pull)
+			readies = append(readies, startup.Ready)
+			fetched += startup.Fetched
+		}
+		slices.Sort(readies)
+
+		avgPull, _ := average(pulls)
+		avgReady, _ := average(readies)
+		results = append(results, &ImageResult{
+			Image:      image,
+			Count:      uint64(len(readies)),
+			AvgPull:    avgPull,
+			MinReady:   readies[0],
+			MaxReady:   readies[len(readies)-1],
+			AvgReady:   avgReady,
+			P50Ready:   percentile(readies, 50),
+			P90Ready:   percentile(readies, 90),
+			P99Ready:   percentile(readies, 99),
+			AvgFetched: fetched / uint64(len(readies)),
+		})
+	}
+
+	return results
+}
+
+// printImageTable prints the image startup statistics in a table format.
+func printImageTable(w io.Writer, results []*ImageResult, markdown bool) error {
+	table := newTable(w, markdown)
+	table.Header([]string{"IMAGE", "TIMES", "AVG PULL", "MIN READY", "MAX READY", "AVG READY", "P50 READY", "P90 READY", "P99 READY", "AVG FETCHED"})
+	for _, result := range results {
+		if err := table.Append([]string{
+			result.Image,
+			strconv.FormatUint(result.Count, 10),
+			formatDuration(result.AvgPull),
+			formatDuration(result.MinReady),
+			formatDuration(result.MaxReady),
+			formatDuration(result.AvgReady),
+			formatDuration(result.P50Ready),
+			formatDuration(result.P90Ready),
+			formatDuration(result.P99Ready),
+			humanize.Bytes(result.AvgFetched),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// writeImageCSV writes the image results of the report in CSV format, the times are in milliseconds.
+func writeImageCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"image", "count", "avg_pull_ms", "min_ready_ms", "max_ready_ms", "avg_ready_ms",
+		"p50_ready_ms", "p90_ready_ms", "p99_ready_ms", "avg_fetched_bytes",
+	}); err != nil {
+		return err
+	}
+
+	for _, result := range report.ImageResults {
+		if err := writer.Write([]string{
+			result.Image,
+			strconv.FormatUint(result.Count, 10),
+			formatMilliseconds(result.AvgPull),
+			formatMilliseconds(result.MinReady),
+			formatMilliseconds(result.MaxReady),
+			formatMilliseconds(result.AvgReady),
+			formatMilliseconds(result.P50Ready),
+			formatMilliseconds(result.P90Ready),
+			formatMilliseconds(result.P99Ready),
+			strconv.FormatUint(result.AvgFetched, 10),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// sortStartups sorts the startups by image and pod name.
+func sortStartups(startups []*Startup) {
+	slices.SortStableFunc(startups, func(a, b *Startup) int {
+		if c := strings.Compare(a.Image, b.Image); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.PodName, b.PodName)
+	})
+}
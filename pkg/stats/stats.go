@@ -18,47 +18,130 @@ package stats
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"math"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/dragonflyoss/perf-tests/pkg/backend"
 	"github.com/dragonflyoss/perf-tests/pkg/config"
-	"github.com/dragonflyoss/perf-tests/pkg/util"
+	"github.com/dragonflyoss/perf-tests/pkg/executor"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/google/uuid"
 	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/renderer"
+	"github.com/olekukonko/tablewriter/tw"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// cacheCold is the cache state of the downloads of the new tasks.
+	cacheCold = "cold"
+
+	// cacheWarm is the cache state of the downloads reusing the tasks of the previous iteration.
+	cacheWarm = "warm"
+
+	// cachePreheated is the cache state of the downloads of the preheated tasks.
+	cachePreheated = "preheated"
+)
+
 // Stats represents the statistics of the benchmark.
 type Stats interface {
 	// GetDownloads returns the download statistics.
 	GetDownloads() []*Download
 
-	// CollectClientMetrics collects the client metrics and resets the metrics.
-	CollectClientMetrics(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel) error
+	// GetTimings returns the client side timings of the downloads.
+	GetTimings() []*Timing
+
+	// AddTiming adds the client side timing of a download.
+	AddTiming(timing *Timing)
+
+	// GetStartups returns the startup timings of the containers.
+	GetStartups() []*Startup
+
+	// AddStartup adds the startup timing of a container.
+	AddStartup(startup *Startup)
+
+	// CollectClientMetrics collects the client metrics and resets the metrics, warm is true if
+	// the downloads reuse the tasks of the previous iteration, and preheated is true if the tasks
+	// are preheated before the downloads.
+	CollectClientMetrics(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, warm, preheated bool) error
+
+	// GetImagePulls returns the pull timings of the images.
+	GetImagePulls() []*ImagePull
+
+	// AddImagePull adds the pull timing of an image.
+	AddImagePull(pull *ImagePull)
+
+	// CollectImageMetrics collects the client metrics of the image pulls and resets the metrics, warm is
+	// true if the pulls are served by the tasks of the previous pulls.
+	CollectImageMetrics(ctx context.Context, image string, warm bool) error
+
+	// GetLoads returns the loads sent through the dfdaemon proxy.
+	GetLoads() []*Load
+
+	// AddLoad adds a load sent through the dfdaemon proxy.
+	AddLoad(load *Load)
+
+	// CollectLoadMetrics collects the client metrics of the load and resets the metrics.
+	CollectLoadMetrics(ctx context.Context, mode string) error
+
+	// GetOriginTraffic returns the traffic served by the file server for the download steps.
+	GetOriginTraffic() []*OriginTraffic
+
+	// AddOriginTraffic adds the traffic served by the file server for a download step.
+	AddOriginTraffic(traffic *OriginTraffic)
 
 	// ResetClientMetrics resets the client metrics.
 	ResetClientMetrics(ctx context.Context) error
 
-	// PrettyPrint prints the statistics in a pretty format.
+	// Report returns the serializable report of the statistics.
+	Report() (*Report, error)
+
+	// PrettyPrint prints the statistics in the configured output format.
 	PrettyPrint() error
 }
 
 // stats implements the Stats interface.
 type stats struct {
+	// config is the configuration of the benchmark.
+	config *config.Config
+
+	// startedAt is the time when the benchmark is started.
+	startedAt time.Time
+
 	// downloads stores the download statistics.
 	downloads *sync.Map
 
-	// namespace is the namespace of the benchmark.
-	namespace string
+	// timings stores the client side timings of the downloads.
+	timings *sync.Map
+
+	// startups stores the startup timings of the containers.
+	startups *sync.Map
+
+	// imagePulls stores the pull timings of the images.
+	imagePulls *sync.Map
+
+	// loads stores the loads sent through the dfdaemon proxy.
+	loads *sync.Map
+
+	// originTraffic stores the traffic served by the file server for the download steps.
+	originTraffic *sync.Map
+
+	// executor is the executor to run commands in the client pods.
+	executor executor.Executor
 }
 
 // Download represents the download statistics.
@@ -72,13 +155,90 @@ type Download struct {
 	// fileSizeLevel is the file size level of the file.
 	fileSizeLevel backend.FileSizeLevel
 
+	// warm is true if the downloads reuse the tasks of the previous iteration.
+	warm bool
+
+	// preheated is true if the tasks are preheated before the downloads.
+	preheated bool
+
+	// image is the image pulled by the downloads, empty if the downloads are not image pulls.
+	image string
+
+	// load is the mode of the load sent through the proxy, empty if the downloads are not a load.
+	load string
+
+	// collectedAt is the time when the metrics are collected.
+	collectedAt time.Time
+
 	// metricFamilies is the metric families of the download.
 	metricFamilies map[string]*dto.MetricFamily
 }
 
+// Timing represents the client side timing of a download, measured independently from the dfdaemon metrics.
+type Timing struct {
+	// PodName is the name of the pod.
+	PodName string `json:"pod_name"`
+
+	// Downloader is the downloader used to download the file.
+	Downloader string `json:"downloader"`
+
+	// FileSizeLevel is the file size level of the file.
+	FileSizeLevel backend.FileSizeLevel `json:"file_size_level"`
+
+	// Elapsed is the wall-clock time of the download command measured by dfbench, including the exec overhead.
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Connect is the time to establish the connection reported by curl, zero if not reported.
+	Connect time.Duration `json:"connect,omitempty"`
+
+	// TTFB is the time to the first byte reported by curl, zero if not reported.
+	TTFB time.Duration `json:"ttfb,omitempty"`
+
+	// Total is the total time of the transfer reported by curl, zero if not reported.
+	Total time.Duration `json:"total,omitempty"`
+
+	// Range is the byte range of the download by the fixed and random range modes, empty if the whole
+	// file or the chunks of the sequential range mode are downloaded.
+	Range string `json:"range,omitempty"`
+
+	// Wave is the index of the wave the pod is started in by the start pattern.
+	Wave int `json:"wave"`
+
+	// Warm is true if the download reuses the task of the previous iteration.
+	Warm bool `json:"warm"`
+
+	// Upload is true if the timing is of importing or putting the file before the downloads by dfcache or dfstore.
+	Upload bool `json:"upload,omitempty"`
+
+	// Preheated is true if the task is preheated before the download.
+	Preheated bool `json:"preheated,omitempty"`
+
+	// Preheat is true if the timing is of the preheat job of the tasks before the downloads.
+	Preheat bool `json:"preheat,omitempty"`
+
+	// Corrupted is true if the size or the digest of the downloaded file mismatches the file server.
+	Corrupted bool `json:"corrupted,omitempty"`
+
+	// Faulty is true if the responses of the task are injected with the fault of the file server.
+	Faulty bool `json:"faulty,omitempty"`
+
+	// Failed is true if the download of the faulty task fails, the elapsed time is until the failure.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// Cost returns the client side cost of the download, the total time reported by curl
+// is preferred as it excludes the exec overhead.
+func (t *Timing) Cost() time.Duration {
+	if t.Total > 0 {
+		return t.Total
+	}
+
+	return t.Elapsed
+}
+
 // New creates a new Stats instance.
-func New(namespace string) Stats {
-	return &stats{downloads: &sync.Map{}, namespace: namespace}
+func New(config *config.Config, executor executor.Executor) Stats {
+	return &stats{config: config, startedAt: time.Now(), downloads: &sync.Map{}, timings: &sync.Map{}, startups: &sync.Map{}, imagePulls: &sync.Map{}, loads: &sync.Map{}, originTraffic: &sync.Map{}, executor: executor}
 }
 
 // GetDownloads returns the download statistics.
@@ -92,8 +252,39 @@ func (s *stats) GetDownloads() []*Download {
 	return downloads
 }
 
-// collectClientMetrics collects the client metrics.
-func (s *stats) CollectClientMetrics(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel) error {
+// GetTimings returns the client side timings of the downloads.
+func (s *stats) GetTimings() []*Timing {
+	timings := []*Timing{}
+	s.timings.Range(func(key, value interface{}) bool {
+		timings = append(timings, value.(*Timing))
+		return true
+	})
+
+	return timings
+}
+
+// AddTiming adds the client side timing of a download.
+func (s *stats) AddTiming(timing *Timing) {
+	s.timings.Store(uuid.New().String(), timing)
+}
+
+// CollectClientMetrics collects the client metrics and resets the metrics.
+func (s *stats) CollectClientMetrics(ctx context.Context, downloader string, fileSizeLevel backend.FileSizeLevel, warm, preheated bool) error {
+	return s.collectClientMetrics(ctx, func(pod string) *Download {
+		return &Download{podName: pod, downloader: downloader, fileSizeLevel: fileSizeLevel, warm: warm, preheated: preheated}
+	})
+}
+
+// CollectImageMetrics collects the client metrics of the image pulls and resets the metrics.
+func (s *stats) CollectImageMetrics(ctx context.Context, image string, warm bool) error {
+	return s.collectClientMetrics(ctx, func(pod string) *Download {
+		return &Download{podName: pod, image: image, warm: warm}
+	})
+}
+
+// collectClientMetrics collects the client metrics of all client pods into the downloads created
+// by newDownload and resets the metrics.
+func (s *stats) collectClientMetrics(ctx context.Context, newDownload func(pod string) *Download) error {
 	clientPods, err := s.getClientPods(ctx)
 	if err != nil {
 		logrus.Errorf("failed to get client pods: %v", err)
@@ -108,19 +299,17 @@ func (s *stats) CollectClientMetrics(ctx context.Context, downloader string, fil
 		}
 		reader := bytes.NewReader(data)
 
-		parser := expfmt.TextParser{}
+		parser := expfmt.NewTextParser(model.UTF8Validation)
 		metricFamilies, err := parser.TextToMetricFamilies(reader)
 		if err != nil {
 			logrus.Errorf("failed to parse metrics: %v", err)
 			return err
 		}
 
-		s.downloads.Store(uuid.New().String(), &Download{
-			podName:        pod,
-			downloader:     downloader,
-			fileSizeLevel:  fileSizeLevel,
-			metricFamilies: metricFamilies,
-		})
+		download := newDownload(pod)
+		download.collectedAt = time.Now()
+		download.metricFamilies = metricFamilies
+		s.downloads.Store(uuid.New().String(), download)
 
 		if err := s.resetClientMetrics(ctx, pod); err != nil {
 			logrus.Errorf("failed to reset client metrics: %v", err)
@@ -151,8 +340,8 @@ func (s *stats) ResetClientMetrics(ctx context.Context) error {
 
 // getClientMetrics collects the client metrics by pod name
 func (s *stats) getClientMetrics(ctx context.Context, name string) ([]byte, error) {
-	podExec := util.NewPodExec(s.namespace, name, "client")
-	output, err := podExec.Command(ctx, "sh", "-c", "curl -s http://127.0.0.1:4002/metrics").CombinedOutput()
+	metricsURL := fmt.Sprintf("http://%s/metrics", s.executor.GetEndpoint(name).MetricsAddr)
+	output, err := s.executor.Exec(ctx, name, "sh", "-c", fmt.Sprintf("curl -s %s", metricsURL))
 	if err != nil {
 		logrus.Errorf("failed to cleanup: %v \nmessage: %s", err, string(output))
 		return nil, err
@@ -163,8 +352,8 @@ func (s *stats) getClientMetrics(ctx context.Context, name string) ([]byte, erro
 
 // resetClientMetrics resets the client metrics by pod name
 func (s *stats) resetClientMetrics(ctx context.Context, name string) error {
-	podExec := util.NewPodExec(s.namespace, name, "client")
-	output, err := podExec.Command(ctx, "sh", "-c", "curl -s -X DELETE http://127.0.0.1:4002/metrics").CombinedOutput()
+	metricsURL := fmt.Sprintf("http://%s/metrics", s.executor.GetEndpoint(name).MetricsAddr)
+	output, err := s.executor.Exec(ctx, name, "sh", "-c", fmt.Sprintf("curl -s -X DELETE %s", metricsURL))
 	if err != nil {
 		logrus.Errorf("failed to cleanup: %v \nmessage: %s", err, string(output))
 		return err
@@ -175,7 +364,7 @@ func (s *stats) resetClientMetrics(ctx context.Context, name string) error {
 
 // getClientPods returns the client pods.
 func (s *stats) getClientPods(ctx context.Context) ([]string, error) {
-	pods, err := util.GetPods(ctx, s.namespace, "component=client")
+	pods, err := s.executor.GetPods(ctx, "component=client")
 	if err != nil {
 		logrus.Errorf("failed to get pods: %v", err)
 		return nil, err
@@ -189,120 +378,672 @@ func (s *stats) getClientPods(ctx context.Context) ([]string, error) {
 	return pods, nil
 }
 
-// PrettyPrint prints the statistics in a pretty format.
+// PrettyPrint prints the statistics in the configured output format, to the output file if specified.
 func (s *stats) PrettyPrint() error {
-	downloads := s.GetDownloads()
-	proxyDownloads := make(map[backend.FileSizeLevel][]*Download)
-	dfgetDownloads := make(map[backend.FileSizeLevel][]*Download)
-	for _, download := range downloads {
-		switch download.downloader {
-		case config.DownloaderDfget:
-			dfgetDownloads[download.fileSizeLevel] = append(dfgetDownloads[download.fileSizeLevel], download)
-		case config.DownloaderProxy:
-			proxyDownloads[download.fileSizeLevel] = append(proxyDownloads[download.fileSizeLevel], download)
+	w := io.Writer(os.Stdout)
+	if s.config.Report.File != "" {
+		file, err := os.Create(s.config.Report.File)
+		if err != nil {
+			return err
 		}
+		defer file.Close()
+
+		w = file
 	}
 
-	if len(dfgetDownloads) != 0 {
-		if err := printTable(dfgetDownloads); err != nil {
+	switch s.config.Report.Format {
+	case config.OutputFormatTable, "":
+		return s.printTables(w, false)
+	case config.OutputFormatMarkdown:
+		return s.printTables(w, true)
+	case config.OutputFormatJSON:
+		report, err := s.Report()
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, report)
+	case config.OutputFormatCSV:
+		report, err := s.Report()
+		if err != nil {
 			return err
 		}
+
+		if len(report.ImageResults) > 0 {
+			return writeImageCSV(w, report)
+		}
+
+		if len(report.PullResults) > 0 {
+			return writePullCSV(w, report)
+		}
+
+		if len(report.LoadResults) > 0 {
+			return writeLoadCSV(w, report)
+		}
+
+		return writeCSV(w, report)
+	default:
+		return fmt.Errorf("unknown output format %q", s.config.Report.Format)
 	}
+}
 
-	if len(proxyDownloads) != 0 {
-		if err := printTable(proxyDownloads); err != nil {
+// printTables prints a statistics table per downloader.
+func (s *stats) printTables(w io.Writer, markdown bool) error {
+	summaries, err := s.summarize()
+	if err != nil {
+		return err
+	}
+
+	// Show the upload cost of dfcache and dfstore, and the speed-up against the direct downloads by default.
+	names := s.config.Report.Columns
+	if len(names) == 0 {
+		names = slices.Clone(DefaultColumns)
+		if len(summaries[config.DownloaderDfcache]) > 0 || len(summaries[config.DownloaderDfstore]) > 0 {
+			names = append(names, "upload")
+		}
+
+		if len(summaries[config.DownloaderDirect]) > 0 {
+			names = append(names, "speed-up")
+		}
+
+		if s.config.Dragonfly.Preheat {
+			names = append(names, "preheat")
+		}
+
+		if s.config.Dragonfly.Verify {
+			names = append(names, "corrupted")
+		}
+
+		if s.config.Dragonfly.OriginTraffic {
+			names = append(names, "origin-traffic", "origin-fetches", "origin-diff")
+		}
+	}
+
+	for _, downloader := range downloaders {
+		if len(summaries[downloader]) == 0 {
+			continue
+		}
+
+		if markdown {
+			if _, err := fmt.Fprintf(w, "### %s\n\n", strings.ToUpper(downloader)); err != nil {
+				return err
+			}
+		} else if len(summaries) > 1 {
+			if _, err := fmt.Fprintf(w, "%s\n", strings.ToUpper(downloader)); err != nil {
+				return err
+			}
+		}
+
+		if err := printTable(w, summaries[downloader], names, markdown, byFileSizeLevel); err != nil {
 			return err
 		}
+
+		if cacheSummaries := s.summarizeCache(downloader); len(cacheSummaries) > 0 {
+			if markdown {
+				if _, err := fmt.Fprintf(w, "\n#### %s by cache\n\n", strings.ToUpper(downloader)); err != nil {
+					return err
+				}
+			}
+
+			if err := printTable(w, cacheSummaries, names, markdown, byCache); err != nil {
+				return err
+			}
+		}
+
+		if waveSummaries := s.summarizeWaves(downloader); len(waveSummaries) > 0 {
+			if markdown {
+				if _, err := fmt.Fprintf(w, "\n#### %s by wave\n\n", strings.ToUpper(downloader)); err != nil {
+					return err
+				}
+			}
+
+			if err := printTable(w, waveSummaries, names, markdown, byWave); err != nil {
+				return err
+			}
+		}
+
+		faultResults := slices.DeleteFunc(s.faultResults(), func(result *FaultResult) bool { return result.Downloader != downloader })
+		if len(faultResults) > 0 {
+			if markdown {
+				if _, err := fmt.Fprintf(w, "\n#### %s by fault\n\n", strings.ToUpper(downloader)); err != nil {
+					return err
+				}
+			}
+
+			if err := printFaultTable(w, faultResults, markdown); err != nil {
+				return err
+			}
+		}
+
+		if markdown {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	if results := imageResults(s.config.Nydus.Images, s.GetStartups()); len(results) > 0 {
+		if markdown {
+			if _, err := fmt.Fprint(w, "### NYDUS\n\n"); err != nil {
+				return err
+			}
+		}
+
+		if err := printImageTable(w, results, markdown); err != nil {
+			return err
+		}
+	}
+
+	pullResults, err := s.pullResults()
+	if err != nil {
+		return err
+	}
+
+	if len(pullResults) > 0 {
+		if markdown {
+			if _, err := fmt.Fprint(w, "### IMAGE\n\n"); err != nil {
+				return err
+			}
+		}
+
+		if err := printPullTable(w, pullResults, markdown, s.config.Image.Preheat); err != nil {
+			return err
+		}
+
+		if markdown {
+			if _, err := fmt.Fprint(w, "\n#### IMAGE by layer\n\n"); err != nil {
+				return err
+			}
+		}
+
+		if err := printLayerTable(w, pullResults, markdown); err != nil {
+			return err
+		}
+	}
+
+	loadResults, err := s.loadResults()
+	if err != nil {
+		return err
+	}
+
+	if len(loadResults) == 0 {
+		return nil
+	}
+
+	if markdown {
+		if _, err := fmt.Fprint(w, "### PROXY LOAD\n\n"); err != nil {
+			return err
+		}
+	}
+
+	if err := printLoadTable(w, loadResults, markdown); err != nil {
+		return err
+	}
+
+	if markdown {
+		if _, err := fmt.Fprint(w, "\n#### PROXY LOAD by latency\n\n"); err != nil {
+			return err
+		}
+	}
+
+	return printHistogramTable(w, loadResults, markdown)
 }
 
-// printTable prints the download statistics in a table format.
-func printTable(downloads map[backend.FileSizeLevel][]*Download) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.Header([]string{"File Size Level", "Times", "Min Cost", "Max Cost", "Avg Cost", "Back To Source Traffic", "Remote Peer Traffic", "Local Peer Traffic", "Back To Source Rate"})
-
-	rows := map[backend.FileSizeLevel][]string{}
-	for fileSizeLevel, records := range downloads {
-		maxCost := time.Duration(-math.MaxInt64)
-		minCost := time.Duration(math.MaxInt64)
-
-		var (
-			n                                                        uint64
-			totalCost                                                time.Duration
-			backToSourceTraffic, remotePeerTraffic, localPeerTraffic float64
-		)
-		for _, record := range records {
-			for name, mf := range record.metricFamilies {
-				switch name {
-				case "dragonfly_client_download_traffic":
-					for _, metrics := range mf.GetMetric() {
-						for _, label := range metrics.GetLabel() {
-							if *label.Name == "type" {
-								switch *label.Value {
-								case "BACK_TO_SOURCE":
-									backToSourceTraffic += metrics.GetCounter().GetValue()
-								case "REMOTE_PEER":
-									remotePeerTraffic += metrics.GetCounter().GetValue()
-								case "LOCAL_PEER":
-									localPeerTraffic += metrics.GetCounter().GetValue()
-								default:
-									fmt.Printf("invalid traffic type: %s\n", *label.Value)
-									return errors.New("invalid traffic type")
-								}
-							}
-						}
-					}
-				case "dragonfly_client_download_task_duration_milliseconds":
-					for _, metrics := range mf.GetMetric() {
-						for _, label := range metrics.GetLabel() {
-							if *label.Name == "task_size_level" && *label.Value == fileSizeLevel.TaskSizeLevel() {
-								if metrics.GetHistogram().GetSampleCount() != 1 {
-									return errors.New("invalid sample count")
-								}
-
-								cost := time.Duration(int64(metrics.GetHistogram().GetSampleSum()) * int64(time.Millisecond))
-								totalCost += cost
-								n += metrics.GetHistogram().GetSampleCount()
-
-								if cost < minCost {
-									minCost = cost
-								}
-
-								if cost > maxCost {
-									maxCost = cost
-								}
-							}
-						}
-					}
+// summarize aggregates the downloads and the client side timings by downloader and file size level.
+func (s *stats) summarize() (map[string][]*summary, error) {
+	downloads := make(map[string]map[backend.FileSizeLevel][]*Download)
+	for _, download := range s.GetDownloads() {
+		if downloads[download.downloader] == nil {
+			downloads[download.downloader] = make(map[backend.FileSizeLevel][]*Download)
+		}
+
+		downloads[download.downloader][download.fileSizeLevel] = append(downloads[download.downloader][download.fileSizeLevel], download)
+	}
+
+	timings := make(map[string]map[backend.FileSizeLevel][]*Timing)
+	for _, timing := range s.GetTimings() {
+		if timings[timing.Downloader] == nil {
+			timings[timing.Downloader] = make(map[backend.FileSizeLevel][]*Timing)
+		}
+
+		timings[timing.Downloader][timing.FileSizeLevel] = append(timings[timing.Downloader][timing.FileSizeLevel], timing)
+	}
+
+	originTraffic := make(map[string]map[backend.FileSizeLevel][]*OriginTraffic)
+	for _, traffic := range s.GetOriginTraffic() {
+		if originTraffic[traffic.Downloader] == nil {
+			originTraffic[traffic.Downloader] = make(map[backend.FileSizeLevel][]*OriginTraffic)
+		}
+
+		originTraffic[traffic.Downloader][traffic.FileSizeLevel] = append(originTraffic[traffic.Downloader][traffic.FileSizeLevel], traffic)
+	}
+
+	summaries := make(map[string][]*summary)
+	for _, downloader := range downloaders {
+		for _, fileSizeLevel := range sortFileSizeLevels(slices.Concat(slices.Collect(maps.Keys(downloads[downloader])), slices.Collect(maps.Keys(timings[downloader])))) {
+			if len(downloads[downloader][fileSizeLevel]) == 0 && len(timings[downloader][fileSizeLevel]) == 0 {
+				continue
+			}
+
+			summary, err := summarizeDownloads(downloader, fileSizeLevel, downloads[downloader][fileSizeLevel], timings[downloader][fileSizeLevel])
+			if err != nil {
+				logrus.Warnf("failed to summarize %s by %s: %v", fileSizeLevel, downloader, err)
+				continue
+			}
+
+			summary.addOriginTraffic(originTraffic[downloader][fileSizeLevel])
+			reconcileOriginTraffic(downloader, summary)
+			summaries[downloader] = append(summaries[downloader], summary)
+		}
+	}
+
+	// The speed-up is the ratio of the wall-clock time of the direct downloads to the P2P downloads, the
+	// client side costs are not comparable as curl reports the transfer time only while dfget is timed by
+	// dfbench including the exec overhead, so both sides are timed by dfbench the same way.
+	for _, direct := range summaries[config.DownloaderDirect] {
+		directElapsed, _ := direct.avgElapsed()
+		for _, downloader := range downloaders {
+			if downloader == config.DownloaderDirect {
+				continue
+			}
+
+			for _, summary := range summaries[downloader] {
+				if elapsed, ok := summary.avgElapsed(); ok && elapsed > 0 && summary.fileSizeLevel == direct.fileSizeLevel {
+					summary.speedUp = float64(directElapsed) / float64(elapsed)
 				}
 			}
 		}
+	}
+
+	return summaries, nil
+}
+
+// summarizeDownloads aggregates the downloads and the client side timings of the file size level by the
+// downloader. The costs of dfcache and dfstore are not reported by the dfdaemon histogram, so they are
+// aggregated by the client side timings, and all traffic of the direct downloads is from the source.
+func summarizeDownloads(downloader string, fileSizeLevel backend.FileSizeLevel, downloads []*Download, timings []*Timing) (*summary, error) {
+	switch downloader {
+	case config.DownloaderDirect:
+		s, err := newClientSummary(fileSizeLevel, nil, timings)
+		if err != nil {
+			return nil, err
+		}
+
+		s.backToSourceTraffic = float64(fileSizeLevel.Bytes() * s.count)
+		return s, nil
+	case config.DownloaderDfcache, config.DownloaderDfstore:
+		return newClientSummary(fileSizeLevel, downloads, timings)
+	default:
+		return newSummary(fileSizeLevel, downloads, timings)
+	}
+}
+
+// summarizeCache aggregates the downloads and the client side timings of the downloader by file size level
+// and cache state, the cold downloads are followed by the warm and the preheated ones, and nothing is returned
+// for a single cache state.
+func (s *stats) summarizeCache(downloader string) []*summary {
+	downloads := make(map[backend.FileSizeLevel]map[string][]*Download)
+	for _, download := range s.GetDownloads() {
+		if download.downloader != downloader {
+			continue
+		}
 
-		avgCost := totalCost / time.Duration(n)
-		rows[fileSizeLevel] = []string{
-			fileSizeLevel.String(),
-			fmt.Sprintf("%d", len(records)),
-			formatDuration(minCost),
-			formatDuration(maxCost),
-			formatDuration(avgCost),
-			humanize.Bytes(uint64(backToSourceTraffic)),
-			humanize.Bytes(uint64(remotePeerTraffic)),
-			humanize.Bytes(uint64(localPeerTraffic)),
-			fmt.Sprintf("%.2f%%", (backToSourceTraffic)/float64(remotePeerTraffic+localPeerTraffic+backToSourceTraffic)*100),
+		if downloads[download.fileSizeLevel] == nil {
+			downloads[download.fileSizeLevel] = make(map[string][]*Download)
 		}
+
+		cache := cacheState(download.warm, download.preheated)
+		downloads[download.fileSizeLevel][cache] = append(downloads[download.fileSizeLevel][cache], download)
 	}
 
-	for _, fileSizeLevel := range backend.FileSizeLevels {
-		if row, ok := rows[fileSizeLevel]; ok {
-			table.Append(row)
+	timings := make(map[backend.FileSizeLevel]map[string][]*Timing)
+	for _, timing := range s.GetTimings() {
+		if timing.Downloader != downloader {
 			continue
 		}
+
+		if timings[timing.FileSizeLevel] == nil {
+			timings[timing.FileSizeLevel] = make(map[string][]*Timing)
+		}
+
+		cache := cacheState(timing.Warm, timing.Preheated)
+		timings[timing.FileSizeLevel][cache] = append(timings[timing.FileSizeLevel][cache], timing)
 	}
 
-	table.Render()
-	return nil
+	originTraffic := make(map[backend.FileSizeLevel]map[string][]*OriginTraffic)
+	for _, traffic := range s.GetOriginTraffic() {
+		if traffic.Downloader != downloader {
+			continue
+		}
+
+		if originTraffic[traffic.FileSizeLevel] == nil {
+			originTraffic[traffic.FileSizeLevel] = make(map[string][]*OriginTraffic)
+		}
+
+		cache := cacheState(traffic.Warm, traffic.Preheated)
+		originTraffic[traffic.FileSizeLevel][cache] = append(originTraffic[traffic.FileSizeLevel][cache], traffic)
+	}
+
+	var summaries []*summary
+	for _, fileSizeLevel := range sortFileSizeLevels(slices.Concat(slices.Collect(maps.Keys(downloads)), slices.Collect(maps.Keys(timings)))) {
+		var caches []string
+		for _, cache := range []string{cacheCold, cacheWarm, cachePreheated} {
+			if len(downloads[fileSizeLevel][cache]) > 0 || len(timings[fileSizeLevel][cache]) > 0 {
+				caches = append(caches, cache)
+			}
+		}
+
+		if len(caches) <= 1 {
+			continue
+		}
+
+		for _, cache := range caches {
+
+			summary, err := summarizeDownloads(downloader, fileSizeLevel, downloads[fileSizeLevel][cache], timings[fileSizeLevel][cache])
+			if err != nil {
+				logrus.Warnf("failed to summarize %s cache of %s by %s: %v", cache, fileSizeLevel, downloader, err)
+				continue
+			}
+
+			summary.cache = cache
+			summary.addOriginTraffic(originTraffic[fileSizeLevel][cache])
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries
+}
+
+// summarizeWaves aggregates the downloads and the client side timings of the downloader by file size level
+// and wave, the downloads are assigned to the waves by the pods, and nothing is returned for a single wave.
+func (s *stats) summarizeWaves(downloader string) []*summary {
+	podWaves := make(map[backend.FileSizeLevel]map[string]int)
+	timings := make(map[backend.FileSizeLevel]map[int][]*Timing)
+	for _, timing := range s.GetTimings() {
+		// The uploads and the preheats before the downloads are not started by the waves.
+		if timing.Downloader != downloader || timing.Upload || timing.Preheat {
+			continue
+		}
+
+		if podWaves[timing.FileSizeLevel] == nil {
+			podWaves[timing.FileSizeLevel] = make(map[string]int)
+			timings[timing.FileSizeLevel] = make(map[int][]*Timing)
+		}
+
+		podWaves[timing.FileSizeLevel][timing.PodName] = timing.Wave
+		timings[timing.FileSizeLevel][timing.Wave] = append(timings[timing.FileSizeLevel][timing.Wave], timing)
+	}
+
+	downloads := make(map[backend.FileSizeLevel]map[int][]*Download)
+	for _, download := range s.GetDownloads() {
+		wave, ok := podWaves[download.fileSizeLevel][download.podName]
+		if download.downloader != downloader || !ok {
+			continue
+		}
+
+		if downloads[download.fileSizeLevel] == nil {
+			downloads[download.fileSizeLevel] = make(map[int][]*Download)
+		}
+
+		downloads[download.fileSizeLevel][wave] = append(downloads[download.fileSizeLevel][wave], download)
+	}
+
+	var summaries []*summary
+	for _, fileSizeLevel := range sortFileSizeLevels(slices.Collect(maps.Keys(timings))) {
+		if len(timings[fileSizeLevel]) <= 1 {
+			continue
+		}
+
+		waves := slices.Sorted(maps.Keys(timings[fileSizeLevel]))
+		for _, wave := range waves {
+			summary, err := summarizeDownloads(downloader, fileSizeLevel, downloads[fileSizeLevel][wave], timings[fileSizeLevel][wave])
+			if err != nil {
+				logrus.Warnf("failed to summarize wave %d of %s by %s: %v", wave, fileSizeLevel, downloader, err)
+				continue
+			}
+
+			summary.wave = wave
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries
+}
+
+// column represents a column of the statistics table.
+type column struct {
+	// name is the name to select the column.
+	name string
+
+	// header is the header of the column.
+	header string
+
+	// value returns the value of the column.
+	value func(*summary) string
+}
+
+// columns is all columns of the statistics table.
+var columns = []column{
+	{"times", "Times", func(s *summary) string { return fmt.Sprintf("%d", s.count) }},
+	{"min", "Min Cost", func(s *summary) string { return formatDuration(s.minCost()) }},
+	{"max", "Max Cost", func(s *summary) string { return formatDuration(s.maxCost()) }},
+	{"avg", "Avg Cost", func(s *summary) string { return formatDuration(s.avgCost()) }},
+	{"p50", "P50 Cost", func(s *summary) string { return formatDuration(s.percentile(50)) }},
+	{"p90", "P90 Cost", func(s *summary) string { return formatDuration(s.percentile(90)) }},
+	{"p95", "P95 Cost", func(s *summary) string { return formatDuration(s.percentile(95)) }},
+	{"p99", "P99 Cost", func(s *summary) string { return formatDuration(s.percentile(99)) }},
+	{"stddev", "Stddev", func(s *summary) string { return formatDuration(s.stddev()) }},
+	{"throughput", "Throughput", func(s *summary) string { return humanize.Bytes(uint64(s.throughput())) + "/s" }},
+	{"client-cost", "Avg Client Cost", func(s *summary) string {
+		if cost, ok := s.avgClientCost(); ok {
+			return formatDuration(cost)
+		}
+		return "-"
+	}},
+	{"ttfb", "Avg TTFB", func(s *summary) string {
+		if ttfb, ok := s.avgTTFB(); ok {
+			return formatDuration(ttfb)
+		}
+		return "-"
+	}},
+	{"client-overhead", "Client Overhead", func(s *summary) string {
+		if cost, ok := s.avgClientCost(); ok {
+			return formatDuration(cost - s.avgCost())
+		}
+		return "-"
+	}},
+	{"back-to-source-traffic", "Back To Source Traffic", func(s *summary) string { return humanize.Bytes(uint64(s.backToSourceTraffic)) }},
+	{"remote-peer-traffic", "Remote Peer Traffic", func(s *summary) string { return humanize.Bytes(uint64(s.remotePeerTraffic)) }},
+	{"local-peer-traffic", "Local Peer Traffic", func(s *summary) string { return humanize.Bytes(uint64(s.localPeerTraffic)) }},
+	{"back-to-source-rate", "Back To Source Rate", func(s *summary) string { return fmt.Sprintf("%.2f%%", s.backToSourceRate()) }},
+	{"upload", "Avg Upload Cost", func(s *summary) string {
+		if cost, ok := average(s.uploadCosts); ok {
+			return formatDuration(cost)
+		}
+		return "-"
+	}},
+	{"preheat", "Avg Preheat Cost", func(s *summary) string {
+		if cost, ok := average(s.preheatCosts); ok {
+			return formatDuration(cost)
+		}
+		return "-"
+	}},
+	{"origin-traffic", "Origin Traffic", func(s *summary) string {
+		if s.originTasks == 0 {
+			return "-"
+		}
+		return humanize.Bytes(uint64(s.originTraffic))
+	}},
+	{"origin-fetches", "Origin Fetches", func(s *summary) string {
+		if fetches, ok := s.originFetches(); ok {
+			return fmt.Sprintf("%.2fx", fetches)
+		}
+		return "-"
+	}},
+	{"origin-diff", "Origin Diff", formatOriginDiff},
+	{"corrupted", "Corrupted", func(s *summary) string { return strconv.FormatUint(s.corrupted, 10) }},
+	{"speed-up", "Speed-Up", func(s *summary) string {
+		if s.speedUp > 0 {
+			return fmt.Sprintf("%.2fx", s.speedUp)
+		}
+		return "-"
+	}},
+}
+
+// DefaultColumns is the columns of the statistics table printed by default.
+var DefaultColumns = []string{
+	"times", "min", "max", "avg", "p50", "p90", "p99", "stddev", "throughput", "client-cost", "client-overhead",
+	"back-to-source-traffic", "remote-peer-traffic", "local-peer-traffic", "back-to-source-rate",
+}
+
+// ColumnNames returns the names of all columns of the statistics table.
+func ColumnNames() []string {
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+
+	return names
+}
+
+// ValidateColumns validates the names of the columns of the statistics table.
+func ValidateColumns(names []string) error {
+	_, err := selectColumns(names)
+	return err
+}
+
+// selectColumns returns the columns by names, all columns are selected by "all"
+// and the default columns are selected if names is empty.
+func selectColumns(names []string) ([]column, error) {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+
+	if slices.Contains(names, "all") {
+		return columns, nil
+	}
+
+	selected := make([]column, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(columns, func(c column) bool { return c.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q, available columns are %v", name, ColumnNames())
+		}
+
+		selected = append(selected, columns[i])
+	}
+
+	return selected, nil
+}
+
+// grouping is the grouping of the rows of the statistics table.
+type grouping int
+
+const (
+	// byFileSizeLevel groups the rows by file size level.
+	byFileSizeLevel grouping = iota
+
+	// byWave groups the rows by file size level and wave.
+	byWave
+
+	// byCache groups the rows by file size level and cache state.
+	byCache
+)
+
+// printTable prints the download statistics in a table format, the rows are grouped by the grouping.
+func printTable(w io.Writer, summaries []*summary, names []string, markdown bool, grouping grouping) error {
+	selected, err := selectColumns(names)
+	if err != nil {
+		return err
+	}
+
+	// Format the header manually, the auto format splits the digits of percentile headers, e.g. "P 99 COST".
+	header := []string{"FILE SIZE LEVEL"}
+	switch grouping {
+	case byWave:
+		header = append(header, "WAVE")
+	case byCache:
+		header = append(header, "CACHE")
+	}
+
+	for _, c := range selected {
+		header = append(header, strings.ToUpper(c.header))
+	}
+
+	options := []tablewriter.Option{tablewriter.WithHeaderAutoFormat(tw.Off)}
+	if markdown {
+		options = append(options, tablewriter.WithRenderer(renderer.NewMarkdown()))
+	}
+
+	table := tablewriter.NewTable(w, options...)
+	table.Header(header)
+	for _, summary := range summaries {
+		row := []string{summary.fileSizeLevel.String()}
+		switch grouping {
+		case byWave:
+			row = append(row, strconv.Itoa(summary.wave))
+		case byCache:
+			row = append(row, summary.cache)
+		}
+
+		for _, c := range selected {
+			row = append(row, c.value(summary))
+		}
+
+		if err := table.Append(row); err != nil {
+			return err
+		}
+	}
+
+	return table.Render()
+}
+
+// downloadCosts returns the cost of every download recorded by the histogram. A single sample is
+// exact, several samples are estimated from the deltas of the cumulative bucket counts, every
+// download in a bucket costs the midpoint of the bucket, and the downloads above the highest
+// bucket share the rest of the sample sum.
+func downloadCosts(histogram *dto.Histogram) []time.Duration {
+	count := histogram.GetSampleCount()
+	switch count {
+	case 0:
+		return nil
+	case 1:
+		return []time.Duration{millisecondsToDuration(histogram.GetSampleSum())}
+	}
+
+	var (
+		costs                 = make([]time.Duration, 0, count)
+		cumulativeCount       uint64
+		lowerBound, bucketSum float64
+	)
+	for _, bucket := range histogram.GetBucket() {
+		upperBound := bucket.GetUpperBound()
+		if math.IsInf(upperBound, 1) || bucket.GetCumulativeCount() > count {
+			break
+		}
+
+		midpoint := (lowerBound + upperBound) / 2
+		for ; cumulativeCount < bucket.GetCumulativeCount(); cumulativeCount++ {
+			costs = append(costs, millisecondsToDuration(midpoint))
+			bucketSum += midpoint
+		}
+
+		lowerBound = upperBound
+	}
+
+	if overflowCount := count - cumulativeCount; overflowCount > 0 {
+		cost := math.Max(lowerBound, (histogram.GetSampleSum()-bucketSum)/float64(overflowCount))
+		for ; cumulativeCount < count; cumulativeCount++ {
+			costs = append(costs, millisecondsToDuration(cost))
+		}
+	}
+
+	return costs
+}
+
+// millisecondsToDuration converts the milliseconds to the duration.
+func millisecondsToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
 }
 
 // formatDuration formats the duration to a string.
@@ -310,3 +1051,28 @@ func formatDuration(d time.Duration) string {
 	ms := float64(d) / float64(time.Millisecond)
 	return fmt.Sprintf("%.2fms", ms)
 }
+
+// sortFileSizeLevels sorts the file size levels by size and removes the duplicates.
+func sortFileSizeLevels(fileSizeLevels []backend.FileSizeLevel) []backend.FileSizeLevel {
+	slices.SortFunc(fileSizeLevels, func(a, b backend.FileSizeLevel) int {
+		if c := cmp.Compare(a.Bytes(), b.Bytes()); c != 0 {
+			return c
+		}
+
+		return strings.Compare(string(a), string(b))
+	})
+
+	return slices.Compact(fileSizeLevels)
+}
+
+// cacheState returns the name of the cache state of the downloads.
+func cacheState(warm, preheated bool) string {
+	switch {
+	case warm:
+		return cacheWarm
+	case preheated:
+		return cachePreheated
+	default:
+		return cacheCold
+	}
+}
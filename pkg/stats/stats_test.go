@@ -0,0 +1,424 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+	"github.com/dragonflyoss/perf-tests/pkg/config"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// parseMetricFamilies parses the metrics in the text format.
+func parseMetricFamilies(t *testing.T, text string) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	metricFamilies, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("failed to parse metrics: %v", err)
+	}
+
+	return metricFamilies
+}
+
+// parseHistogram parses the histogram of the download task duration of the task size level 1 in the text format.
+func parseHistogram(t *testing.T, text string) *dto.Histogram {
+	t.Helper()
+
+	mf, ok := parseMetricFamilies(t, "# TYPE dragonfly_client_download_task_duration_milliseconds histogram\n"+text)["dragonfly_client_download_task_duration_milliseconds"]
+	if !ok || len(mf.GetMetric()) != 1 {
+		t.Fatalf("invalid histogram %q", text)
+	}
+
+	return mf.GetMetric()[0].GetHistogram()
+}
+
+func TestDownloadCosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram string
+		expected  []time.Duration
+	}{
+		{
+			name: "no sample",
+			histogram: `dragonfly_client_download_task_duration_milliseconds_bucket{le="10"} 0
+dragonfly_client_download_task_duration_milliseconds_bucket{le="+Inf"} 0
+dragonfly_client_download_task_duration_milliseconds_sum 0
+dragonfly_client_download_task_duration_milliseconds_count 0
+`,
+		},
+		{
+			name: "single sample is exact",
+			histogram: `dragonfly_client_download_task_duration_milliseconds_bucket{le="10"} 0
+dragonfly_client_download_task_duration_milliseconds_bucket{le="100"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{le="+Inf"} 1
+dragonfly_client_download_task_duration_milliseconds_sum 42.5
+dragonfly_client_download_task_duration_milliseconds_count 1
+`,
+			expected: []time.Duration{42500 * time.Microsecond},
+		},
+		{
+			name: "samples at bucket midpoints",
+			histogram: `dragonfly_client_download_task_duration_milliseconds_bucket{le="10"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{le="100"} 3
+dragonfly_client_download_task_duration_milliseconds_bucket{le="1000"} 3
+dragonfly_client_download_task_duration_milliseconds_bucket{le="+Inf"} 3
+dragonfly_client_download_task_duration_milliseconds_sum 120
+dragonfly_client_download_task_duration_milliseconds_count 3
+`,
+			expected: []time.Duration{5 * time.Millisecond, 55 * time.Millisecond, 55 * time.Millisecond},
+		},
+		{
+			name: "overflow samples share the rest of the sum",
+			histogram: `dragonfly_client_download_task_duration_milliseconds_bucket{le="10"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{le="100"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{le="+Inf"} 4
+dragonfly_client_download_task_duration_milliseconds_sum 460
+dragonfly_client_download_task_duration_milliseconds_count 4
+`,
+			expected: []time.Duration{5 * time.Millisecond, 55 * time.Millisecond, 200 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name: "overflow samples are at least the highest bucket",
+			histogram: `dragonfly_client_download_task_duration_milliseconds_bucket{le="10"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{le="100"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{le="+Inf"} 4
+dragonfly_client_download_task_duration_milliseconds_sum 100
+dragonfly_client_download_task_duration_milliseconds_count 4
+`,
+			expected: []time.Duration{5 * time.Millisecond, 55 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			costs := downloadCosts(parseHistogram(t, tt.histogram))
+			if !slices.Equal(costs, tt.expected) {
+				t.Errorf("downloadCosts = %v, want %v", costs, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPodMetrics(t *testing.T) {
+	metrics := `# TYPE dragonfly_client_download_task_duration_milliseconds histogram
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="10"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="100"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="+Inf"} 2
+dragonfly_client_download_task_duration_milliseconds_sum{task_size_level="1"} 60
+dragonfly_client_download_task_duration_milliseconds_count{task_size_level="1"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="2",le="10"} 0
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="2",le="100"} 1
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="2",le="+Inf"} 1
+dragonfly_client_download_task_duration_milliseconds_sum{task_size_level="2"} 80
+dragonfly_client_download_task_duration_milliseconds_count{task_size_level="2"} 1
+# TYPE dragonfly_client_download_traffic counter
+dragonfly_client_download_traffic{type="BACK_TO_SOURCE",task_type="STANDARD"} 1024
+dragonfly_client_download_traffic{type="REMOTE_PEER",task_type="STANDARD"} 2048
+dragonfly_client_download_traffic{type="LOCAL_PEER",task_type="STANDARD"} 512
+`
+
+	tests := []struct {
+		name          string
+		fileSizeLevel backend.FileSizeLevel
+		count         uint64
+		totalCost     time.Duration
+		costs         []time.Duration
+	}{
+		{
+			name:          "task size level 1",
+			fileSizeLevel: backend.FileSizeLevelMicro,
+			count:         2,
+			totalCost:     60 * time.Millisecond,
+			costs:         []time.Duration{5 * time.Millisecond, 55 * time.Millisecond},
+		},
+		{
+			name:          "task size level 2",
+			fileSizeLevel: backend.FileSizeLevelSmall,
+			count:         1,
+			totalCost:     80 * time.Millisecond,
+			costs:         []time.Duration{80 * time.Millisecond},
+		},
+		{
+			name:          "no download of the task size level",
+			fileSizeLevel: backend.FileSizeLevelLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			download := &Download{podName: "client-0", fileSizeLevel: tt.fileSizeLevel, metricFamilies: parseMetricFamilies(t, metrics)}
+			pm, err := download.podMetrics()
+			if err != nil {
+				t.Fatalf("podMetrics failed: %v", err)
+			}
+
+			if pm.Count != tt.count || pm.TotalCost != tt.totalCost || !slices.Equal(pm.Costs, tt.costs) {
+				t.Errorf("podMetrics = count %d, total cost %v, costs %v, want count %d, total cost %v, costs %v",
+					pm.Count, pm.TotalCost, pm.Costs, tt.count, tt.totalCost, tt.costs)
+			}
+
+			if pm.BackToSourceTraffic != 1024 || pm.RemotePeerTraffic != 2048 || pm.LocalPeerTraffic != 512 {
+				t.Errorf("podMetrics traffic = %v/%v/%v, want 1024/2048/512", pm.BackToSourceTraffic, pm.RemotePeerTraffic, pm.LocalPeerTraffic)
+			}
+		})
+	}
+}
+
+func TestSummarizeWaves(t *testing.T) {
+	tests := []struct {
+		name     string
+		waves    map[string]int
+		expected []int
+	}{
+		{
+			name:  "single wave",
+			waves: map[string]int{"client-0": 0, "client-1": 0},
+		},
+		{
+			name:     "two waves",
+			waves:    map[string]int{"client-0": 0, "client-1": 1, "client-2": 1},
+			expected: []int{0, 1},
+		},
+		{
+			name:     "waves sorted by index",
+			waves:    map[string]int{"client-0": 2, "client-1": 0, "client-2": 1},
+			expected: []int{0, 1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(config.New(), nil).(*stats)
+			for pod, wave := range tt.waves {
+				s.downloads.Store(pod, &Download{
+					podName:        pod,
+					downloader:     config.DownloaderDfget,
+					fileSizeLevel:  backend.FileSizeLevelMicro,
+					metricFamilies: parseMetricFamilies(t, testMetrics),
+				})
+				s.AddTiming(&Timing{PodName: pod, Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: time.Second, Wave: wave})
+			}
+
+			// The downloads of the other downloader, and the uploads and the preheats before the downloads are not summarized.
+			s.AddTiming(&Timing{PodName: "client-0", Downloader: config.DownloaderProxy, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: time.Second, Wave: 5})
+			s.AddTiming(&Timing{PodName: "client-0", Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: time.Second, Upload: true})
+			s.AddTiming(&Timing{PodName: "client-0", Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: time.Second, Preheat: true})
+
+			summaries := s.summarizeWaves(config.DownloaderDfget)
+			var waves []int
+			for _, summary := range summaries {
+				waves = append(waves, summary.wave)
+			}
+
+			if !slices.Equal(waves, tt.expected) {
+				t.Fatalf("summarizeWaves() waves = %v, want %v", waves, tt.expected)
+			}
+
+			for _, summary := range summaries {
+				var pods uint64
+				for _, wave := range tt.waves {
+					if wave == summary.wave {
+						pods++
+					}
+				}
+
+				// Every pod reports two downloads of the task size level.
+				if len(summary.uploadCosts) != 0 || len(summary.preheatCosts) != 0 {
+					t.Errorf("wave %d has %d uploads and %d preheats, want 0", summary.wave, len(summary.uploadCosts), len(summary.preheatCosts))
+				}
+
+				if summary.count != 2*pods || len(summary.clientCosts) != int(pods) {
+					t.Errorf("wave %d has %d downloads and %d timings, want %d and %d", summary.wave, summary.count, len(summary.clientCosts), 2*pods, pods)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarizeCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		downloader string
+		caches     []string
+		timings    []string
+		expected   []string
+	}{
+		{
+			name:   "cold downloads only",
+			caches: []string{cacheCold, cacheCold},
+		},
+		{
+			name:     "cold and warm downloads",
+			caches:   []string{cacheCold, cacheWarm, cacheWarm},
+			expected: []string{cacheCold, cacheWarm},
+		},
+		{
+			name:   "warm downloads only",
+			caches: []string{cacheWarm, cacheWarm},
+		},
+		{
+			name:     "cold, warm and preheated downloads",
+			caches:   []string{cacheWarm, cachePreheated, cacheCold},
+			expected: []string{cacheCold, cacheWarm, cachePreheated},
+		},
+		{
+			name:       "direct timings without downloads",
+			downloader: config.DownloaderDirect,
+			timings:    []string{cacheCold, cacheWarm, cacheWarm},
+			expected:   []string{cacheCold, cacheWarm},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader := config.DownloaderDfget
+			if tt.downloader != "" {
+				downloader = tt.downloader
+			}
+
+			s := New(config.New(), nil).(*stats)
+			for i, cache := range tt.caches {
+				pod := fmt.Sprintf("client-%d", i)
+				s.downloads.Store(pod, &Download{
+					podName:        pod,
+					downloader:     downloader,
+					fileSizeLevel:  backend.FileSizeLevelMicro,
+					warm:           cache == cacheWarm,
+					preheated:      cache == cachePreheated,
+					metricFamilies: parseMetricFamilies(t, testMetrics),
+				})
+			}
+
+			for i, cache := range append(slices.Clone(tt.caches), tt.timings...) {
+				s.AddTiming(&Timing{PodName: fmt.Sprintf("client-%d", i), Downloader: downloader, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: time.Second, Warm: cache == cacheWarm, Preheated: cache == cachePreheated})
+			}
+
+			summaries := s.summarizeCache(downloader)
+			var caches []string
+			for _, summary := range summaries {
+				caches = append(caches, summary.cache)
+			}
+
+			if !slices.Equal(caches, tt.expected) {
+				t.Fatalf("summarizeCache() cache states = %v, want %v", caches, tt.expected)
+			}
+
+			for _, summary := range summaries {
+				var pods, timings uint64
+				for _, cache := range tt.caches {
+					if cache == summary.cache {
+						pods++
+					}
+				}
+
+				for _, cache := range tt.timings {
+					if cache == summary.cache {
+						timings++
+					}
+				}
+
+				// The direct downloads are counted by the timings.
+				count := 2 * pods
+				if downloader == config.DownloaderDirect {
+					count = timings
+				}
+
+				if summary.count != count || len(summary.clientCosts) != int(pods+timings) {
+					t.Errorf("%s cache has %d downloads and %d timings, want %d and %d", summary.cache, summary.count, len(summary.clientCosts), count, pods+timings)
+				}
+			}
+		})
+	}
+}
+
+func TestSpeedUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		direct   []time.Duration
+		dfget    []time.Duration
+		expected float64
+	}{
+		{
+			name:     "faster than direct",
+			direct:   []time.Duration{4 * time.Second, 2 * time.Second},
+			dfget:    []time.Duration{time.Second, 2 * time.Second},
+			expected: 2,
+		},
+		{
+			name:     "slower than direct",
+			direct:   []time.Duration{time.Second},
+			dfget:    []time.Duration{4 * time.Second},
+			expected: 0.25,
+		},
+		{
+			name:  "no direct download",
+			dfget: []time.Duration{time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(config.New(), nil).(*stats)
+			for i, elapsed := range tt.dfget {
+				pod := fmt.Sprintf("client-%d", i)
+				s.downloads.Store(pod, &Download{
+					podName:        pod,
+					downloader:     config.DownloaderDfget,
+					fileSizeLevel:  backend.FileSizeLevelMicro,
+					metricFamilies: parseMetricFamilies(t, testMetrics),
+				})
+
+				// The speed-up is computed from the wall-clock time rather than the transfer time reported by curl.
+				s.AddTiming(&Timing{PodName: pod, Downloader: config.DownloaderDfget, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: elapsed, Total: time.Millisecond})
+			}
+
+			for i, elapsed := range tt.direct {
+				s.AddTiming(&Timing{PodName: fmt.Sprintf("client-%d", i), Downloader: config.DownloaderDirect, FileSizeLevel: backend.FileSizeLevelMicro, Elapsed: elapsed, Total: time.Millisecond})
+			}
+
+			summaries, err := s.summarize()
+			if err != nil {
+				t.Fatalf("summarize failed: %v", err)
+			}
+
+			if len(summaries[config.DownloaderDfget]) != 1 {
+				t.Fatalf("got %d dfget summaries, want 1", len(summaries[config.DownloaderDfget]))
+			}
+
+			if speedUp := summaries[config.DownloaderDfget][0].speedUp; speedUp != tt.expected {
+				t.Errorf("speed-up = %v, want %v", speedUp, tt.expected)
+			}
+
+			// The direct downloads are summarized from the client side timings alone.
+			if len(tt.direct) > 0 {
+				direct := summaries[config.DownloaderDirect]
+				if len(direct) != 1 || direct[0].count != uint64(len(tt.direct)) || direct[0].speedUp != 0 {
+					t.Errorf("direct summaries = %+v, want one summary of %d downloads without speed-up", direct, len(tt.direct))
+				}
+			}
+		})
+	}
+}
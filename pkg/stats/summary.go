@@ -0,0 +1,391 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+// summary represents the aggregated statistics of the downloads of a file size level.
+type summary struct {
+	// fileSizeLevel is the file size level of the downloads.
+	fileSizeLevel backend.FileSizeLevel
+
+	// wave is the index of the wave of the downloads, zero if not summarized by wave.
+	wave int
+
+	// cache is the cache state of the downloads [cold, warm, preheated], empty if not summarized by cache.
+	cache string
+
+	// count is the number of downloads reported by the dfdaemon.
+	count uint64
+
+	// totalCost is the total cost of the downloads reported by the dfdaemon.
+	totalCost time.Duration
+
+	// costs is the sorted cost of every download reported by the dfdaemon.
+	costs []time.Duration
+
+	// clientCosts is the client side cost of every download.
+	clientCosts []time.Duration
+
+	// elapsed is the wall-clock time of every download measured by dfbench, including the exec overhead.
+	elapsed []time.Duration
+
+	// ttfbs is the time to the first byte of every download reported by curl.
+	ttfbs []time.Duration
+
+	// uploadCosts is the client side cost of every import or put before the downloads by dfcache or dfstore.
+	uploadCosts []time.Duration
+
+	// preheatCosts is the cost of every preheat job before the downloads.
+	preheatCosts []time.Duration
+
+	// originTasks is the number of the tasks of the traffic served by the file server, zero if not counted.
+	originTasks uint64
+
+	// originRequests is the number of the requests served by the file server.
+	originRequests uint64
+
+	// originTraffic is the traffic served by the file server.
+	originTraffic float64
+
+	// corrupted is the number of the downloads mismatching the size or the digest of the file server,
+	// their costs are not aggregated as the client side costs, but the costs reported by the dfdaemon
+	// histogram include them.
+	corrupted uint64
+
+	// backToSourceTraffic is the traffic downloaded from the source.
+	backToSourceTraffic float64
+
+	// remotePeerTraffic is the traffic downloaded from the remote peers.
+	remotePeerTraffic float64
+
+	// localPeerTraffic is the traffic downloaded from the local peer.
+	localPeerTraffic float64
+
+	// speedUp is the ratio of the wall-clock time of the direct downloads to the wall-clock time
+	// of the downloads, zero if there is no direct download.
+	speedUp float64
+}
+
+// PodMetrics represents the dfdaemon metrics collected from a pod after a download step.
+type PodMetrics struct {
+	// PodName is the name of the pod.
+	PodName string `json:"pod_name"`
+
+	// Downloader is the downloader used to download the file.
+	Downloader string `json:"downloader"`
+
+	// FileSizeLevel is the file size level of the file.
+	FileSizeLevel backend.FileSizeLevel `json:"file_size_level"`
+
+	// Warm is true if the downloads reuse the tasks of the previous iteration.
+	Warm bool `json:"warm"`
+
+	// Preheated is true if the tasks are preheated before the downloads.
+	Preheated bool `json:"preheated,omitempty"`
+
+	// Image is the image pulled by the downloads, empty if the downloads are not image pulls.
+	Image string `json:"image,omitempty"`
+
+	// Load is the mode of the load sent through the proxy, empty if the downloads are not a load.
+	Load string `json:"load,omitempty"`
+
+	// CollectedAt is the time when the metrics are collected.
+	CollectedAt time.Time `json:"collected_at"`
+
+	// Count is the number of downloads reported by the dfdaemon.
+	Count uint64 `json:"count"`
+
+	// TotalCost is the total cost of the downloads reported by the dfdaemon.
+	TotalCost time.Duration `json:"total_cost"`
+
+	// Costs is the cost of every download reported by the dfdaemon.
+	Costs []time.Duration `json:"costs"`
+
+	// BackToSourceTraffic is the traffic downloaded from the source in bytes.
+	BackToSourceTraffic float64 `json:"back_to_source_traffic"`
+
+	// RemotePeerTraffic is the traffic downloaded from the remote peers in bytes.
+	RemotePeerTraffic float64 `json:"remote_peer_traffic"`
+
+	// LocalPeerTraffic is the traffic downloaded from the local peer in bytes.
+	LocalPeerTraffic float64 `json:"local_peer_traffic"`
+}
+
+// podMetrics parses the dfdaemon metrics of the download.
+func (d *Download) podMetrics() (*PodMetrics, error) {
+	pm := &PodMetrics{
+		PodName:       d.podName,
+		Downloader:    d.downloader,
+		FileSizeLevel: d.fileSizeLevel,
+		Warm:          d.warm,
+		Preheated:     d.preheated,
+		Image:         d.image,
+		Load:          d.load,
+		CollectedAt:   d.collectedAt,
+	}
+
+	for name, mf := range d.metricFamilies {
+		switch name {
+		case "dragonfly_client_download_traffic":
+			for _, metrics := range mf.GetMetric() {
+				for _, label := range metrics.GetLabel() {
+					if *label.Name == "type" {
+						switch *label.Value {
+						case "BACK_TO_SOURCE":
+							pm.BackToSourceTraffic += metrics.GetCounter().GetValue()
+						case "REMOTE_PEER":
+							pm.RemotePeerTraffic += metrics.GetCounter().GetValue()
+						case "LOCAL_PEER":
+							pm.LocalPeerTraffic += metrics.GetCounter().GetValue()
+						default:
+							return nil, fmt.Errorf("invalid traffic type: %s", *label.Value)
+						}
+					}
+				}
+			}
+		case "dragonfly_client_download_task_duration_milliseconds":
+			for _, metrics := range mf.GetMetric() {
+				for _, label := range metrics.GetLabel() {
+					if *label.Name == "task_size_level" && *label.Value == d.fileSizeLevel.TaskSizeLevel() {
+						histogram := metrics.GetHistogram()
+						pm.TotalCost += millisecondsToDuration(histogram.GetSampleSum())
+						pm.Count += histogram.GetSampleCount()
+						pm.Costs = append(pm.Costs, downloadCosts(histogram)...)
+					}
+				}
+			}
+		}
+	}
+
+	return pm, nil
+}
+
+// newSummary aggregates the downloads and the client side timings of the file size level.
+func newSummary(fileSizeLevel backend.FileSizeLevel, downloads []*Download, timings []*Timing) (*summary, error) {
+	s := &summary{fileSizeLevel: fileSizeLevel}
+	for _, download := range downloads {
+		pm, err := download.podMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		s.count += pm.Count
+		s.totalCost += pm.TotalCost
+		s.costs = append(s.costs, pm.Costs...)
+		s.backToSourceTraffic += pm.BackToSourceTraffic
+		s.remotePeerTraffic += pm.RemotePeerTraffic
+		s.localPeerTraffic += pm.LocalPeerTraffic
+	}
+
+	if s.count == 0 {
+		return nil, errors.New("no download sample found")
+	}
+	slices.Sort(s.costs)
+
+	for _, timing := range timings {
+		if timing.Upload {
+			s.uploadCosts = append(s.uploadCosts, timing.Cost())
+			continue
+		}
+
+		if timing.Preheat {
+			s.preheatCosts = append(s.preheatCosts, timing.Cost())
+			continue
+		}
+
+		// The downloads of the faulty tasks are reported by fault.
+		if timing.Faulty {
+			continue
+		}
+
+		if timing.Corrupted {
+			s.corrupted++
+			continue
+		}
+
+		s.clientCosts = append(s.clientCosts, timing.Cost())
+		s.elapsed = append(s.elapsed, timing.Elapsed)
+		if timing.TTFB > 0 {
+			s.ttfbs = append(s.ttfbs, timing.TTFB)
+		}
+	}
+
+	// The dfdaemon histogram includes the corrupted downloads, so the costs are meaningless if all are corrupted.
+	if len(s.clientCosts) == 0 && s.corrupted > 0 {
+		return nil, fmt.Errorf("all %d downloads are corrupted", s.corrupted)
+	}
+
+	return s, nil
+}
+
+// newClientSummary aggregates the file size level by the client side timings as the costs for the downloads
+// not reported by the dfdaemon histogram, and the traffic of the downloads.
+func newClientSummary(fileSizeLevel backend.FileSizeLevel, downloads []*Download, timings []*Timing) (*summary, error) {
+	s := &summary{fileSizeLevel: fileSizeLevel}
+	for _, download := range downloads {
+		pm, err := download.podMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		s.backToSourceTraffic += pm.BackToSourceTraffic
+		s.remotePeerTraffic += pm.RemotePeerTraffic
+		s.localPeerTraffic += pm.LocalPeerTraffic
+	}
+
+	for _, timing := range timings {
+		cost := timing.Cost()
+		if timing.Upload {
+			s.uploadCosts = append(s.uploadCosts, cost)
+			continue
+		}
+
+		if timing.Preheat {
+			s.preheatCosts = append(s.preheatCosts, cost)
+			continue
+		}
+
+		// The downloads of the faulty tasks are reported by fault.
+		if timing.Faulty {
+			continue
+		}
+
+		if timing.Corrupted {
+			s.corrupted++
+			continue
+		}
+
+		s.count++
+		s.totalCost += cost
+		s.costs = append(s.costs, cost)
+		s.clientCosts = append(s.clientCosts, cost)
+		s.elapsed = append(s.elapsed, timing.Elapsed)
+		if timing.TTFB > 0 {
+			s.ttfbs = append(s.ttfbs, timing.TTFB)
+		}
+	}
+
+	if s.count == 0 && s.corrupted > 0 {
+		return nil, fmt.Errorf("all %d downloads are corrupted", s.corrupted)
+	}
+
+	if s.count == 0 {
+		return nil, errors.New("no download sample found")
+	}
+	slices.Sort(s.costs)
+
+	return s, nil
+}
+
+// minCost returns the minimum cost of the downloads.
+func (s *summary) minCost() time.Duration {
+	return s.costs[0]
+}
+
+// maxCost returns the maximum cost of the downloads.
+func (s *summary) maxCost() time.Duration {
+	return s.costs[len(s.costs)-1]
+}
+
+// avgCost returns the average cost of the downloads.
+func (s *summary) avgCost() time.Duration {
+	return s.totalCost / time.Duration(s.count)
+}
+
+// percentile returns the cost at the percentile of the downloads by the nearest-rank method.
+func (s *summary) percentile(p float64) time.Duration {
+	return percentile(s.costs, p)
+}
+
+// stddev returns the population standard deviation of the cost of the downloads.
+func (s *summary) stddev() time.Duration {
+	avg := float64(s.avgCost())
+	var sum float64
+	for _, cost := range s.costs {
+		sum += (float64(cost) - avg) * (float64(cost) - avg)
+	}
+
+	return time.Duration(math.Sqrt(sum / float64(len(s.costs))))
+}
+
+// throughput returns the effective throughput of the downloads in bytes per second.
+func (s *summary) throughput() float64 {
+	avg := s.avgCost()
+	if avg <= 0 {
+		return 0
+	}
+
+	return float64(s.fileSizeLevel.Bytes()) / avg.Seconds()
+}
+
+// avgClientCost returns the average client side cost of the downloads, false if no timing is recorded.
+func (s *summary) avgClientCost() (time.Duration, bool) {
+	return average(s.clientCosts)
+}
+
+// avgElapsed returns the average wall-clock time of the downloads, false if no timing is recorded.
+func (s *summary) avgElapsed() (time.Duration, bool) {
+	return average(s.elapsed)
+}
+
+// avgTTFB returns the average time to the first byte of the downloads, false if no TTFB is reported.
+func (s *summary) avgTTFB() (time.Duration, bool) {
+	return average(s.ttfbs)
+}
+
+// backToSourceRate returns the percentage of the traffic downloaded from the source.
+func (s *summary) backToSourceRate() float64 {
+	total := s.backToSourceTraffic + s.remotePeerTraffic + s.localPeerTraffic
+	if total == 0 {
+		return 0
+	}
+
+	return s.backToSourceTraffic / total * 100
+}
+
+// average returns the average of the durations, false if the durations are empty.
+func average(durations []time.Duration) (time.Duration, bool) {
+	if len(durations) == 0 {
+		return 0, false
+	}
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+
+	return total / time.Duration(len(durations)), true
+}
+
+// percentile returns the duration at the percentile of the sorted durations by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
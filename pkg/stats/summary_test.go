@@ -0,0 +1,192 @@
+/*
+ *     Copyright 2024 The Dragonfly Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stats
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragonflyoss/perf-tests/pkg/backend"
+)
+
+// durations returns the durations of the milliseconds.
+func durations(ms ...int) []time.Duration {
+	ds := make([]time.Duration, 0, len(ms))
+	for _, m := range ms {
+		ds = append(ds, time.Duration(m)*time.Millisecond)
+	}
+
+	return ds
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := durations(10, 20, 30, 40, 50, 60, 70, 80, 90, 100)
+	tests := []struct {
+		name     string
+		sorted   []time.Duration
+		p        float64
+		expected time.Duration
+	}{
+		{name: "p0 is the minimum", sorted: sorted, p: 0, expected: 10 * time.Millisecond},
+		{name: "p50", sorted: sorted, p: 50, expected: 50 * time.Millisecond},
+		{name: "p90", sorted: sorted, p: 90, expected: 90 * time.Millisecond},
+		{name: "p95 rounds up", sorted: sorted, p: 95, expected: 100 * time.Millisecond},
+		{name: "p99 rounds up", sorted: sorted, p: 99, expected: 100 * time.Millisecond},
+		{name: "p100 is the maximum", sorted: sorted, p: 100, expected: 100 * time.Millisecond},
+		{name: "single sample", sorted: durations(42), p: 99, expected: 42 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &summary{costs: tt.sorted}
+			if got := s.percentile(tt.p); got != tt.expected {
+				t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSummaryStddev(t *testing.T) {
+	tests := []struct {
+		name     string
+		costs    []time.Duration
+		expected time.Duration
+	}{
+		{name: "single sample", costs: durations(100), expected: 0},
+		{name: "same samples", costs: durations(100, 100, 100), expected: 0},
+		{name: "population stddev", costs: durations(20, 40, 40, 40, 50, 50, 70, 90), expected: 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &summary{count: uint64(len(tt.costs)), costs: tt.costs}
+			for _, cost := range tt.costs {
+				s.totalCost += cost
+			}
+
+			if got := s.stddev(); got != tt.expected {
+				t.Errorf("stddev = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSummaryThroughput(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileSizeLevel backend.FileSizeLevel
+		totalCost     time.Duration
+		count         uint64
+		expected      float64
+	}{
+		{name: "small in a second", fileSizeLevel: backend.FileSizeLevelSmall, totalCost: 2 * time.Second, count: 2, expected: 1 << 20},
+		{name: "medium in half a second", fileSizeLevel: backend.FileSizeLevelMedium, totalCost: 500 * time.Millisecond, count: 1, expected: 20 << 20},
+		{name: "zero cost", fileSizeLevel: backend.FileSizeLevelSmall, count: 1, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &summary{fileSizeLevel: tt.fileSizeLevel, count: tt.count, totalCost: tt.totalCost}
+			if got := s.throughput(); math.Abs(got-tt.expected) > 1e-6 {
+				t.Errorf("throughput = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		wantErr bool
+	}{
+		{name: "default columns"},
+		{name: "all columns", names: []string{"all"}},
+		{name: "selected columns", names: []string{"times", "p50", "p99", "throughput"}},
+		{name: "unknown column", names: []string{"p50", "p42"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateColumns(tt.names); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateColumns(%v) error = %v, want error %t", tt.names, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSummaryCorrupted(t *testing.T) {
+	metrics := `# TYPE dragonfly_client_download_task_duration_milliseconds histogram
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="100"} 2
+dragonfly_client_download_task_duration_milliseconds_bucket{task_size_level="1",le="+Inf"} 2
+dragonfly_client_download_task_duration_milliseconds_sum{task_size_level="1"} 100
+dragonfly_client_download_task_duration_milliseconds_count{task_size_level="1"} 2
+`
+
+	tests := []struct {
+		name        string
+		timings     []*Timing
+		clientCosts int
+		corrupted   uint64
+		err         string
+	}{
+		{
+			name:        "no corrupted download",
+			timings:     []*Timing{{Elapsed: time.Second}, {Elapsed: time.Second}},
+			clientCosts: 2,
+		},
+		{
+			name:        "corrupted download excluded from the client costs",
+			timings:     []*Timing{{Elapsed: time.Second}, {Elapsed: time.Second, Corrupted: true}},
+			clientCosts: 1,
+			corrupted:   1,
+		},
+		{
+			name:    "all downloads corrupted",
+			timings: []*Timing{{Elapsed: time.Second, Corrupted: true}, {Elapsed: time.Second, Corrupted: true}},
+			err:     "all 2 downloads are corrupted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads := []*Download{{podName: "client-0", fileSizeLevel: backend.FileSizeLevelMicro, metricFamilies: parseMetricFamilies(t, metrics)}}
+			s, err := newSummary(backend.FileSizeLevelMicro, downloads, tt.timings)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("newSummary error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("newSummary failed: %v", err)
+			}
+
+			if len(s.clientCosts) != tt.clientCosts || s.corrupted != tt.corrupted {
+				t.Errorf("newSummary = %d client costs and %d corrupted, want %d and %d", len(s.clientCosts), s.corrupted, tt.clientCosts, tt.corrupted)
+			}
+
+			// The dfdaemon histogram includes the corrupted downloads.
+			if s.count != 2 {
+				t.Errorf("count = %d, want 2", s.count)
+			}
+		})
+	}
+}